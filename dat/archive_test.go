@@ -0,0 +1,94 @@
+package dat
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestExportArchive(t *testing.T) {
+	strs := []byte("strs\x00\x00")
+	entries := append(formatEntries[:4:4], testEntry{data: testGzip(t, strs), deflated: true})
+	datFile := testDat{entries: entries}.open(t, Options{})
+
+	ids := []uint32{firstTestBaseID, firstTestBaseID + 1, firstTestBaseID + 2, firstTestBaseID + 3, firstTestBaseID + 4}
+	want := []struct {
+		name string
+		data []byte
+	}{
+		{"4.dds", formatEntries[0].data},
+		{"5.strs", formatEntries[1].data},
+		{"6.dds", formatEntries[2].data},
+		{"7.bin", formatEntries[3].data},
+		{"8.strs", strs},
+	}
+
+	for _, format := range []ArchiveFormat{ArchiveZip, ArchiveTar} {
+		var out bytes.Buffer
+		if err := datFile.ExportArchive(&out, format, ids); err != nil {
+			t.Fatalf("%v: ExportArchive: %v", format, err)
+		}
+
+		var names []string
+		files := make(map[string][]byte)
+		switch format {
+		case ArchiveZip:
+			zr, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+			if err != nil {
+				t.Fatalf("zip: reading the archive: %v", err)
+			}
+			for _, f := range zr.File {
+				rc, err := f.Open()
+				if err != nil {
+					t.Fatalf("zip: opening %s: %v", f.Name, err)
+				}
+				data, err := io.ReadAll(rc)
+				rc.Close()
+				if err != nil {
+					t.Fatalf("zip: reading %s: %v", f.Name, err)
+				}
+				names = append(names, f.Name)
+				files[f.Name] = data
+			}
+		case ArchiveTar:
+			tr := tar.NewReader(&out)
+			for {
+				header, err := tr.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("tar: reading the archive: %v", err)
+				}
+				data, err := io.ReadAll(tr)
+				if err != nil {
+					t.Fatalf("tar: reading %s: %v", header.Name, err)
+				}
+				names = append(names, header.Name)
+				files[header.Name] = data
+			}
+		}
+
+		if len(names) != len(want) {
+			t.Fatalf("%v: archive holds %v, want %d files", format, names, len(want))
+		}
+		for i, file := range want {
+			if names[i] != file.name {
+				t.Errorf("%v: file %d is named %q, want %q", format, i, names[i], file.name)
+			}
+			if !bytes.Equal(files[file.name], file.data) {
+				t.Errorf("%v: %s holds %d bytes not matching the entry", format, file.name, len(files[file.name]))
+			}
+		}
+	}
+
+	if err := datFile.ExportArchive(io.Discard, ArchiveFormat(9), ids); err == nil {
+		t.Error("ExportArchive of an unknown format succeeded")
+	}
+	if err := datFile.ExportArchive(io.Discard, ArchiveTar, []uint32{999}); !errors.Is(err, ErrEntryNotFound) {
+		t.Errorf("ExportArchive of an unknown base ID returned %v, want ErrEntryNotFound", err)
+	}
+}
@@ -0,0 +1,140 @@
+package dat
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// ExtractAll extracts the entries named by the base IDs in ids using a pool of
+// workers. Entries are read with ReadAt, so the workers share one handle, and
+// each worker inflates with its own Decoder.
+// The first error cancels the remaining work and is returned once every
+// worker has stopped; cancelling ctx does the same.
+func (datFile *DatFile) ExtractAll(ctx context.Context, ids []uint32, workers int) (map[uint32][]byte, error) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	// Fail early, before starting workers, if the shared dictionary is unusable
+	if err := prepareHuffmanTreeDict(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+		results  = make(map[uint32][]byte, len(ids))
+		jobs     = make(chan uint32)
+	)
+
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			dec := NewDecoder()
+			for id := range jobs {
+				if ctx.Err() != nil {
+					continue // Draining after cancellation
+				}
+
+				index, err := datFile.rowForBaseID(id)
+				if err != nil {
+					fail(err)
+					continue
+				}
+
+				data, err := datFile.extractEntryLimit(ctx, dec, index, 0)
+				if err != nil {
+					fail(fmt.Errorf("extracting base ID %d: %w", id, err))
+					continue
+				}
+
+				mu.Lock()
+				results[id] = data
+				mu.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for _, id := range ids {
+		select {
+		case jobs <- id:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// ExtractManyOptions controls how ExtractMany shares results.
+type ExtractManyOptions struct {
+	// Copy gives each id resolving to an already extracted MFT row its own
+	// copy of the data. By default such ids share one slice.
+	Copy bool
+}
+
+// ExtractMany extracts the entries identified by ids, which are file IDs when
+// isFileID is set and base IDs otherwise. See ExtractManyWith.
+func (datFile *DatFile) ExtractMany(ids []uint32, isFileID bool) (map[uint32][]byte, error) {
+	return datFile.ExtractManyWith(ExtractManyOptions{}, ids, isFileID)
+}
+
+// ExtractManyWith resolves every id before reading anything, then reads and
+// decompresses each distinct MFT row once and maps every id resolving to it
+// to the result. The first id that cannot be resolved or extracted stops it.
+func (datFile *DatFile) ExtractManyWith(opts ExtractManyOptions, ids []uint32, isFileID bool) (map[uint32][]byte, error) {
+	rows := make(map[uint32]int, len(ids))
+	for _, id := range ids {
+		index, err := datFile.resolveIndex(id, isFileID)
+		if err != nil {
+			return nil, fmt.Errorf("resolving ID %d: %w", id, err)
+		}
+		rows[id] = index
+	}
+
+	extracted := make(map[int][]byte, len(rows))
+	results := make(map[uint32][]byte, len(rows))
+	for _, id := range ids {
+		if _, ok := results[id]; ok {
+			continue
+		}
+		index := rows[id]
+		data, ok := extracted[index]
+		if !ok {
+			var err error
+			if data, err = datFile.extractEntry(context.Background(), index); err != nil {
+				return nil, fmt.Errorf("extracting ID %d: %w", id, err)
+			}
+			extracted[index] = data
+		} else if opts.Copy {
+			data = append([]byte(nil), data...)
+		}
+		results[id] = data
+	}
+	return results, nil
+}
@@ -0,0 +1,100 @@
+package dat
+
+import (
+	"bytes"
+	"context"
+	"maps"
+	"testing"
+)
+
+func TestExtractAll(t *testing.T) {
+	datFile := testDat{entries: testEntries}.open(t, Options{})
+	ids := make([]uint32, len(testEntries))
+	for i := range testEntries {
+		ids[i] = uint32(firstTestBaseID + i)
+	}
+
+	for _, workers := range []int{0, 1, 4} {
+		results, err := datFile.ExtractAll(context.Background(), ids, workers)
+		if err != nil {
+			t.Fatalf("%d workers: ExtractAll: %v", workers, err)
+		}
+		if len(results) != len(ids) {
+			t.Errorf("%d workers: %d results, want %d", workers, len(results), len(ids))
+		}
+		for i, id := range ids {
+			if !bytes.Equal(results[id], testEntries[i].data) {
+				t.Errorf("%d workers: base ID %d does not match its entry", workers, id)
+			}
+		}
+	}
+}
+
+func TestExtractAllErrors(t *testing.T) {
+	datFile := testDat{entries: testEntries}.open(t, Options{})
+
+	if _, err := datFile.ExtractAll(context.Background(), []uint32{firstTestBaseID, 999}, 2); err == nil {
+		t.Error("ExtractAll with an unknown base ID succeeded")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := datFile.ExtractAll(ctx, []uint32{firstTestBaseID}, 2); err != context.Canceled {
+		t.Errorf("ExtractAll with a cancelled context returned %v, want context.Canceled", err)
+	}
+}
+
+func TestExtractMany(t *testing.T) {
+	datFile := testDat{
+		entries: testEntries,
+		fileIDs: [][2]uint32{{100, firstTestBaseID + 1}, {101, firstTestBaseID + 1}},
+	}.open(t, Options{})
+
+	tests := []struct {
+		name   string
+		copy   bool
+		shared bool
+	}{
+		{"shared", false, true},
+		{"copied", true, false},
+	}
+	for _, test := range tests {
+		results, err := datFile.ExtractManyWith(ExtractManyOptions{Copy: test.copy}, []uint32{100, 101, 100}, true)
+		if err != nil {
+			t.Fatalf("%s: ExtractManyWith: %v", test.name, err)
+		}
+		if len(results) != 2 || !bytes.Equal(results[100], testEntries[1].data) || !bytes.Equal(results[101], testEntries[1].data) {
+			t.Fatalf("%s: unexpected results for file IDs 100 and 101", test.name)
+		}
+		if shared := &results[100][0] == &results[101][0]; shared != test.shared {
+			t.Errorf("%s: results share their data = %v, want %v", test.name, shared, test.shared)
+		}
+	}
+
+	if _, err := datFile.ExtractMany([]uint32{100, 999}, true); err == nil {
+		t.Error("ExtractMany with an unknown file ID succeeded")
+	}
+}
+
+func TestExtractManyDecodesOnce(t *testing.T) {
+	datFile := testDat{
+		entries: testEntries,
+		fileIDs: [][2]uint32{{100, firstTestBaseID + 1}, {101, firstTestBaseID + 1}, {102, firstTestBaseID}},
+	}.open(t, Options{})
+
+	decodes := make(map[int]int)
+	decodeEntryHook = func(index int) { decodes[index]++ }
+	defer func() { decodeEntryHook = nil }()
+
+	results, err := datFile.ExtractMany([]uint32{100, 101, 102, 100}, true)
+	if err != nil {
+		t.Fatalf("ExtractMany: %v", err)
+	}
+	if len(results) != 3 {
+		t.Errorf("ExtractMany returned %d results, want 3", len(results))
+	}
+	want := map[int]int{firstTestBaseID - 1: 1, firstTestBaseID: 1}
+	if !maps.Equal(decodes, want) {
+		t.Errorf("ExtractMany decoded MFT indices %v times, want %v", decodes, want)
+	}
+}
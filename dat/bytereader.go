@@ -0,0 +1,75 @@
+package dat
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// byteReader reads little-endian fields from the front of a byte slice. The
+// first read past the end records an error; it and every later read return
+// zero values, so a parser can read a whole header and check Err once.
+type byteReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func newByteReader(data []byte) *byteReader {
+	return &byteReader{data: data}
+}
+
+// take returns the next n bytes, or nil once the data is exhausted.
+func (r *byteReader) take(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.data)-r.pos {
+		r.err = fmt.Errorf("reading %d bytes at offset %d of %d: %w", n, r.pos, len(r.data), io.ErrUnexpectedEOF)
+		return nil
+	}
+	b := r.data[r.pos : r.pos+n : r.pos+n]
+	r.pos += n
+	return b
+}
+
+// Bytes returns the next n bytes as a subslice of the data.
+func (r *byteReader) Bytes(n int) []byte {
+	return r.take(n)
+}
+
+// Skip moves past n bytes.
+func (r *byteReader) Skip(n int) {
+	r.take(n)
+}
+
+func (r *byteReader) U16() uint16 {
+	if b := r.take(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *byteReader) U32() uint32 {
+	if b := r.take(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *byteReader) U64() uint64 {
+	if b := r.take(8); b != nil {
+		return binary.LittleEndian.Uint64(b)
+	}
+	return 0
+}
+
+// Pos returns the offset of the next byte to be read.
+func (r *byteReader) Pos() int {
+	return r.pos
+}
+
+// Err returns the error of the first read that ran past the end of the data.
+func (r *byteReader) Err() error {
+	return r.err
+}
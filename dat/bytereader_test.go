@@ -0,0 +1,74 @@
+package dat
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestByteReader(t *testing.T) {
+	data := []byte{
+		0x01, 0x02,
+		0x03, 0x04, 0x05, 0x06,
+		0x07, 0x08, 0x09, 0x0A, 0x0B, 0x0C, 0x0D, 0x0E,
+		'a', 'b', 'c',
+		0xFF,
+	}
+	r := newByteReader(data)
+
+	if got := r.U16(); got != 0x0201 {
+		t.Errorf("U16 = %#x, want 0x0201", got)
+	}
+	if got := r.U32(); got != 0x06050403 {
+		t.Errorf("U32 = %#x, want 0x06050403", got)
+	}
+	if got := r.U64(); got != 0x0E0D0C0B0A090807 {
+		t.Errorf("U64 = %#x, want 0x0E0D0C0B0A090807", got)
+	}
+	b := r.Bytes(3)
+	if string(b) != "abc" {
+		t.Errorf("Bytes(3) = %q, want \"abc\"", b)
+	}
+	if cap(b) != 3 {
+		t.Errorf("Bytes(3) has capacity %d, so appending would overwrite the data", cap(b))
+	}
+	if r.Pos() != 17 || r.Err() != nil {
+		t.Fatalf("after the fields: Pos() = %d, Err() = %v", r.Pos(), r.Err())
+	}
+
+	// The first read past the end fails and so does every later one
+	if got := r.U16(); got != 0 {
+		t.Errorf("U16 past the end = %#x, want 0", got)
+	}
+	if !errors.Is(r.Err(), io.ErrUnexpectedEOF) {
+		t.Errorf("Err() = %v, want io.ErrUnexpectedEOF", r.Err())
+	}
+	first := r.Err()
+	r.Skip(0)
+	if got := r.Bytes(0); got != nil || r.Err() != first || r.Pos() != 17 {
+		t.Errorf("read after a failure returned %v and left Pos() = %d, Err() = %v", got, r.Pos(), r.Err())
+	}
+}
+
+func TestByteReaderBounds(t *testing.T) {
+	tests := []struct {
+		name string
+		read func(r *byteReader)
+		pos  int // Pos after the read
+		fail bool
+	}{
+		{"exact U32", func(r *byteReader) { r.U32() }, 4, false},
+		{"U64 of 4 bytes", func(r *byteReader) { r.U64() }, 0, true},
+		{"skip to the end", func(r *byteReader) { r.Skip(4) }, 4, false},
+		{"skip past the end", func(r *byteReader) { r.Skip(5) }, 0, true},
+		{"negative length", func(r *byteReader) { r.Bytes(-1) }, 0, true},
+		{"empty read", func(r *byteReader) { r.Bytes(0) }, 0, false},
+	}
+	for _, test := range tests {
+		r := newByteReader([]byte{1, 2, 3, 4})
+		test.read(r)
+		if r.Pos() != test.pos || (r.Err() != nil) != test.fail {
+			t.Errorf("%s: Pos() = %d, Err() = %v; want %d, failing %v", test.name, r.Pos(), r.Err(), test.pos, test.fail)
+		}
+	}
+}
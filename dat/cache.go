@@ -0,0 +1,109 @@
+package dat
+
+import (
+	"container/list"
+	"sync"
+)
+
+// CacheStats reports the activity of the decompression cache, see
+// Options.CacheSize.
+type CacheStats struct {
+	Hits    uint64
+	Misses  uint64
+	Entries int   // Entries currently cached
+	Bytes   int64 // Decompressed bytes currently cached
+}
+
+// entryCache is a least-recently-used cache of decompressed entries keyed by
+// MFT index, bounded by the total size of the cached data.
+type entryCache struct {
+	mu       sync.Mutex
+	capacity int64
+	size     int64
+	order    *list.List            // Most recently used at the front
+	entries  map[int]*list.Element // Values are *cachedEntry
+	hits     uint64
+	misses   uint64
+}
+
+// cachedEntry is the value of an entryCache list element.
+type cachedEntry struct {
+	index int
+	data  []byte
+}
+
+// newEntryCache returns a cache holding at most capacity bytes.
+func newEntryCache(capacity int64) *entryCache {
+	return &entryCache{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[int]*list.Element),
+	}
+}
+
+// get returns the cached data for index and marks it most recently used.
+func (cache *entryCache) get(index int) ([]byte, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	element, ok := cache.entries[index]
+	if !ok {
+		cache.misses++
+		return nil, false
+	}
+	cache.hits++
+	cache.order.MoveToFront(element)
+	return element.Value.(*cachedEntry).data, true
+}
+
+// put caches data for index, evicting the least recently used entries to make
+// room. Data larger than the whole cache is not cached.
+func (cache *entryCache) put(index int, data []byte) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if int64(len(data)) > cache.capacity {
+		return
+	}
+	if element, ok := cache.entries[index]; ok {
+		// Another goroutine decompressed the same entry concurrently
+		cache.order.MoveToFront(element)
+		return
+	}
+
+	for cache.size+int64(len(data)) > cache.capacity {
+		oldest := cache.order.Back()
+		entry := cache.order.Remove(oldest).(*cachedEntry)
+		delete(cache.entries, entry.index)
+		cache.size -= int64(len(entry.data))
+	}
+	cache.entries[index] = cache.order.PushFront(&cachedEntry{index, data})
+	cache.size += int64(len(data))
+}
+
+// clear drops every cached entry, keeping the hit and miss counts.
+func (cache *entryCache) clear() {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.order.Init()
+	clear(cache.entries)
+	cache.size = 0
+}
+
+// stats returns a snapshot of the cache counters.
+func (cache *entryCache) stats() CacheStats {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	return CacheStats{Hits: cache.hits, Misses: cache.misses, Entries: len(cache.entries), Bytes: cache.size}
+}
+
+// CacheStats returns the decompression cache counters. It is the zero value
+// when no cache was configured.
+func (datFile *DatFile) CacheStats() CacheStats {
+	if datFile.cache == nil {
+		return CacheStats{}
+	}
+	return datFile.cache.stats()
+}
@@ -0,0 +1,93 @@
+package dat
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEntryCache(t *testing.T) {
+	cache := newEntryCache(10)
+	put := func(index, size int) { cache.put(index, make([]byte, size)) }
+	cached := func(index int) bool {
+		cache.mu.Lock()
+		defer cache.mu.Unlock()
+		_, ok := cache.entries[index]
+		return ok
+	}
+
+	put(1, 4)
+	put(2, 4)
+	if _, ok := cache.get(1); !ok {
+		t.Fatal("entry 1 not cached")
+	}
+
+	// Entry 2 is now the least recently used and makes room for entry 3
+	put(3, 4)
+	if cached(2) || !cached(1) || !cached(3) {
+		t.Errorf("after evicting: cached 1 %v, 2 %v, 3 %v, want true, false, true", cached(1), cached(2), cached(3))
+	}
+
+	// Data larger than the cache is not cached and evicts nothing
+	put(4, 11)
+	if cached(4) || !cached(1) || !cached(3) {
+		t.Error("data larger than the cache changed its contents")
+	}
+
+	// Caching an index again keeps the first data
+	cache.put(1, []byte("other"))
+	if data, _ := cache.get(1); len(data) != 4 {
+		t.Errorf("entry 1 holds %d bytes after a second put, want 4", len(data))
+	}
+
+	if _, ok := cache.get(2); ok {
+		t.Error("evicted entry 2 found")
+	}
+	want := CacheStats{Hits: 2, Misses: 1, Entries: 2, Bytes: 8}
+	if stats := cache.stats(); stats != want {
+		t.Errorf("stats = %+v, want %+v", stats, want)
+	}
+
+	cache.clear()
+	want = CacheStats{Hits: 2, Misses: 1}
+	if stats := cache.stats(); stats != want {
+		t.Errorf("stats after clear = %+v, want %+v", stats, want)
+	}
+}
+
+func TestExtractCached(t *testing.T) {
+	datFile := testDat{entries: testEntries}.open(t, Options{CacheSize: 1 << 20})
+	compressed := uint32(firstTestBaseID + 2)
+
+	first, err := datFile.ExtractByBaseID(compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := datFile.ExtractByBaseID(compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(second, testEntries[2].data) || &first[0] != &second[0] {
+		t.Error("second extraction did not return the cached data")
+	}
+
+	// A prefix of a cached entry is served from the cache; stored entries are
+	// never cached
+	if prefix, err := datFile.ExtractPrefix(compressed, false, 10); err != nil || !bytes.Equal(prefix, first[:10]) {
+		t.Errorf("ExtractPrefix = %q, %v", prefix, err)
+	}
+	if _, err := datFile.ExtractByBaseID(firstTestBaseID); err != nil {
+		t.Fatal(err)
+	}
+	want := CacheStats{Hits: 2, Misses: 1, Entries: 1, Bytes: int64(len(first))}
+	if stats := datFile.CacheStats(); stats != want {
+		t.Errorf("CacheStats = %+v, want %+v", stats, want)
+	}
+
+	uncached := testDat{entries: testEntries}.open(t, Options{})
+	if _, err := uncached.ExtractByBaseID(compressed); err != nil {
+		t.Fatal(err)
+	}
+	if stats := uncached.CacheStats(); stats != (CacheStats{}) {
+		t.Errorf("CacheStats without a cache = %+v", stats)
+	}
+}
@@ -0,0 +1,366 @@
+package dat
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"math/bits"
+	"sort"
+	"sync"
+)
+
+const (
+	deflateMinMatch   = 4                      // Shortest copy, stored as the writeSizeConstantAddition
+	deflateMaxMatch   = deflateMinMatch + 0xFF // Longest copy a write size code can encode
+	deflateBlockCodes = 16 << 12               // Codes per block, the largest MaxCount allows
+	deflateHashBits   = 16                     // Size of the match finder hash table, in bits
+	deflateCopyCodes  = 34                     // Write offset codes inflate accepts
+)
+
+// huffmanCode is the code of one symbol, right-aligned in code.
+type huffmanCode struct {
+	code uint32
+	bits uint8
+}
+
+// huffmanTreeDictCodes returns the codes of the shared dictionary tree, which
+// tree descriptors are written with.
+var huffmanTreeDictCodes = sync.OnceValue(func() *[MAX_SYMBOL_VALUE]huffmanCode {
+	return huffmanCodes(huffmanTreeDict())
+})
+
+// huffmanCodes lists the code of every symbol of a tree built by
+// createHuffmanTree. Within each code length, symbols stored later in
+// SymbolValues get smaller codes, mirroring readCode.
+func huffmanCodes(huffmanTree *HuffmanTree) *[MAX_SYMBOL_VALUE]huffmanCode {
+	var codes [MAX_SYMBOL_VALUE]huffmanCode
+	previous := -1
+	for index := 0; index < MAX_CODE_BITS_LENGTH && huffmanTree.BitsLength[index] != 0; index++ {
+		bitsLength := huffmanTree.BitsLength[index]
+		last := int(huffmanTree.SymbolValueOffset[index])
+		first := huffmanTree.CompressedCodes[index] >> (32 - bitsLength)
+		for offset := last; offset > previous; offset-- {
+			codes[huffmanTree.SymbolValues[offset]] = huffmanCode{first + uint32(last-offset), bitsLength}
+		}
+		previous = last
+	}
+	return &codes
+}
+
+// huffmanTreeFromLengths builds the tree parseHuffmanTree would read from a
+// descriptor of the given code lengths, 0 marking unused symbols.
+func huffmanTreeFromLengths(lengths []uint8) *HuffmanTree {
+	var workingBits [MAX_CODE_BITS_LENGTH]int16
+	var workingCode [MAX_SYMBOL_VALUE]int16
+	for i := range workingBits {
+		workingBits[i] = -1
+	}
+	for i := range workingCode {
+		workingCode[i] = -1
+	}
+
+	// Same insertion order as parseHuffmanTree: highest symbol first
+	for symbol := len(lengths) - 1; symbol >= 0; symbol-- {
+		bitsLength := lengths[symbol]
+		if bitsLength == 0 {
+			continue
+		}
+		if workingBits[bitsLength] != -1 {
+			workingCode[symbol] = workingBits[bitsLength]
+		}
+		workingBits[bitsLength] = int16(symbol)
+	}
+
+	huffmanTree := &HuffmanTree{}
+	createHuffmanTree(huffmanTree, &workingBits, &workingCode)
+	return huffmanTree
+}
+
+// huffmanLengths returns Huffman code lengths for the symbol frequencies.
+// Unused symbols get length 0. readCode treats a tree whose first code is 0
+// as empty, which happens when every symbol has the same length and the code
+// is complete, so that case is broken by lengthening one code.
+func huffmanLengths(frequencies []uint32) []uint8 {
+	lengths := make([]uint8, len(frequencies))
+	var symbols []int
+	for symbol, frequency := range frequencies {
+		if frequency != 0 {
+			symbols = append(symbols, symbol)
+		}
+	}
+	switch len(symbols) {
+	case 0:
+		return lengths
+	case 1:
+		lengths[symbols[0]] = 1
+		return lengths
+	}
+	sort.SliceStable(symbols, func(i, j int) bool {
+		return frequencies[symbols[i]] < frequencies[symbols[j]]
+	})
+
+	// Two-queue Huffman construction: leaves come sorted and merged nodes are
+	// created in non-decreasing weight order, so both queues stay sorted
+	numLeaves := len(symbols)
+	weights := make([]uint64, 2*numLeaves-1)
+	parents := make([]int, 2*numLeaves-1)
+	for i, symbol := range symbols {
+		weights[i] = uint64(frequencies[symbol])
+	}
+	nextLeaf, nextMerged := 0, numLeaves
+	smallest := func(created int) int {
+		if nextLeaf < numLeaves && (nextMerged >= created || weights[nextLeaf] <= weights[nextMerged]) {
+			nextLeaf++
+			return nextLeaf - 1
+		}
+		nextMerged++
+		return nextMerged - 1
+	}
+	for created := numLeaves; created < len(weights); created++ {
+		first, second := smallest(created), smallest(created)
+		weights[created] = weights[first] + weights[second]
+		parents[first], parents[second] = created, created
+	}
+
+	// Depths from the root, which is the last node created
+	depths := make([]uint8, len(weights))
+	maxDepth := uint8(0)
+	for node := len(weights) - 2; node >= 0; node-- {
+		depths[node] = depths[parents[node]] + 1
+		if node < numLeaves {
+			maxDepth = max(maxDepth, depths[node])
+		}
+	}
+
+	if maxDepth >= MAX_CODE_BITS_LENGTH {
+		// Too skewed for a descriptor; a flat code that leaves room unused
+		flat := uint8(bits.Len(uint(numLeaves)))
+		for _, symbol := range symbols {
+			lengths[symbol] = flat
+		}
+		return lengths
+	}
+
+	for i, symbol := range symbols {
+		lengths[symbol] = depths[i]
+	}
+	if uniform := depths[0]; depths[numLeaves-1] == uniform && numLeaves == 1<<uniform {
+		lengths[symbols[0]]++
+	}
+	return lengths
+}
+
+// bitWriter packs codes most significant bit first into little-endian words,
+// ending every whole block with its checksum, see crcTable.
+type bitWriter struct {
+	words      []uint32
+	pending    uint64 // Bits not yet forming a whole word, right-aligned
+	count      uint8  // Number of pending bits
+	blockWords uint32 // Words per block, BlockSize when 0
+}
+
+// writeBits appends the low bits of value.
+func (w *bitWriter) writeBits(value uint32, bitsLength uint8) {
+	if bitsLength == 0 {
+		return
+	}
+	w.pending = w.pending<<bitsLength | uint64(value)&(uint64(1)<<bitsLength-1)
+	w.count += bitsLength
+	if w.count >= 32 {
+		w.count -= 32
+		w.appendWord(uint32(w.pending >> w.count))
+		w.pending &= uint64(1)<<w.count - 1
+	}
+}
+
+// appendWord appends a whole word, first inserting the checksum of the block
+// when the position is one pullByte skips.
+func (w *bitWriter) appendWord(word uint32) {
+	blockWords := w.blockWords
+	if blockWords == 0 {
+		blockWords = BlockSize
+	}
+	if (uint32(len(w.words))+1)%blockWords == 0 {
+		block := make([]byte, 0, 4*(blockWords-1))
+		for _, word := range w.words[uint32(len(w.words))-(blockWords-1):] {
+			block = binary.LittleEndian.AppendUint32(block, word)
+		}
+		w.words = append(w.words, crc32.Checksum(block, crcTable))
+	}
+	w.words = append(w.words, word)
+}
+
+// bytes pads the pending bits to a word and returns the stream, followed by
+// enough zero words for the decoder to look ahead past the last code.
+func (w *bitWriter) bytes() []byte {
+	if w.count > 0 {
+		w.writeBits(0, 32-w.count)
+	}
+	w.appendWord(0)
+	w.appendWord(0)
+	output := make([]byte, 4*len(w.words))
+	for i, word := range w.words {
+		binary.LittleEndian.PutUint32(output[4*i:], word)
+	}
+	return output
+}
+
+// lzToken is a literal byte, when length is 0, or a back-reference.
+type lzToken struct {
+	literal uint8
+	length  uint32
+	offset  uint32
+}
+
+// findMatches splits input into literals and back-references, using a hash
+// of the next deflateMinMatch bytes to find the latest earlier occurrence.
+func findMatches(input []byte) []lzToken {
+	table := make([]int, 1<<deflateHashBits)
+	for i := range table {
+		table[i] = -1
+	}
+
+	var tokens []lzToken
+	for position := 0; position < len(input); {
+		if position+deflateMinMatch <= len(input) {
+			hash := binary.LittleEndian.Uint32(input[position:]) * 0x9E3779B1 >> (32 - deflateHashBits)
+			candidate := table[hash]
+			table[hash] = position
+			if candidate >= 0 && position-candidate <= maxWriteOffset {
+				length := 0
+				for position+length < len(input) && length < deflateMaxMatch && input[candidate+length] == input[position+length] {
+					length++
+				}
+				if length >= deflateMinMatch {
+					tokens = append(tokens, lzToken{length: uint32(length), offset: uint32(position - candidate)})
+					position += length
+					continue
+				}
+			}
+		}
+		tokens = append(tokens, lzToken{literal: input[position]})
+		position++
+	}
+	return tokens
+}
+
+// writeSizeCode returns the symbol tree code, extra bits and extra bit count
+// inflate decodes to a copy of length deflateMinMatch+value.
+func writeSizeCode(value uint32) (uint16, uint32, uint8) {
+	if value < 4 {
+		return uint16(value), 0, 0
+	}
+	group := uint32(bits.Len32(value) - 2)
+	extraBits := uint8(group - 1)
+	return uint16(4*group + value>>extraBits - 4), value & (1<<extraBits - 1), extraBits
+}
+
+// writeOffsetCode returns the copy tree code, extra bits and extra bit count
+// inflate decodes to a back-reference distance of value+1.
+func writeOffsetCode(value uint32) (uint16, uint32, uint8) {
+	if value < 2 {
+		return uint16(value), 0, 0
+	}
+	group := uint32(bits.Len32(value) - 1)
+	extraBits := uint8(group - 1)
+	return uint16(2*group + value>>extraBits - 2), value & (1<<extraBits - 1), extraBits
+}
+
+// writeTreeDescriptor writes code lengths the way parseHuffmanTree reads
+// them: the symbol count, then runs of equal lengths from the highest symbol
+// down, each as one code of the dictionary tree.
+func writeTreeDescriptor(w *bitWriter, lengths []uint8) {
+	numberSymbol := len(lengths)
+	for numberSymbol > 0 && lengths[numberSymbol-1] == 0 {
+		numberSymbol--
+	}
+	w.writeBits(uint32(numberSymbol), 16)
+
+	dict := huffmanTreeDictCodes()
+	for symbol := numberSymbol - 1; symbol >= 0; {
+		bitsLength := lengths[symbol]
+		run := 1
+		for run < 8 && symbol-run >= 0 && lengths[symbol-run] == bitsLength {
+			run++
+		}
+		code := dict[(run-1)<<5|int(bitsLength)]
+		w.writeBits(code.code, code.bits)
+		symbol -= run
+	}
+}
+
+// Deflate compresses input into the GW2 format read by InflateBuffer and
+// NewReader. It favours simplicity over ratio: matches are found with a
+// single-entry hash table and every block carries its own Huffman trees.
+func Deflate(input []byte) ([]byte, error) {
+	return deflate(input, BlockSize)
+}
+
+// deflate is Deflate cutting the stream into blocks of blockWords words.
+func deflate(input []byte, blockWords uint32) ([]byte, error) {
+	if uint64(len(input)) > math.MaxUint32 {
+		return nil, fmt.Errorf("input of %d bytes is too large for the stream header", len(input))
+	}
+	if err := prepareHuffmanTreeDict(); err != nil {
+		return nil, err
+	}
+
+	// Skipped header word, decompressed size, then the write size addition
+	w := &bitWriter{blockWords: blockWords}
+	w.writeBits(0, 32)
+	w.writeBits(uint32(len(input)), 32)
+	w.writeBits(0, 4)
+	w.writeBits(deflateMinMatch-1, 4)
+
+	tokens := findMatches(input)
+	for start := 0; start < len(tokens); start += deflateBlockCodes {
+		block := tokens[start:min(start+deflateBlockCodes, len(tokens))]
+
+		symbolFrequencies := make([]uint32, MAX_SYMBOL_VALUE)
+		copyFrequencies := make([]uint32, deflateCopyCodes)
+		copies := 0
+		for _, token := range block {
+			if token.length == 0 {
+				symbolFrequencies[token.literal]++
+				continue
+			}
+			sizeCode, _, _ := writeSizeCode(token.length - deflateMinMatch)
+			symbolFrequencies[0x100+sizeCode]++
+			offsetCode, _, _ := writeOffsetCode(token.offset - 1)
+			copyFrequencies[offsetCode]++
+			copies++
+		}
+		if copies == 0 {
+			// The copy tree is never read, but an empty descriptor reads as no tree
+			copyFrequencies[0] = 1
+		}
+
+		symbolLengths := huffmanLengths(symbolFrequencies)
+		copyLengths := huffmanLengths(copyFrequencies)
+		writeTreeDescriptor(w, symbolLengths)
+		writeTreeDescriptor(w, copyLengths)
+		w.writeBits(deflateBlockCodes>>12-1, 4)
+
+		symbolCodes := huffmanCodes(huffmanTreeFromLengths(symbolLengths))
+		copyCodes := huffmanCodes(huffmanTreeFromLengths(copyLengths))
+		for _, token := range block {
+			if token.length == 0 {
+				code := symbolCodes[token.literal]
+				w.writeBits(code.code, code.bits)
+				continue
+			}
+
+			sizeCode, sizeExtra, sizeExtraBits := writeSizeCode(token.length - deflateMinMatch)
+			code := symbolCodes[0x100+sizeCode]
+			w.writeBits(code.code, code.bits)
+			w.writeBits(sizeExtra, sizeExtraBits)
+
+			offsetCode, offsetExtra, offsetExtraBits := writeOffsetCode(token.offset - 1)
+			code = copyCodes[offsetCode]
+			w.writeBits(code.code, code.bits)
+			w.writeBits(offsetExtra, offsetExtraBits)
+		}
+	}
+	return w.bytes(), nil
+}
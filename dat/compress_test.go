@@ -0,0 +1,172 @@
+package dat
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestDeflateRoundTrip(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	noise := make([]byte, maxWriteOffset)
+	random.Read(noise)
+	allBytes := make([]byte, 256)
+	for i := range allBytes {
+		allBytes[i] = byte(i)
+	}
+	// A block repeated past most of the back-reference window
+	distant := append(append(bytes.Clone(noise[:1000]), noise[1000:maxWriteOffset-2000]...), noise[:1000]...)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		shrinks bool // The stream is smaller than the input
+	}{
+		{"empty", []byte{}, false},
+		{"one byte", []byte{'x'}, false},
+		{"one symbol repeated", bytes.Repeat([]byte{'x'}, 5000), true},
+		{"every byte value", allBytes, false},
+		{"every byte value repeated", bytes.Repeat(allBytes, 100), true},
+		{"longest matches", bytes.Repeat([]byte("ab"), 10*deflateMaxMatch), true},
+		{"zeros over several blocks", make([]byte, 3<<20), true},
+		{"incompressible", noise, false},
+		{"distant match", distant, false},
+		{"mixed", testPayload(400000), true},
+	}
+	for _, test := range tests {
+		compressed, err := Deflate(test.data)
+		if err != nil {
+			t.Errorf("%s: Deflate: %v", test.name, err)
+			continue
+		}
+		if test.shrinks && len(compressed) >= len(test.data) {
+			t.Errorf("%s: Deflate wrote %d bytes for %d", test.name, len(compressed), len(test.data))
+		}
+
+		got, err := InflateBuffer(compressed, nil, 0)
+		if err != nil {
+			t.Errorf("%s: InflateBuffer: %v", test.name, err)
+			continue
+		}
+		if !bytes.Equal(got, test.data) {
+			t.Errorf("%s: round trip returned %d bytes not matching the %d input bytes", test.name, len(got), len(test.data))
+		}
+	}
+}
+
+func TestWriteSizeCode(t *testing.T) {
+	for value := uint32(0); value <= deflateMaxMatch-deflateMinMatch; value++ {
+		code, extra, extraBits := writeSizeCode(value)
+		if code > 28 || extra >= 1<<extraBits {
+			t.Fatalf("writeSizeCode(%d) = %d, %d, %d", value, code, extra, extraBits)
+		}
+
+		// What inflate decodes, before the constant addition
+		var base uint32
+		switch group := code / 4; {
+		case group == 0:
+			base = uint32(code)
+		case code == 28:
+			base = 0xFF
+		default:
+			base = 1 << (group - 1) * uint32(4+code%4)
+		}
+		if wantBits := max(int(code/4)-1, 0); code != 28 && int(extraBits) != wantBits {
+			t.Errorf("writeSizeCode(%d) writes %d extra bits, inflate reads %d", value, extraBits, wantBits)
+		}
+		if got := base | extra; got != value {
+			t.Errorf("writeSizeCode(%d) decodes to %d", value, got)
+		}
+	}
+}
+
+func TestWriteOffsetCode(t *testing.T) {
+	for value := uint32(0); value < maxWriteOffset; value++ {
+		code, extra, extraBits := writeOffsetCode(value)
+		if code >= deflateCopyCodes || extra >= 1<<extraBits {
+			t.Fatalf("writeOffsetCode(%d) = %d, %d, %d", value, code, extra, extraBits)
+		}
+
+		base := uint32(code)
+		if group := code / 2; group > 0 {
+			base = 1 << (group - 1) * uint32(2+code%2)
+		}
+		if wantBits := max(int(code/2)-1, 0); int(extraBits) != wantBits {
+			t.Fatalf("writeOffsetCode(%d) writes %d extra bits, inflate reads %d", value, extraBits, wantBits)
+		}
+		if got := base | extra; got != value {
+			t.Fatalf("writeOffsetCode(%d) decodes to %d", value, got)
+		}
+	}
+}
+
+func TestHuffmanLengths(t *testing.T) {
+	tests := []struct {
+		name        string
+		frequencies []uint32
+	}{
+		{"one symbol", []uint32{0, 0, 7}},
+		{"two symbols", []uint32{3, 0, 5}},
+		{"complete and equal", []uint32{1, 1, 1, 1}},
+		{"skewed", []uint32{1000, 1, 1, 2, 4, 8, 16, 32, 64, 128, 256, 512}},
+		{"literals", testFrequencies(MAX_SYMBOL_VALUE)},
+	}
+	for _, test := range tests {
+		lengths := huffmanLengths(test.frequencies)
+
+		// Lengths must form a prefix code: the Kraft sum is at most 1
+		var kraft float64
+		for symbol, bitsLength := range lengths {
+			if (bitsLength == 0) != (test.frequencies[symbol] == 0) {
+				t.Errorf("%s: symbol %d of frequency %d has length %d", test.name, symbol, test.frequencies[symbol], bitsLength)
+			}
+			if bitsLength >= MAX_CODE_BITS_LENGTH {
+				t.Errorf("%s: symbol %d has length %d", test.name, symbol, bitsLength)
+			}
+			if bitsLength != 0 {
+				kraft += 1 / float64(uint64(1)<<bitsLength)
+			}
+		}
+		if kraft > 1 {
+			t.Errorf("%s: lengths %v are not a prefix code", test.name, lengths)
+		}
+
+		// Every used symbol reads back through readCode
+		tree := huffmanTreeFromLengths(lengths)
+		codes := huffmanCodes(tree)
+		w := &bitWriter{}
+		var symbols []uint16
+		for symbol, bitsLength := range lengths {
+			if bitsLength != 0 {
+				w.writeBits(codes[symbol].code, codes[symbol].bits)
+				symbols = append(symbols, uint16(symbol))
+			}
+		}
+		w.writeBits(0, 32)
+		w.writeBits(0, 32)
+		stateData, err := newState(convertU8ToU32(w.bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range symbols {
+			var got uint16
+			if err := readCode(tree, stateData, &got); err != nil || got != want {
+				t.Errorf("%s: readCode = %d, %v; want %d", test.name, got, err, want)
+				break
+			}
+		}
+	}
+}
+
+// testFrequencies returns n symbol frequencies spread over several orders of
+// magnitude, with some symbols unused.
+func testFrequencies(n int) []uint32 {
+	random := rand.New(rand.NewSource(int64(n)))
+	frequencies := make([]uint32, n)
+	for i := range frequencies {
+		if random.Intn(5) != 0 {
+			frequencies[i] = uint32(1) << random.Intn(20)
+		}
+	}
+	return frequencies
+}
@@ -0,0 +1,68 @@
+package dat
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+)
+
+// crcTable is the CRC-32C (Castagnoli) table block checksums are computed
+// with.
+//
+// GW2-compressed streams are cut into blocks of BlockSize words, or the size
+// set by Options.BlockSize, and the last word of every whole block is a
+// checksum of the words before it, which pullByte skips when decoding. Those block checksums are what VerifyEntry,
+// Options.VerifyCRC and Verify check. They are taken to be CRC-32C, as Deflate
+// writes them, but no published description of the format names the
+// algorithm and it has not been confirmed against retail dats, so the checks
+// are experimental. The MFT CRC field is kept as read but not interpreted,
+// since nothing ties it to an algorithm.
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// crcApplies reports whether the entry carries block checksums: only
+// non-empty GW2-compressed entries do, and the contents of encrypted ones
+// cannot be checked without the key.
+func crcApplies(mftEntry MFTEntry) bool {
+	return mftEntry.IsCompressed() && !mftEntry.IsEmpty() && !mftEntry.IsEncrypted()
+}
+
+// VerifyEntry reads the MFT row at the 0-based index and checks the block
+// checksums of its compressed stream. Entries crcApplies leaves out pass, as
+// do gzip and zlib streams and streams shorter than one block.
+//
+// VerifyEntry is experimental, see crcTable: a mismatch may mean the
+// checksums of the dat are not CRC-32C rather than that the entry is damaged.
+func (datFile *DatFile) VerifyEntry(index int) error {
+	if index < 0 || index >= len(datFile.MFTData) {
+		return fmt.Errorf("MFT index %d out of range [0, %d): %w", index, len(datFile.MFTData), ErrEntryNotFound)
+	}
+	if !crcApplies(datFile.MFTData[index]) {
+		return nil
+	}
+
+	buffer, err := datFile.readRaw(index)
+	if err != nil {
+		return err
+	}
+	return checkEntryCRC(index, buffer, datFile.streamBlockSize())
+}
+
+// checkEntryCRC compares the checksum word ending each whole block of
+// blockWords words of buffer, the stored bytes of a compressed entry, with the
+// CRC of the block.
+func checkEntryCRC(index int, buffer []byte, blockWords uint32) error {
+	if r, ok := openStandardStream(buffer); ok {
+		r.Close()
+		return nil
+	}
+
+	blockBytes := 4 * int(blockWords)
+	for block := 0; (block+1)*blockBytes <= len(buffer); block++ {
+		end := (block + 1) * blockBytes
+		expected := binary.LittleEndian.Uint32(buffer[end-4:])
+		if computed := crc32.Checksum(buffer[block*blockBytes:end-4], crcTable); computed != expected {
+			return fmt.Errorf("CRC mismatch in block %d of MFT entry %d: expected %#08x, computed %#08x", block, index, expected, computed)
+		}
+	}
+	return nil
+}
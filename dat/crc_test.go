@@ -0,0 +1,98 @@
+package dat
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCheckEntryCRC(t *testing.T) {
+	compressed := testDeflate(t, testPayload(400000))
+	if len(compressed) < 2*4*BlockSize {
+		t.Fatalf("the stream of %d bytes does not span two blocks", len(compressed))
+	}
+
+	tests := []struct {
+		name      string
+		flip      int    // Byte to flip, none when negative
+		wantError string // Expected in the error, none when empty
+	}{
+		{"intact", -1, ""},
+		{"data in block 0", 100, "block 0 "},
+		{"checksum of block 0", 4*BlockSize - 2, "block 0 "},
+		{"data in block 1", 4*BlockSize + 100, "block 1 "},
+		{"partial last block", len(compressed) - 10, ""},
+	}
+	for _, test := range tests {
+		buffer := bytes.Clone(compressed)
+		if test.flip >= 0 {
+			buffer[test.flip] ^= 0x01
+		}
+		err := checkEntryCRC(7, buffer, BlockSize)
+		switch {
+		case test.wantError == "" && err != nil:
+			t.Errorf("%s: checkEntryCRC: %v", test.name, err)
+		case test.wantError != "" && (err == nil || !strings.Contains(err.Error(), test.wantError)):
+			t.Errorf("%s: checkEntryCRC returned %v, want an error naming %q", test.name, err, test.wantError)
+		}
+	}
+}
+
+func TestCRCApplies(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry MFTEntry
+		want  bool
+	}{
+		{"compressed", MFTEntry{Size: 10, CompressionFlag: CompressionGW2}, true},
+		{"stored", MFTEntry{Size: 10}, false},
+		{"empty compressed", MFTEntry{CompressionFlag: CompressionGW2}, false},
+		{"encrypted", MFTEntry{Size: 10, CompressionFlag: CompressionGW2, EntryFlag: EntryFlagEncrypted}, false},
+	}
+	for _, test := range tests {
+		if got := crcApplies(test.entry); got != test.want {
+			t.Errorf("%s: crcApplies = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+// damagedCRCDat returns a dat whose compressed entry at base ID
+// firstTestBaseID+1 has a flipped byte in its first block.
+func damagedCRCDat(t *testing.T) []byte {
+	t.Helper()
+	data := testDat{entries: []testEntry{
+		{data: []byte("stored entry")},
+		{data: testPayload(400000), compressed: true},
+		{data: testPayload(400001), compressed: true},
+	}}.build(t)
+	datFile, err := OpenReaderAt(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[datFile.MFTData[firstTestBaseID].Offset+100] ^= 0x01
+	return data
+}
+
+func TestVerifyCRC(t *testing.T) {
+	data := damagedCRCDat(t)
+	datFile, err := OpenReaderAtWithOptions(bytes.NewReader(data), int64(len(data)), Options{VerifyCRC: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Reserved and stored rows carry no block checksums and always pass
+	for index := range datFile.MFTData {
+		err := datFile.VerifyEntry(index)
+		if damaged := index == firstTestBaseID; damaged != (err != nil) {
+			t.Errorf("VerifyEntry(%d) = %v, damaged %v", index, err, damaged)
+		}
+	}
+	for _, id := range []uint32{firstTestBaseID, firstTestBaseID + 2} {
+		if _, err := datFile.ExtractByBaseID(id); err != nil {
+			t.Errorf("ExtractByBaseID(%d) with VerifyCRC: %v", id, err)
+		}
+	}
+	if _, err := datFile.ExtractByBaseID(firstTestBaseID + 1); err == nil || !strings.Contains(err.Error(), "CRC mismatch") {
+		t.Errorf("ExtractByBaseID of the damaged entry returned %v, want a CRC mismatch", err)
+	}
+}
@@ -0,0 +1,135 @@
+package dat
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// DDS header constants, see the DDS_HEADER and DDS_PIXELFORMAT documentation.
+const (
+	ddsMagic          = "DDS "
+	ddsHeaderSize     = 124
+	ddsPixelFormatLen = 32
+	ddsDX10HeaderSize = 20
+
+	ddsdCaps        = 0x1
+	ddsdHeight      = 0x2
+	ddsdWidth       = 0x4
+	ddsdPixelFormat = 0x1000
+	ddsdMipMapCount = 0x20000
+	ddsdLinearSize  = 0x80000
+
+	ddpfFourCC = 0x4
+
+	ddsCapsComplex = 0x8
+	ddsCapsTexture = 0x1000
+	ddsCapsMipMap  = 0x400000
+
+	dxgiFormatBC4Unorm     = 80
+	dxgiFormatBC5Unorm     = 83
+	d3d10ResourceTexture2D = 3
+)
+
+// ddsFormat describes how an ATEX pixel format is stored in a DDS file.
+type ddsFormat struct {
+	fourCC     string // Legacy fourCC, or "DX10" when dxgiFormat is used
+	dxgiFormat uint32 // DXGI_FORMAT for the DX10 extended header
+	blockBytes int    // Bytes per 4x4 block
+}
+
+// ddsFormats maps ATEX pixel formats to DDS. DXTN normal maps are stored as
+// DXT3 blocks and DXTL as DXT5; DXTA and 3DCX have no legacy fourCC and use
+// the DX10 header with BC4 and BC5.
+var ddsFormats = map[string]ddsFormat{
+	"DXT1": {"DXT1", 0, 8},
+	"DXT2": {"DXT3", 0, 16},
+	"DXT3": {"DXT3", 0, 16},
+	"DXTN": {"DXT3", 0, 16},
+	"DXT4": {"DXT5", 0, 16},
+	"DXT5": {"DXT5", 0, 16},
+	"DXTL": {"DXT5", 0, 16},
+	"DXTA": {"DX10", dxgiFormatBC4Unorm, 8},
+	"3DCX": {"DX10", dxgiFormatBC5Unorm, 16},
+}
+
+// WrapDDS prepends a DDS header describing tex to its payload, which must hold
+// decoded block data. The mip count is derived from the payload size.
+func WrapDDS(tex *ATEXTexture) ([]byte, error) {
+	format, ok := ddsFormats[tex.Format]
+	if !ok {
+		return nil, fmt.Errorf("unsupported texture format %q", tex.Format)
+	}
+	if tex.Width == 0 || tex.Height == 0 {
+		return nil, fmt.Errorf("invalid texture size %dx%d", tex.Width, tex.Height)
+	}
+
+	linearSize := ddsLevelSize(int(tex.Width), int(tex.Height), format.blockBytes)
+	mipCount := ddsMipCount(int(tex.Width), int(tex.Height), format.blockBytes, len(tex.Data))
+
+	flags := uint32(ddsdCaps | ddsdHeight | ddsdWidth | ddsdPixelFormat | ddsdLinearSize)
+	caps := uint32(ddsCapsTexture)
+	if mipCount > 1 {
+		flags |= ddsdMipMapCount
+		caps |= ddsCapsComplex | ddsCapsMipMap
+	}
+
+	out := make([]byte, 0, 4+ddsHeaderSize+ddsDX10HeaderSize+len(tex.Data))
+	out = append(out, ddsMagic...)
+	out = binary.LittleEndian.AppendUint32(out, ddsHeaderSize)
+	out = binary.LittleEndian.AppendUint32(out, flags)
+	out = binary.LittleEndian.AppendUint32(out, uint32(tex.Height))
+	out = binary.LittleEndian.AppendUint32(out, uint32(tex.Width))
+	out = binary.LittleEndian.AppendUint32(out, uint32(linearSize))
+	out = binary.LittleEndian.AppendUint32(out, 0) // Depth
+	out = binary.LittleEndian.AppendUint32(out, uint32(mipCount))
+	out = append(out, make([]byte, 11*4)...) // Reserved1
+
+	// DDS_PIXELFORMAT
+	out = binary.LittleEndian.AppendUint32(out, ddsPixelFormatLen)
+	out = binary.LittleEndian.AppendUint32(out, ddpfFourCC)
+	out = append(out, format.fourCC...)
+	out = append(out, make([]byte, 5*4)...) // RGBBitCount and masks
+
+	out = binary.LittleEndian.AppendUint32(out, caps)
+	out = append(out, make([]byte, 4*4)...) // Caps2, Caps3, Caps4, Reserved2
+
+	if format.fourCC == "DX10" {
+		out = binary.LittleEndian.AppendUint32(out, format.dxgiFormat)
+		out = binary.LittleEndian.AppendUint32(out, d3d10ResourceTexture2D)
+		out = binary.LittleEndian.AppendUint32(out, 0) // MiscFlag
+		out = binary.LittleEndian.AppendUint32(out, 1) // ArraySize
+		out = binary.LittleEndian.AppendUint32(out, 0) // MiscFlags2
+	}
+
+	return append(out, tex.Data...), nil
+}
+
+// ExtractTextureDDS extracts the ATEX texture with the given base ID and
+// returns the top mip level of its decoded blocks wrapped as a DDS file.
+func (datFile *DatFile) ExtractTextureDDS(id uint32) ([]byte, error) {
+	tex, blocks, err := datFile.extractTextureBlocks(id)
+	if err != nil {
+		return nil, err
+	}
+	tex.Data = blocks
+	return WrapDDS(tex)
+}
+
+// ddsLevelSize returns the byte size of one mip level of block data.
+func ddsLevelSize(width, height, blockBytes int) int {
+	return max(1, (width+3)/4) * max(1, (height+3)/4) * blockBytes
+}
+
+// ddsMipCount counts the mip levels that fit in payloadSize, at least one.
+func ddsMipCount(width, height, blockBytes, payloadSize int) int {
+	count := 0
+	for payloadSize >= ddsLevelSize(width, height, blockBytes) {
+		payloadSize -= ddsLevelSize(width, height, blockBytes)
+		count++
+		if width == 1 && height == 1 {
+			break
+		}
+		width, height = max(1, width/2), max(1, height/2)
+	}
+	return max(1, count)
+}
@@ -0,0 +1,87 @@
+package dat
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestWrapDDS(t *testing.T) {
+	tests := []struct {
+		name     string
+		tex      ATEXTexture
+		fourCC   string
+		dxgi     uint32 // DXGI format of the DX10 header, if any
+		mipCount uint32
+	}{
+		{"DXT1 single level", ATEXTexture{Format: "DXT1", Width: 8, Height: 8, Data: make([]byte, 32)}, "DXT1", 0, 1},
+		{"DXT1 full chain", ATEXTexture{Format: "DXT1", Width: 8, Height: 8, Data: make([]byte, 32+8+8)}, "DXT1", 0, 3},
+		{"DXTN as DXT3", ATEXTexture{Format: "DXTN", Width: 4, Height: 4, Data: make([]byte, 16)}, "DXT3", 0, 1},
+		{"DXTL as DXT5", ATEXTexture{Format: "DXTL", Width: 16, Height: 4, Data: make([]byte, 64+32)}, "DXT5", 0, 2},
+		{"3DCX as BC5", ATEXTexture{Format: "3DCX", Width: 4, Height: 4, Data: make([]byte, 16)}, "DX10", dxgiFormatBC5Unorm, 1},
+		{"DXTA as BC4", ATEXTexture{Format: "DXTA", Width: 2, Height: 2, Data: make([]byte, 8)}, "DX10", dxgiFormatBC4Unorm, 1},
+	}
+	for _, test := range tests {
+		out, err := WrapDDS(&test.tex)
+		if err != nil {
+			t.Errorf("%s: WrapDDS: %v", test.name, err)
+			continue
+		}
+
+		headerEnd := 4 + ddsHeaderSize
+		if test.dxgi != 0 {
+			headerEnd += ddsDX10HeaderSize
+		}
+		field := func(offset int) uint32 { return binary.LittleEndian.Uint32(out[4+offset:]) }
+		switch {
+		case string(out[:4]) != ddsMagic || field(0) != ddsHeaderSize:
+			t.Errorf("%s: bad DDS magic or header size", test.name)
+		case field(8) != uint32(test.tex.Height) || field(12) != uint32(test.tex.Width):
+			t.Errorf("%s: size %dx%d, want %dx%d", test.name, field(12), field(8), test.tex.Width, test.tex.Height)
+		case field(24) != test.mipCount:
+			t.Errorf("%s: mip count %d, want %d", test.name, field(24), test.mipCount)
+		case string(out[4+80:4+84]) != test.fourCC:
+			t.Errorf("%s: fourCC %q, want %q", test.name, out[4+80:4+84], test.fourCC)
+		case test.dxgi != 0 && binary.LittleEndian.Uint32(out[4+ddsHeaderSize:]) != test.dxgi:
+			t.Errorf("%s: DXGI format %d, want %d", test.name, binary.LittleEndian.Uint32(out[4+ddsHeaderSize:]), test.dxgi)
+		case !bytes.Equal(out[headerEnd:], test.tex.Data):
+			t.Errorf("%s: %d payload bytes after the header, want %d", test.name, len(out)-headerEnd, len(test.tex.Data))
+		}
+	}
+}
+
+func TestWrapDDSRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		tex  ATEXTexture
+	}{
+		{"unknown format", ATEXTexture{Format: "ABCD", Width: 4, Height: 4}},
+		{"zero width", ATEXTexture{Format: "DXT1", Height: 4}},
+		{"zero height", ATEXTexture{Format: "DXT1", Width: 4}},
+	}
+	for _, test := range tests {
+		if _, err := WrapDDS(&test.tex); err == nil {
+			t.Errorf("%s: WrapDDS succeeded", test.name)
+		}
+	}
+}
+
+func TestDDSMipCount(t *testing.T) {
+	tests := []struct {
+		width, height, blockBytes, payloadSize int
+		want                                   int
+	}{
+		{4, 4, 8, 8, 1},
+		{4, 4, 8, 0, 1}, // At least one level
+		{8, 8, 8, 32, 1},
+		{8, 8, 8, 47, 2},
+		{8, 8, 8, 48, 3},
+		{8, 8, 8, 1000, 4}, // Stops at 1x1
+		{256, 128, 16, 256 / 4 * 128 / 4 * 16, 1},
+	}
+	for _, test := range tests {
+		if got := ddsMipCount(test.width, test.height, test.blockBytes, test.payloadSize); got != test.want {
+			t.Errorf("ddsMipCount(%d, %d, %d, %d) = %d, want %d", test.width, test.height, test.blockBytes, test.payloadSize, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package dat
+
+import (
+	"context"
+	"errors"
+)
+
+// Decoder inflates GW2-compressed buffers, keeping the input words, block
+// trees and output buffer of one call for the next instead of allocating them
+// again. A Decoder must not be used by several goroutines at once; give each
+// worker its own.
+type Decoder struct {
+	words  []uint32 // Input of the last call, as words
+	output []uint8  // Output of the last Inflate
+	f      inflater
+}
+
+// NewDecoder returns a Decoder. Its scratch space grows on first use.
+func NewDecoder() *Decoder {
+	return &Decoder{}
+}
+
+// Inflate decompresses a GW2-compressed buffer. The returned slice belongs to
+// the Decoder and is overwritten by the next call to Inflate; copy it to keep
+// it longer.
+func (d *Decoder) Inflate(input []byte) ([]byte, error) {
+	output, err := d.inflate(context.Background(), input, 0, DefaultMaxDecompressedSize, BlockSize, nil, d.output)
+	if err != nil {
+		return nil, err
+	}
+	d.output = output
+	return output, nil
+}
+
+// inflate decompresses input, a stream cut into blocks of blockWords words,
+// into dst, reusing its capacity and allocating only when it is too small,
+// and returns the slice holding the output. A non-zero limit caps the output
+// size, and allocations above maxSize are refused; ctx and onProgress are as
+// for inflateData.
+func (d *Decoder) inflate(ctx context.Context, input []byte, limit, maxSize, blockWords uint32, onProgress func(done, total uint32), dst []byte) ([]byte, error) {
+	if input == nil {
+		return nil, errors.New("input buffer is null")
+	}
+	if err := prepareHuffmanTreeDict(); err != nil {
+		return nil, err
+	}
+
+	d.words = convertU8ToU32Into(d.words, input)
+	stateData, outputBufferSize, err := startStream(d.words, blockWords)
+	if err != nil {
+		return nil, err
+	}
+	if limit != 0 {
+		outputBufferSize = min(outputBufferSize, limit)
+	}
+
+	if uint64(cap(dst)) < uint64(outputBufferSize) {
+		if err := checkDecompressedSize(outputBufferSize, maxSize); err != nil {
+			return nil, err
+		}
+		dst = make([]uint8, outputBufferSize)
+	}
+	dst = dst[:outputBufferSize]
+
+	d.f.reset(stateData)
+	d.f.ctx = ctx
+	d.f.onProgress, d.f.total = onProgress, outputBufferSize
+	_, err = d.f.inflate(dst, 0, outputBufferSize)
+	d.f.ctx, d.f.onProgress, d.f.stateData = nil, nil, nil // Keep nothing of this call alive
+	if err != nil {
+		return nil, err
+	}
+	if onProgress != nil {
+		onProgress(outputBufferSize, outputBufferSize)
+	}
+	return dst, nil
+}
@@ -0,0 +1,74 @@
+package dat
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestDecoder(t *testing.T) {
+	d := NewDecoder()
+
+	// Shrinking, growing and empty outputs all reuse the same Decoder
+	for _, size := range []int{70000, 5000, 400000, 0, 100, 70000} {
+		data := testPayload(size)
+		got, err := d.Inflate(testDeflate(t, data))
+		if err != nil {
+			t.Fatalf("size %d: Inflate: %v", size, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("size %d: Inflate returned %d bytes not matching the input", size, len(got))
+		}
+	}
+
+	// Output that fits the previous buffer is written over it
+	first, err := d.Inflate(testDeflate(t, testPayload(1000)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept := bytes.Clone(first)
+	second, err := d.Inflate(testDeflate(t, bytes.Repeat([]byte{'x'}, 1000)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if &first[0] != &second[0] || bytes.Equal(first, kept) {
+		t.Error("Inflate did not reuse its output buffer")
+	}
+
+	// A failed call leaves the Decoder usable
+	if _, err := d.Inflate(testDeflate(t, testPayload(5000))[:100]); !errors.Is(err, ErrCorruptStream) {
+		t.Errorf("Inflate of a truncated stream returned %v, want ErrCorruptStream", err)
+	}
+	if _, err := d.Inflate(nil); err == nil {
+		t.Error("Inflate of a nil buffer succeeded")
+	}
+	data := testPayload(3000)
+	if got, err := d.Inflate(testDeflate(t, data)); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("Inflate after failures returned %d bytes, %v", len(got), err)
+	}
+}
+
+func BenchmarkDecoder(b *testing.B) {
+	data := testPayload(70000)
+	compressed := testDeflate(b, data)
+
+	b.Run("Decoder", func(b *testing.B) {
+		d := NewDecoder()
+		b.SetBytes(int64(len(data)))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := d.Inflate(compressed); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("InflateBuffer", func(b *testing.B) {
+		b.SetBytes(int64(len(data)))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := InflateBuffer(compressed, nil, 0); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
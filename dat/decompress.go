@@ -0,0 +1,963 @@
+package dat
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Limits of the GW2 compression format.
+const (
+	// MAX_SYMBOL_VALUE is the size of the symbol alphabet: 256 literal bytes
+	// followed by the 29 write size codes of a back-reference (0x100-0x11C).
+	MAX_SYMBOL_VALUE = 285
+
+	// MAX_CODE_BITS_LENGTH bounds Huffman code lengths, which are read from a
+	// 32-bit window of the input; lengths run from 1 to 31.
+	MAX_CODE_BITS_LENGTH = 32
+
+	// BlockSize is the number of 32-bit words in a block of a compressed
+	// stream (64 KiB). The last word of every block is a checksum rather than
+	// stream data, see pullByte. Streams outside any dat always use it; a
+	// DatFile can be told to use another size, see Options.BlockSize.
+	BlockSize = 0x4000
+)
+
+// Bounds of a plausible stream block size, in words, see
+// validStreamBlockSize.
+const (
+	minStreamBlockSize = 0x100
+	maxStreamBlockSize = 0x40000
+)
+
+// validStreamBlockSize reports whether words is a plausible number of words
+// per stream block: a power of two between minStreamBlockSize and
+// maxStreamBlockSize.
+func validStreamBlockSize(words uint32) bool {
+	return words >= minStreamBlockSize && words <= maxStreamBlockSize && words&(words-1) == 0
+}
+
+// DefaultMaxDecompressedSize is the largest output allocated for one stream
+// unless Options.MaxDecompressedSize sets another limit, so a corrupt or
+// crafted size in a stream header cannot exhaust memory. Functions working on
+// a single buffer, such as InflateBuffer, always use it.
+const DefaultMaxDecompressedSize = 256 << 20
+
+// ErrCorruptStream is returned, wrapped, when a GW2-compressed stream is
+// truncated or holds codes that do not decode. Cancellation and the
+// decompressed size limit are reported as themselves instead.
+var ErrCorruptStream = errors.New("corrupt compressed stream")
+
+// corruptStream wraps an error found in the stream data with ErrCorruptStream.
+func corruptStream(err error) error {
+	return fmt.Errorf("%w: %w", ErrCorruptStream, err)
+}
+
+// checkDecompressedSize refuses a decompressed size above maxSize, unless
+// maxSize is 0.
+func checkDecompressedSize(size, maxSize uint32) error {
+	if maxSize != 0 && size > maxSize {
+		return fmt.Errorf("decompressed size %d exceeds the %d byte limit", size, maxSize)
+	}
+	return nil
+}
+
+// HuffmanTree structure
+type HuffmanTree struct {
+	SymbolValues      [MAX_SYMBOL_VALUE]uint16
+	CompressedCodes   [MAX_SYMBOL_VALUE]uint32
+	BitsLength        [MAX_SYMBOL_VALUE]uint8
+	SymbolValueOffset [MAX_SYMBOL_VALUE]uint16
+}
+
+// State structure for managing decompression
+type State struct {
+	InputData     []uint32      // Input data (compressed)
+	InputSize     uint32        // Size of the input data
+	InputPosition uint32        // Current position in the input
+	Head          uint32        // Next bits of the input, left-aligned
+	Bits          uint32        // Bits read from input
+	Buffer        uint32        // Buffer for storing bits
+	Empty         bool          // Flag to check if input is empty
+	InputReader   *bufio.Reader // Streaming input, used instead of InputData when set
+	Err           error         // First error reading the input, see pullByte
+	BlockWords    uint32        // Words per block of the stream, BlockSize when 0
+}
+
+// blockWords returns the number of words per block of the stream.
+func (stateData *State) blockWords() uint32 {
+	if stateData.BlockWords == 0 {
+		return BlockSize
+	}
+	return stateData.BlockWords
+}
+
+// newState returns a State reading the in-memory words of input from the
+// start, with no bits buffered yet.
+func newState(input []uint32) (*State, error) {
+	if len(input) == 0 {
+		return nil, corruptStream(errors.New("empty input"))
+	}
+	return &State{
+		InputData:     input,
+		InputSize:     uint32(len(input)),
+		InputPosition: 0,
+		Bits:          0,
+	}, nil
+}
+
+// huffmanTreeDict returns the shared tree the per-block Huffman trees are
+// encoded with. It is built on first use and never modified afterwards, so
+// concurrent decompressions only ever read it.
+var huffmanTreeDict = sync.OnceValue(newHuffmanTreeDict)
+
+// pullByte loads the next input word into Head and Buffer.
+//
+// GW2 compressed streams are cut into blocks of BlockSize words (64 KiB), or
+// stateData.BlockWords when set. The last word of each block is a checksum of the block, not stream data, so it
+// is skipped. Running out of input records the error in stateData.Err and
+// supplies zero bits; decoders check Err at their next code boundary.
+func pullByte(stateData *State) {
+	if stateData.Bits >= 32 {
+		setStateError(stateData, errors.New("tried to pull a value while 32 bits are still available"))
+		return
+	}
+
+	if (stateData.InputPosition+1)%stateData.blockWords() == 0 {
+		if stateData.InputReader != nil {
+			if _, err := stateData.InputReader.Discard(4); err != nil {
+				setInputError(stateData, fmt.Errorf("block checksum word at %d: %w", stateData.InputPosition, io.ErrUnexpectedEOF))
+				return
+			}
+		} else if stateData.InputPosition+1 >= stateData.InputSize {
+			setInputError(stateData, fmt.Errorf("block checksum word at %d is past the end of input (%d words)", stateData.InputPosition, stateData.InputSize))
+			return
+		}
+		stateData.InputPosition++
+	}
+
+	var tempValue uint32
+	if stateData.InputReader != nil {
+		var err error
+		if tempValue, err = readInputWord(stateData.InputReader); err != nil {
+			setInputError(stateData, err)
+			return
+		}
+	} else {
+		if stateData.InputPosition >= stateData.InputSize {
+			setInputError(stateData, fmt.Errorf("reached end of input (%d words) while fetching a new word", stateData.InputSize))
+			return
+		}
+		tempValue = stateData.InputData[stateData.InputPosition]
+	}
+
+	loadWord(stateData, tempValue)
+	stateData.InputPosition++
+}
+
+// loadWord appends a 32-bit word to the bits held in Head and Buffer.
+func loadWord(stateData *State, tempValue uint32) {
+	if stateData.Bits == 0 {
+		stateData.Head = tempValue
+		stateData.Buffer = 0
+	} else {
+		stateData.Head |= tempValue >> stateData.Bits
+		stateData.Buffer = tempValue << (32 - stateData.Bits)
+	}
+
+	stateData.Bits += 32
+}
+
+// setInputError records the first input error and feeds a zero word so the
+// bit reader stays consistent until the error is noticed.
+func setInputError(stateData *State, err error) {
+	setStateError(stateData, err)
+	loadWord(stateData, 0)
+}
+
+// setStateError records the first error of the bit reader in stateData.Err,
+// where readCode and the decoders pick it up; the reader never exits.
+func setStateError(stateData *State, err error) {
+	if stateData.Err == nil {
+		stateData.Err = err
+	}
+}
+
+// readInputWord reads the next little-endian word of a streaming input,
+// zero-padding a partial final word.
+func readInputWord(r *bufio.Reader) (uint32, error) {
+	var word [4]uint8
+	if n, _ := io.ReadFull(r, word[:]); n == 0 {
+		return 0, fmt.Errorf("reached end of input while fetching a new word: %w", io.ErrUnexpectedEOF)
+	}
+	return binary.LittleEndian.Uint32(word[:]), nil
+}
+
+// needBits ensures we have enough bits
+func needBits(stateData *State, bits uint8) {
+	if bits > 32 {
+		setStateError(stateData, fmt.Errorf("tried to need %d bits, more than 32", bits))
+		return
+	}
+
+	if stateData.Bits < uint32(bits) {
+		pullByte(stateData)
+	}
+}
+
+// dropBits drops a specified number of bits
+func dropBits(stateData *State, bits uint8) {
+	if bits > 32 {
+		setStateError(stateData, fmt.Errorf("tried to drop %d bits, more than 32", bits))
+		return
+	}
+
+	if uint32(bits) > stateData.Bits {
+		setStateError(stateData, fmt.Errorf("tried to drop %d bits with only %d available", bits, stateData.Bits))
+		return
+	}
+
+	if bits == 32 {
+		stateData.Head = stateData.Buffer
+		stateData.Buffer = 0
+	} else {
+		stateData.Head = (stateData.Head << bits) | (stateData.Buffer >> (32 - bits))
+		stateData.Buffer <<= bits
+	}
+
+	stateData.Bits -= uint32(bits)
+}
+
+// readBits returns the next bits bits of the input without consuming them.
+// Head is kept left-aligned: its most significant bit is the next unread bit
+// and Buffer holds the bits that follow, so the value is the top of Head.
+func readBits(state *State, bits uint8) uint32 {
+	if bits == 0 {
+		return 0
+	}
+	return (state.Head >> (32 - bits)) & uint32((uint64(1)<<bits)-1)
+}
+
+// readCode reads a code from the Huffman tree
+func readCode(huffmanTree *HuffmanTree, stateData *State, ioCode *uint16) error {
+	if huffmanTree.CompressedCodes[0] == 0 {
+		return errors.New("trying to read code from an empty HuffmanTree")
+	}
+
+	needBits(stateData, 32)
+	if stateData.Err != nil {
+		return stateData.Err
+	}
+	tempIndex := uint16(0)
+	bitsRead := readBits(stateData, 32)
+
+	// Populated entries, one per code length, have a non-zero BitsLength
+	for bitsRead < huffmanTree.CompressedCodes[tempIndex] {
+		tempIndex++
+		if tempIndex >= MAX_CODE_BITS_LENGTH || huffmanTree.BitsLength[tempIndex] == 0 {
+			return fmt.Errorf("no Huffman code matches bits %#08x", bitsRead)
+		}
+	}
+
+	tempBits := huffmanTree.BitsLength[tempIndex]
+	delta := (bitsRead - huffmanTree.CompressedCodes[tempIndex]) >> (32 - tempBits)
+	if delta > uint32(huffmanTree.SymbolValueOffset[tempIndex]) {
+		return fmt.Errorf("Huffman code %#x of length %d has no symbol", bitsRead>>(32-tempBits), tempBits)
+	}
+	*ioCode = huffmanTree.SymbolValues[huffmanTree.SymbolValueOffset[tempIndex]-uint16(delta)]
+	dropBits(stateData, tempBits)
+	return nil
+}
+
+// createHuffmanTree builds the Huffman tree
+func createHuffmanTree(ioHuffmanTree *HuffmanTree, ioWorkingBitTab *[MAX_CODE_BITS_LENGTH]int16, ioWorkingCodeTab *[MAX_SYMBOL_VALUE]int16) {
+	tempCode := uint32(0)
+	tempBits := uint8(0)
+	comparisonCodeIndex := uint16(0)
+	symbolOffset := uint16(0)
+
+	for tempBits < MAX_CODE_BITS_LENGTH {
+		if (*ioWorkingBitTab)[tempBits] != -1 {
+			tempSymbol := (*ioWorkingBitTab)[tempBits]
+			for tempSymbol != -1 {
+				// Registering the code
+				ioHuffmanTree.SymbolValues[symbolOffset] = uint16(tempSymbol)
+				symbolOffset++
+				tempSymbol = (*ioWorkingCodeTab)[tempSymbol]
+				tempCode-- // Decrement code value for next symbol
+			}
+
+			// Minimum code value for tempBits bits
+			ioHuffmanTree.CompressedCodes[comparisonCodeIndex] = (tempCode + 1) << (32 - tempBits)
+
+			// Number of bits for l_codeCompIndex index
+			ioHuffmanTree.BitsLength[comparisonCodeIndex] = tempBits
+
+			// Offset in symbol_values table to reach the value
+			ioHuffmanTree.SymbolValueOffset[comparisonCodeIndex] = symbolOffset - 1
+
+			comparisonCodeIndex++
+		}
+		tempCode = (tempCode << 1) + 1 // Increment code for next length
+		tempBits++
+	}
+
+	// Terminate the populated entries so a reused tree needs no clearing
+	if comparisonCodeIndex < MAX_SYMBOL_VALUE {
+		ioHuffmanTree.CompressedCodes[comparisonCodeIndex] = 0
+		ioHuffmanTree.BitsLength[comparisonCodeIndex] = 0
+	}
+}
+
+// fillTabsHelper updates the working bit and code tables based on the provided bits and symbol.
+// It is only given the hard-coded dictionary tables, never stream data, so
+// out of range values are a bug in those tables and panic.
+func fillTabsHelper(bits uint8, symbol int16, ioWorkingBitTab *[MAX_CODE_BITS_LENGTH]int16, ioWorkingCodeTab *[MAX_SYMBOL_VALUE]int16) {
+	// Check for out of bounds
+	if bits >= MAX_CODE_BITS_LENGTH {
+		panic(fmt.Sprintf("dictionary code length %d out of range", bits))
+	}
+
+	if symbol < 0 || symbol >= MAX_SYMBOL_VALUE {
+		panic(fmt.Sprintf("dictionary symbol %d out of range", symbol))
+	}
+
+	if (*ioWorkingBitTab)[bits] == -1 {
+		(*ioWorkingBitTab)[bits] = symbol
+	} else {
+		(*ioWorkingCodeTab)[symbol] = (*ioWorkingBitTab)[bits]
+		(*ioWorkingBitTab)[bits] = symbol
+	}
+}
+
+// huffmanTreeDictBits and huffmanTreeDictSymbols define the dictionary tree
+// the per-block Huffman trees of a stream are encoded with: the code length of
+// each symbol, shortest first, as hard-coded in the GW2 client. Together they
+// form a complete prefix code over the 256 symbols of a tree descriptor, see
+// validateHuffmanTreeDictTables.
+var huffmanTreeDictBits = []uint8{
+	3, 3, 3, 4, 4, 4, 4, 5, 5, 5, 5, 6, 6, 6, 6, 6, 6, 6, 6, 7, 7, 7, 7, 7, 7, 7, 8, 8, 8, 8,
+	8, 8, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 10, 10, 10, 10, 10, 10, 10, 10, 10, 10, 10, 10, 10, 10,
+	10, 10, 11, 11, 11, 11, 11, 11, 11, 11, 11, 11, 11, 11, 11, 12, 12, 12, 12, 12, 12, 12, 13,
+	13, 13, 13, 13, 13, 14, 14, 14, 14, 15, 15, 15, 15, 15, 15, 15, 15, 16, 16, 16, 16, 16, 16,
+	16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16,
+	16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16,
+	16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16,
+	16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16,
+	16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16,
+	16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16,
+	16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16, 16,
+}
+
+var huffmanTreeDictSymbols = []int16{
+	0x0A, 0x09, 0x08, 0x0C, 0x0B, 0x07, 0x00, 0xE0, 0x2A, 0x29, 0x06, 0x4A, 0x40, 0x2C, 0x2B,
+	0x28, 0x20, 0x05, 0x04, 0x49, 0x48, 0x27, 0x26, 0x25, 0x0D, 0x03, 0x6A, 0x69, 0x4C, 0x4B,
+	0x47, 0x24, 0xE8, 0xA0, 0x89, 0x88, 0x68, 0x67, 0x63, 0x60, 0x46, 0x23, 0xE9, 0xC9, 0xC0,
+	0xA9, 0xA8, 0x8A, 0x87, 0x80, 0x66, 0x65, 0x45, 0x44, 0x43, 0x2D, 0x02, 0x01, 0xE5, 0xC8,
+	0xAA, 0xA5, 0xA4, 0x8B, 0x85, 0x84, 0x6C, 0x6B, 0x64, 0x4D, 0x0E, 0xE7, 0xCA, 0xC7, 0xA7,
+	0xA6, 0x86, 0x83, 0xE6, 0xE4, 0xC4, 0x8C, 0x2E, 0x22, 0xEC, 0xC6, 0x6D, 0x4E, 0xEA, 0xCC,
+	0xAC, 0xAB, 0x8D, 0x11, 0x10, 0x0F, 0xFF, 0xFE, 0xFD, 0xFC, 0xFB, 0xFA, 0xF9, 0xF8, 0xF7,
+	0xF6, 0xF5, 0xF4, 0xF3, 0xF2, 0xF1, 0xF0, 0xEF, 0xEE, 0xED, 0xEB, 0xE3, 0xE2, 0xE1, 0xDF,
+	0xDE, 0xDD, 0xDC, 0xDB, 0xDA, 0xD9, 0xD8, 0xD7, 0xD6, 0xD5, 0xD4, 0xD3, 0xD2, 0xD1, 0xD0,
+	0xCF, 0xCE, 0xCD, 0xCB, 0xC5, 0xC3, 0xC2, 0xC1, 0xBF, 0xBE, 0xBD, 0xBC, 0xBB, 0xBA, 0xB9,
+	0xB8, 0xB7, 0xB6, 0xB5, 0xB4, 0xB3, 0xB2, 0xB1, 0xB0, 0xAF, 0xAE, 0xAD, 0xA3, 0xA2, 0xA1,
+	0x9F, 0x9E, 0x9D, 0x9C, 0x9B, 0x9A, 0x99, 0x98, 0x97, 0x96, 0x95, 0x94, 0x93, 0x92, 0x91,
+	0x90, 0x8F, 0x8E, 0x82, 0x81, 0x7F, 0x7E, 0x7D, 0x7C, 0x7B, 0x7A, 0x79, 0x78, 0x77, 0x76,
+	0x75, 0x74, 0x73, 0x72, 0x71, 0x70, 0x6F, 0x6E, 0x62, 0x61, 0x5F, 0x5E, 0x5D, 0x5C, 0x5B,
+	0x5A, 0x59, 0x58, 0x57, 0x56, 0x55, 0x54, 0x53, 0x52, 0x51, 0x50, 0x4F, 0x42, 0x41, 0x3F,
+	0x3E, 0x3D, 0x3C, 0x3B, 0x3A, 0x39, 0x38, 0x37, 0x36, 0x35, 0x34, 0x33, 0x32, 0x31, 0x30,
+	0x2F, 0x21, 0x1F, 0x1E, 0x1D, 0x1C, 0x1B, 0x1A, 0x19, 0x18, 0x17, 0x16, 0x15, 0x14, 0x13,
+	0x12,
+}
+
+func init() {
+	if err := validateHuffmanTreeDictTables(huffmanTreeDictBits, huffmanTreeDictSymbols); err != nil {
+		panic("dat: invalid Huffman dictionary tables: " + err.Error())
+	}
+}
+
+// validateHuffmanTreeDictTables checks that bits and symbols describe a
+// complete prefix code that fillTabsHelper and createHuffmanTree can build:
+// one length per symbol, lengths in [1, MAX_CODE_BITS_LENGTH) and in
+// ascending order, symbols in [0, MAX_SYMBOL_VALUE) without repeats, and the
+// code space exactly filled.
+func validateHuffmanTreeDictTables(bits []uint8, symbols []int16) error {
+	if len(bits) != len(symbols) {
+		return fmt.Errorf("%d code lengths for %d symbols", len(bits), len(symbols))
+	}
+
+	var seen [MAX_SYMBOL_VALUE]bool
+	var space uint64 // Code space used, in units of 2^-(MAX_CODE_BITS_LENGTH-1)
+	for i, symbol := range symbols {
+		if bits[i] == 0 || bits[i] >= MAX_CODE_BITS_LENGTH {
+			return fmt.Errorf("symbol %#x has code length %d", symbol, bits[i])
+		}
+		if i > 0 && bits[i] < bits[i-1] {
+			return fmt.Errorf("code length %d of symbol %#x is shorter than the previous one", bits[i], symbol)
+		}
+		if symbol < 0 || symbol >= MAX_SYMBOL_VALUE {
+			return fmt.Errorf("symbol %#x out of range", symbol)
+		}
+		if seen[symbol] {
+			return fmt.Errorf("symbol %#x listed twice", symbol)
+		}
+		seen[symbol] = true
+		space += 1 << (MAX_CODE_BITS_LENGTH - 1 - bits[i])
+	}
+	if space != 1<<(MAX_CODE_BITS_LENGTH-1) {
+		return fmt.Errorf("code lengths fill %d/%d of the code space", space, uint64(1)<<(MAX_CODE_BITS_LENGTH-1))
+	}
+	return nil
+}
+
+func newHuffmanTreeDict() *HuffmanTree {
+	var workingBits [MAX_CODE_BITS_LENGTH]int16
+	var workingCode [MAX_SYMBOL_VALUE]int16
+
+	// Initialize working tables
+	for i := range workingBits {
+		workingBits[i] = -1 // Use -1 to indicate uninitialized
+	}
+	for i := range workingCode {
+		workingCode[i] = -1 // Use -1 to indicate uninitialized
+	}
+
+	// Populate the working tables
+	for i := range huffmanTreeDictBits {
+		fillTabsHelper(huffmanTreeDictBits[i], huffmanTreeDictSymbols[i], &workingBits, &workingCode)
+	}
+
+	// Build the Huffman tree
+	huffmanTree := &HuffmanTree{}
+	createHuffmanTree(huffmanTree, &workingBits, &workingCode)
+	return huffmanTree
+}
+
+// prepareHuffmanTreeDict builds the shared dictionary tree if needed and
+// checks that it is usable.
+func prepareHuffmanTreeDict() error {
+	if huffmanTreeDict().CompressedCodes[0] == 0 {
+		return errors.New("huffman tree empty")
+	}
+	return nil
+}
+
+// Function to parse the Huffman tree
+func parseHuffmanTree(stateData *State, dict *HuffmanTree, ioHuffmanTree *HuffmanTree) error {
+	// Reading the number of symbols to read
+	needBits(stateData, 16)
+	numberSymbolData := uint16(readBits(stateData, 16)) // C-style cast equivalent
+	dropBits(stateData, 16)
+
+	if numberSymbolData > MAX_SYMBOL_VALUE {
+		return fmt.Errorf("too many symbols to decode: %d", numberSymbolData)
+	}
+
+	var workingBits [MAX_CODE_BITS_LENGTH]int16
+	var workingCode [MAX_SYMBOL_VALUE]int16
+
+	// Initialize our workingBits and workingCode
+	for i := range workingBits {
+		workingBits[i] = -1 // Using -1 to indicate uninitialized
+	}
+	for i := range workingCode {
+		workingCode[i] = -1 // Using -1 to indicate uninitialized
+	}
+	var numberSymbol = int16(numberSymbolData)
+	remainingSymbol := numberSymbol - 1
+
+	// Fetching the code repartition
+	for remainingSymbol >= 0 {
+		var tempCode uint16
+		if err := readCode(dict, stateData, &tempCode); err != nil {
+			return err
+		}
+
+		codeNumberBits := tempCode & 0x1F
+		codeNumberSymbol := int16((tempCode >> 5) + 1)
+
+		if codeNumberBits == 0 {
+			remainingSymbol -= codeNumberSymbol
+		} else {
+			for codeNumberSymbol > 0 {
+				// A corrupt repartition can assign more symbols than announced
+				if remainingSymbol < 0 {
+					return fmt.Errorf("Huffman tree assigns more than %d symbols", numberSymbol)
+				}
+				if workingBits[codeNumberBits] == -1 {
+					workingBits[codeNumberBits] = int16(remainingSymbol)
+				} else {
+					workingCode[remainingSymbol] = workingBits[codeNumberBits]
+					workingBits[codeNumberBits] = int16(remainingSymbol)
+				}
+				remainingSymbol--
+				codeNumberSymbol--
+			}
+		}
+	}
+
+	// Effectively build the Huffman tree
+	createHuffmanTree(ioHuffmanTree, &workingBits, &workingCode)
+	return nil
+}
+
+// inflater keeps the decoding state of a compressed stream between calls so
+// output can be produced piecewise instead of in a single pass.
+type inflater struct {
+	ctx                       context.Context          // Checked every BlockSize bytes of output when set
+	onProgress                func(done, total uint32) // Called every BlockSize bytes of output when set
+	total                     uint32                   // Size of the whole output, reported to onProgress
+	stateData                 *State
+	dict                      *HuffmanTree // Read-only tree the block trees are encoded with
+	writeSizeConstantAddition uint32
+	huffmanTreeSymbol         HuffmanTree
+	huffmanTreeCopy           HuffmanTree
+	remainingCodes            uint32 // Codes left to read in the current block
+	copyRemaining             uint32 // Bytes left to copy for the pending back-reference
+	copyOffset                uint32 // Distance of the pending back-reference
+	stopAfterBlocks           int    // Blocks to decode before stopping early, all when 0
+	blocks                    int    // Blocks started so far
+}
+
+// newInflater reads the stream parameters that precede the first block.
+func newInflater(stateData *State) *inflater {
+	f := &inflater{}
+	f.reset(stateData)
+	return f
+}
+
+// reset prepares f to decode a new stream, reading the stream parameters that
+// precede the first block. The tree storage of f is reused.
+//
+// The parameters are the 8 bits following the size word, which with the
+// most-significant-first bit order are the top byte of the third input word,
+// byte 11 of the buffer. Its high nibble is not used by the decoder. Its low
+// nibble is the write size constant addition less one: the minimum length of a
+// back-reference, added to every decoded copy length, so it is in [1, 16].
+// Exactly 8 bits are consumed, leaving the stream aligned on the first block.
+func (f *inflater) reset(stateData *State) {
+	needBits(stateData, 8)
+	dropBits(stateData, 4) // Unused high nibble
+	writeSizeConstantAddition := (readBits(stateData, 4) + 1)
+	dropBits(stateData, 4)
+
+	*f = inflater{
+		stateData:                 stateData,
+		dict:                      huffmanTreeDict(),
+		writeSizeConstantAddition: writeSizeConstantAddition,
+	}
+}
+
+// inflate decodes into outputBuffer from tempOutputPosition up to limit and
+// returns the new position, which is short of limit only when stopAfterBlocks
+// blocks have been decoded. outputBuffer[:tempOutputPosition] must hold the
+// previously produced output that back-references may point into. Errors in
+// the stream wrap ErrCorruptStream; cancellation returns ctx.Err() as is.
+func (f *inflater) inflate(outputBuffer []uint8, tempOutputPosition, limit uint32) (uint32, error) {
+	position, err := f.decode(outputBuffer, tempOutputPosition, limit)
+	if err != nil && (f.ctx == nil || err != f.ctx.Err()) {
+		err = corruptStream(err)
+	}
+	return position, err
+}
+
+// decode is inflate without the wrapping of stream errors.
+func (f *inflater) decode(outputBuffer []uint8, tempOutputPosition, limit uint32) (uint32, error) {
+	stateData := f.stateData
+	nextBlockCheck := tempOutputPosition
+	checkBlocks := f.ctx != nil || f.onProgress != nil
+
+	for tempOutputPosition < limit {
+		if checkBlocks && tempOutputPosition >= nextBlockCheck {
+			if f.ctx != nil {
+				if err := f.ctx.Err(); err != nil {
+					return tempOutputPosition, err
+				}
+			}
+			if f.onProgress != nil {
+				f.onProgress(tempOutputPosition, f.total)
+			}
+			nextBlockCheck = tempOutputPosition + BlockSize
+		}
+
+		// Finishing a back-reference interrupted by the previous limit
+		if f.copyRemaining > 0 {
+			for f.copyRemaining > 0 && tempOutputPosition < limit {
+				outputBuffer[tempOutputPosition] = outputBuffer[tempOutputPosition-f.copyOffset]
+				tempOutputPosition++
+				f.copyRemaining--
+			}
+			continue
+		}
+
+		if f.remainingCodes == 0 {
+			if f.stopAfterBlocks != 0 && f.blocks == f.stopAfterBlocks {
+				break
+			}
+
+			// Reading Huffman Trees, overwriting those of the previous block
+			if err := parseHuffmanTree(stateData, f.dict, &f.huffmanTreeSymbol); err != nil {
+				return tempOutputPosition, err
+			}
+			if err := parseHuffmanTree(stateData, f.dict, &f.huffmanTreeCopy); err != nil {
+				return tempOutputPosition, err
+			}
+
+			// Reading MaxCount
+			needBits(stateData, 4)
+			f.remainingCodes = (readBits(stateData, 4) + 1) << 12
+			dropBits(stateData, 4)
+			f.blocks++
+		}
+		f.remainingCodes--
+
+		// Reading next code
+		var tempCode uint16
+		if err := readCode(&f.huffmanTreeSymbol, stateData, &tempCode); err != nil {
+			return tempOutputPosition, err
+		}
+
+		if tempCode < 0x100 {
+			outputBuffer[tempOutputPosition] = uint8(tempCode) // Cast to uint8
+			tempOutputPosition++
+			continue
+		}
+
+		// We are in copy mode!
+		// Reading the additional info to know the write size
+		tempCode -= 0x100
+
+		// Write size
+		codeDivision4 := tempCode / 4
+		rem := tempCode % 4
+
+		var writeSize uint32
+		switch {
+		case codeDivision4 == 0:
+			writeSize = uint32(tempCode)
+		case codeDivision4 < 7:
+			writeSize = uint32((1 << (codeDivision4 - 1)) * (4 + rem))
+		case tempCode == 28:
+			// The longest copy has a code of its own, with no additional bits
+			writeSize = 0xFF
+		default:
+			return tempOutputPosition, fmt.Errorf("invalid write size code %d", tempCode)
+		}
+
+		// Additional bits
+		if codeDivision4 > 1 && tempCode != 28 {
+			writeSizeAddition := codeDivision4 - 1
+			needBits(stateData, uint8(writeSizeAddition))
+			writeSize |= readBits(stateData, uint8(writeSizeAddition))
+			dropBits(stateData, uint8(writeSizeAddition))
+		}
+		writeSize += f.writeSizeConstantAddition
+
+		// Write offset
+		// Reading the write offset
+		if err := readCode(&f.huffmanTreeCopy, stateData, &tempCode); err != nil {
+			return tempOutputPosition, err
+		}
+
+		codeDivision2 := tempCode / 2
+
+		var writeOffset uint32
+		switch {
+		case codeDivision2 == 0:
+			writeOffset = uint32(tempCode)
+		case codeDivision2 < 17:
+			// Computed in uint32: the largest bases do not fit in a uint16
+			writeOffset = uint32(1<<(codeDivision2-1)) * uint32(2+(tempCode%2))
+		default:
+			return tempOutputPosition, fmt.Errorf("invalid write offset code %d", tempCode)
+		}
+
+		// Additional bits
+		if codeDivision2 > 1 {
+			writeOffsetAdditionBits := codeDivision2 - 1
+			needBits(stateData, uint8(writeOffsetAdditionBits))
+			writeOffset |= readBits(stateData, uint8(writeOffsetAdditionBits))
+			dropBits(stateData, uint8(writeOffsetAdditionBits))
+		}
+		writeOffset += 1
+
+		// A corrupt stream can point before the start of the output
+		if writeOffset > tempOutputPosition {
+			return tempOutputPosition, fmt.Errorf("back-reference offset %d exceeds output position %d", writeOffset, tempOutputPosition)
+		}
+
+		f.copyRemaining = writeSize
+		f.copyOffset = writeOffset
+	}
+
+	// The last codes may have been decoded from missing input
+	if stateData.Err != nil {
+		return tempOutputPosition, stateData.Err
+	}
+	return tempOutputPosition, nil
+}
+
+// inflateData decodes the whole stream into outputBuffer, checking ctx and
+// reporting to onProgress, when set, every BlockSize bytes of output. The last
+// report has done equal to outputBufferSize.
+func inflateData(ctx context.Context, stateData *State, outputBuffer *[]uint8, outputBufferSize uint32, onProgress func(done, total uint32)) error {
+	f := newInflater(stateData)
+	f.ctx = ctx
+	f.onProgress, f.total = onProgress, outputBufferSize
+	if _, err := f.inflate(*outputBuffer, 0, outputBufferSize); err != nil {
+		return err
+	}
+	if onProgress != nil {
+		onProgress(outputBufferSize, outputBufferSize)
+	}
+	return nil
+}
+
+// inflateToWriter decodes the stream to w in BlockSize chunks, keeping only a
+// sliding window of history instead of the whole decompressed output.
+func inflateToWriter(stateData *State, w io.Writer, outputBufferSize uint32) error {
+	r := newWindowReader(stateData, outputBufferSize)
+	chunk := make([]uint8, BlockSize)
+	for {
+		n, err := io.ReadFull(r, chunk)
+		if n > 0 {
+			written, err := w.Write(chunk[:n])
+			if err != nil {
+				return err
+			}
+			if written != n {
+				return io.ErrShortWrite
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Convert uint8 buffer to uint32 buffer. A trailing partial word is
+// zero-padded; the decompressed length is read from the stream header, so the
+// padding never shows up in the output.
+func convertU8ToU32(input []uint8) []uint32 {
+	return convertU8ToU32Into(nil, input)
+}
+
+// convertU8ToU32Into is convertU8ToU32 reusing the capacity of dst.
+func convertU8ToU32Into(dst []uint32, input []uint8) []uint32 {
+	words := (len(input) + 3) / 4
+	if cap(dst) < words {
+		dst = make([]uint32, words)
+	}
+	output := dst[:words]
+	clear(output)
+
+	for i, value := range input {
+		output[i/4] |= uint32(value) << (8 * (i % 4)) // Little-endian conversion
+	}
+
+	return output
+}
+
+// DecompressedSize returns the decompressed size recorded in the header of a
+// GW2-compressed buffer, without allocating or inflating anything.
+func DecompressedSize(inputBuffer []uint8) (uint32, error) {
+	// A skipped word, then the size, both little-endian words
+	if len(inputBuffer) < 8 {
+		return 0, corruptStream(fmt.Errorf("header truncated: %d bytes", len(inputBuffer)))
+	}
+	return binary.LittleEndian.Uint32(inputBuffer[4:8]), nil
+}
+
+// InflateBuffer decompresses a GW2-compressed buffer. The input size is taken
+// from len(inputBuffer). A non-zero *outputBufferSize caps the decompressed
+// size and receives the size read from the stream; a nil outputBufferSize
+// sets no cap and receives nothing. customOutputBufferSize overrides the
+// allocation. The returned slice always has the length actually decoded, so a
+// larger custom allocation only shows up in its capacity.
+func InflateBuffer(inputBuffer []uint8, outputBufferSize *uint32, customOutputBufferSize uint32) ([]uint8, error) {
+	return inflateBufferContext(context.Background(), inputBuffer, outputBufferSize, customOutputBufferSize, DefaultMaxDecompressedSize, BlockSize, nil)
+}
+
+// inflateBufferContext is InflateBuffer for a stream cut into blocks of
+// blockWords words, refusing allocations above maxSize, aborting with
+// ctx.Err() once ctx is done and reporting progress to onProgress when it is
+// set.
+func inflateBufferContext(ctx context.Context, inputBuffer []uint8, outputBufferSize *uint32, customOutputBufferSize, maxSize, blockWords uint32, onProgress func(done, total uint32)) ([]uint8, error) {
+	if inputBuffer == nil {
+		return nil, errors.New("input buffer is null")
+	}
+
+	stateData, tempOutputBufferSize, err := openStream(inputBuffer, blockWords)
+	if err != nil {
+		return nil, err
+	}
+	if outputBufferSize != nil {
+		// We do not take max here as we won't be able to have more than the output available
+		if *outputBufferSize != 0 && tempOutputBufferSize > *outputBufferSize {
+			tempOutputBufferSize = *outputBufferSize
+		}
+		*outputBufferSize = tempOutputBufferSize
+	}
+
+	// Never decode past the real end of the stream, whatever the allocation
+	allocationSize := tempOutputBufferSize
+	if customOutputBufferSize > 0 {
+		allocationSize = customOutputBufferSize
+		tempOutputBufferSize = min(tempOutputBufferSize, customOutputBufferSize)
+	}
+
+	if err := checkDecompressedSize(allocationSize, maxSize); err != nil {
+		return nil, err
+	}
+
+	// Allocate memory for output buffer
+	outputBuffer := make([]uint8, allocationSize)
+
+	// Inflate data
+	if err := inflateData(ctx, stateData, &outputBuffer, tempOutputBufferSize, onProgress); err != nil {
+		return nil, err
+	}
+
+	return outputBuffer[:tempOutputBufferSize], nil
+}
+
+// openStream prepares a State over inputBuffer, a stream cut into blocks of
+// blockWords words, and reads the stream header, returning the State
+// positioned after it and the decompressed size.
+func openStream(inputBuffer []uint8, blockWords uint32) (*State, uint32, error) {
+	if err := prepareHuffmanTreeDict(); err != nil {
+		return nil, 0, err
+	}
+
+	// Convert uint8 buffer to uint32 buffer
+	return startStream(convertU8ToU32(inputBuffer), blockWords)
+}
+
+// startStream is openStream over input already converted to words.
+func startStream(u32InputBuffer []uint32, blockWords uint32) (*State, uint32, error) {
+	// Initialize state
+	stateData, err := newState(u32InputBuffer)
+	if err != nil {
+		return nil, 0, err
+	}
+	stateData.BlockWords = blockWords
+	outputBufferSize, err := readStreamHeader(stateData)
+	if err != nil {
+		return nil, 0, err
+	}
+	return stateData, outputBufferSize, nil
+}
+
+// readStreamHeader reads the stream header from a State positioned at the
+// start of a stream and returns the decompressed size.
+func readStreamHeader(stateData *State) (uint32, error) {
+	// Skipping header & getting size of the uncompressed data
+	needBits(stateData, 32)
+	dropBits(stateData, 32)
+
+	// Getting size of the uncompressed data
+	needBits(stateData, 32)
+	outputBufferSize := readBits(stateData, 32)
+	dropBits(stateData, 32)
+	if stateData.Err != nil {
+		return 0, corruptStream(stateData.Err)
+	}
+	return outputBufferSize, nil
+}
+
+// InflateInto decompresses a GW2-compressed buffer into dst, reusing its
+// capacity, and returns the slice of dst holding the output. A new slice is
+// allocated only when dst is too small, so callers recycling buffers should
+// keep the returned one.
+func InflateInto(dst []uint8, inputBuffer []uint8) ([]uint8, error) {
+	stateData, outputBufferSize, err := openStream(inputBuffer, BlockSize)
+	if err != nil {
+		return nil, err
+	}
+
+	if uint64(cap(dst)) < uint64(outputBufferSize) {
+		if err := checkDecompressedSize(outputBufferSize, DefaultMaxDecompressedSize); err != nil {
+			return nil, err
+		}
+		dst = make([]uint8, outputBufferSize)
+	}
+	dst = dst[:outputBufferSize]
+
+	if err := inflateData(context.Background(), stateData, &dst, outputBufferSize, nil); err != nil {
+		return nil, err
+	}
+	return dst, nil
+}
+
+// ProbeInflate decodes a GW2-compressed buffer without keeping the output and
+// returns the first error in the stream, or nil when it decodes to the size
+// in its header. Only a sliding window of history is allocated, whatever the
+// decompressed size.
+func ProbeInflate(inputBuffer []uint8) error {
+	return probeInflate(inputBuffer, BlockSize)
+}
+
+// probeInflate is ProbeInflate for a stream cut into blocks of blockWords
+// words.
+func probeInflate(inputBuffer []uint8, blockWords uint32) error {
+	if inputBuffer == nil {
+		return errors.New("input buffer is null")
+	}
+
+	stateData, outputBufferSize, err := openStream(inputBuffer, blockWords)
+	if err != nil {
+		return err
+	}
+	return inflateToWriter(stateData, io.Discard, outputBufferSize)
+}
+
+// maxBlockOutput bounds the output of one code block: 16 << 12 codes, each
+// copying at most 0xFF bytes plus the largest constant write size addition.
+const maxBlockOutput = (16 << 12) * (0xFF + 16)
+
+// InflateBlocks decompresses only the first stopAfterBlocks code blocks of a
+// GW2-compressed buffer, or the whole stream when it is 0, and returns the
+// output they produce. Sniffing the format of an entry needs only the start
+// of the first block, however large the entry is.
+func InflateBlocks(inputBuffer []uint8, stopAfterBlocks int) ([]uint8, error) {
+	return inflateBlocks(inputBuffer, 0, DefaultMaxDecompressedSize, BlockSize, stopAfterBlocks)
+}
+
+// inflateBlocks is InflateBlocks for a stream cut into blocks of blockWords
+// words, also stopping after limit bytes when limit is non-zero, and refusing
+// output larger than maxSize.
+func inflateBlocks(inputBuffer []uint8, limit, maxSize, blockWords uint32, stopAfterBlocks int) ([]uint8, error) {
+	if stopAfterBlocks < 0 {
+		return nil, fmt.Errorf("negative block count %d", stopAfterBlocks)
+	}
+
+	stateData, outputBufferSize, err := openStream(inputBuffer, blockWords)
+	if err != nil {
+		return nil, err
+	}
+	if limit != 0 {
+		outputBufferSize = min(outputBufferSize, limit)
+	}
+	if stopAfterBlocks != 0 && uint64(outputBufferSize) > uint64(stopAfterBlocks)*maxBlockOutput {
+		outputBufferSize = uint32(uint64(stopAfterBlocks) * maxBlockOutput)
+	}
+	if err := checkDecompressedSize(outputBufferSize, maxSize); err != nil {
+		return nil, err
+	}
+
+	outputBuffer := make([]uint8, outputBufferSize)
+	f := newInflater(stateData)
+	f.stopAfterBlocks = stopAfterBlocks
+	n, err := f.inflate(outputBuffer, 0, outputBufferSize)
+	if err != nil {
+		return nil, err
+	}
+	return outputBuffer[:n], nil
+}
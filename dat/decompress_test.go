@@ -0,0 +1,1017 @@
+package dat
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testPayloadSizes covers an empty stream, streams within one 64 KiB block of
+// input and streams whose compressed form spans several blocks.
+var testPayloadSizes = []int{0, 1, 100, 5000, 70000, 400000}
+
+func TestInflateBufferRoundTrip(t *testing.T) {
+	for _, size := range testPayloadSizes {
+		data := testPayload(size)
+		compressed := testDeflate(t, data)
+
+		var outputBufferSize uint32
+		got, err := InflateBuffer(compressed, &outputBufferSize, 0)
+		if err != nil {
+			t.Fatalf("size %d: InflateBuffer: %v", size, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("size %d: InflateBuffer returned %d bytes not matching the input", size, len(got))
+		}
+		if outputBufferSize != uint32(size) {
+			t.Errorf("size %d: outputBufferSize = %d", size, outputBufferSize)
+		}
+	}
+}
+
+func TestInflateBufferCap(t *testing.T) {
+	data := testPayload(5000)
+	compressed := testDeflate(t, data)
+
+	tests := []struct {
+		name             string
+		outputBufferSize uint32
+		custom           uint32
+		want             int
+	}{
+		{"no cap", 0, 0, 5000},
+		{"capped", 1000, 0, 1000},
+		{"cap above size", 9000, 0, 5000},
+		{"larger allocation", 0, 8000, 5000},
+		{"smaller allocation", 0, 300, 300},
+	}
+	for _, test := range tests {
+		outputBufferSize := test.outputBufferSize
+		got, err := InflateBuffer(compressed, &outputBufferSize, test.custom)
+		if err != nil {
+			t.Fatalf("%s: InflateBuffer: %v", test.name, err)
+		}
+		if !bytes.Equal(got, data[:test.want]) {
+			t.Errorf("%s: got %d bytes, want the first %d of the input", test.name, len(got), test.want)
+		}
+	}
+}
+
+func TestInflateBufferCorrupt(t *testing.T) {
+	compressed := testDeflate(t, testPayload(5000))
+	flipped := bytes.Clone(compressed)
+	for i := 12; i < len(flipped); i += 7 {
+		flipped[i] ^= 0x5A
+	}
+
+	tests := []struct {
+		name  string
+		input []byte
+	}{
+		{"nil", nil},
+		{"empty", []byte{}},
+		{"header only", compressed[:6]},
+		{"truncated", compressed[:len(compressed)/2]},
+		{"flipped bytes", flipped},
+	}
+	for _, test := range tests {
+		var outputBufferSize uint32
+		if _, err := InflateBuffer(test.input, &outputBufferSize, 0); err == nil {
+			t.Errorf("%s: InflateBuffer succeeded", test.name)
+		}
+	}
+}
+
+func TestDecompressedSize(t *testing.T) {
+	compressed := testDeflate(t, testPayload(1234))
+	size, err := DecompressedSize(compressed)
+	if err != nil || size != 1234 {
+		t.Errorf("DecompressedSize = %d, %v; want 1234", size, err)
+	}
+	if _, err := DecompressedSize(compressed[:7]); err == nil {
+		t.Error("DecompressedSize of a 7 byte header succeeded")
+	}
+}
+
+// benchmarkPayloadSizes are the decompressed sizes the decoding benchmarks
+// run at, from one block of input to several.
+var benchmarkPayloadSizes = []int{5000, 70000, 400000, 4 << 20}
+
+func BenchmarkInflateBuffer(b *testing.B) {
+	for _, size := range benchmarkPayloadSizes {
+		compressed := testDeflate(b, testPayload(size))
+		b.Run(fmt.Sprintf("%dB", size), func(b *testing.B) {
+			b.SetBytes(int64(size))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				var outputBufferSize uint32
+				if _, err := InflateBuffer(compressed, &outputBufferSize, 0); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// testSmallBlocks returns a stream of blocks as short as the format allows,
+// 4096 literals each under trees of all 256 byte values, and the bytes it
+// holds. Per-block work, parsing and setting up the trees, weighs far more
+// in it than in the streams Deflate writes.
+func testSmallBlocks(blocks int) ([]byte, []byte) {
+	const codes = 1 << 12 // The fewest codes a block can hold
+	random := rand.New(rand.NewSource(31))
+	data := make([]byte, blocks*codes)
+	random.Read(data)
+
+	w := &bitWriter{}
+	w.writeBits(0, 32)
+	w.writeBits(uint32(len(data)), 32)
+	w.writeBits(0, 4)
+	w.writeBits(deflateMinMatch-1, 4)
+	for start := 0; start < len(data); start += codes {
+		block := data[start : start+codes]
+		frequencies := make([]uint32, 0x100)
+		for _, value := range block {
+			frequencies[value]++
+		}
+		symbolLengths := huffmanLengths(frequencies)
+		copyLengths := huffmanLengths([]uint32{1})
+		writeTreeDescriptor(w, symbolLengths)
+		writeTreeDescriptor(w, copyLengths)
+		w.writeBits(codes>>12-1, 4)
+
+		symbolCodes := huffmanCodes(huffmanTreeFromLengths(symbolLengths))
+		for _, value := range block {
+			w.writeBits(symbolCodes[value].code, symbolCodes[value].bits)
+		}
+	}
+	return w.bytes(), data
+}
+
+// BenchmarkInflateSmallBlocks measures the per-block cost of inflating,
+// which is dominated by rebuilding the symbol and copy trees.
+func BenchmarkInflateSmallBlocks(b *testing.B) {
+	compressed, data := testSmallBlocks(64)
+	if got, err := InflateBuffer(compressed, nil, 0); err != nil || !bytes.Equal(got, data) {
+		b.Fatalf("InflateBuffer returned %d bytes, %v", len(got), err)
+	}
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := InflateBuffer(compressed, nil, 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*64), "ns/block")
+}
+
+// BenchmarkParseHuffmanTree parses a tree descriptor of all 256 byte values
+// into a tree reused from the previous block, as inflate does, and into one
+// zeroed first, as inflate once did for every block.
+func BenchmarkParseHuffmanTree(b *testing.B) {
+	dict := huffmanTreeDict()
+	w := &bitWriter{}
+	writeTreeDescriptor(w, huffmanLengths(testFrequencies(0x100)))
+	input := convertU8ToU32(w.bytes())
+
+	for _, zeroed := range []bool{false, true} {
+		name := "reused"
+		if zeroed {
+			name = "zeroed"
+		}
+		b.Run(name, func(b *testing.B) {
+			tree := &HuffmanTree{}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				stateData, err := newState(input)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if zeroed {
+					*tree = HuffmanTree{}
+				}
+				if err := parseHuffmanTree(stateData, dict, tree); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkReadCode decodes a stream of random codes of the dictionary tree,
+// the tree every per-block Huffman tree descriptor is read with.
+func BenchmarkReadCode(b *testing.B) {
+	const count = 4096
+	dict := huffmanTreeDict()
+	codes := huffmanTreeDictCodes()
+	random := rand.New(rand.NewSource(1))
+	w := &bitWriter{}
+	for i := 0; i < count; i++ {
+		code := codes[random.Intn(256)]
+		w.writeBits(code.code, code.bits)
+	}
+	input := convertU8ToU32(w.bytes())
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		stateData, err := newState(input)
+		if err != nil {
+			b.Fatal(err)
+		}
+		var symbol uint16
+		for j := 0; j < count; j++ {
+			if err := readCode(dict, stateData, &symbol); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*count), "ns/code")
+}
+
+func TestMaxDecompressedSize(t *testing.T) {
+	// A valid stream whose header claims 4 GiB of output
+	bomb := testDeflate(t, testPayload(100))
+	binary.LittleEndian.PutUint32(bomb[4:], math.MaxUint32)
+
+	tests := []struct {
+		name    string
+		inflate func() ([]byte, error)
+	}{
+		{"InflateBuffer", func() ([]byte, error) {
+			var outputBufferSize uint32
+			return InflateBuffer(bomb, &outputBufferSize, 0)
+		}},
+		{"InflateBuffer with a custom allocation", func() ([]byte, error) {
+			outputBufferSize := uint32(50)
+			return InflateBuffer(bomb, &outputBufferSize, DefaultMaxDecompressedSize+1)
+		}},
+		{"InflateInto", func() ([]byte, error) { return InflateInto(nil, bomb) }},
+		{"InflateBlocks", func() ([]byte, error) { return InflateBlocks(bomb, 0) }},
+		{"Decoder", func() ([]byte, error) { return NewDecoder().Inflate(bomb) }},
+	}
+	for _, test := range tests {
+		if _, err := test.inflate(); err == nil || !strings.Contains(err.Error(), "byte limit") {
+			t.Errorf("%s returned %v, want the size limit error", test.name, err)
+		}
+	}
+
+	// Capping the output below the limit still decodes what is there
+	outputBufferSize := uint32(100)
+	if got, err := InflateBuffer(bomb, &outputBufferSize, 0); err != nil || !bytes.Equal(got, testPayload(100)) {
+		t.Errorf("InflateBuffer capped to the real size returned %d bytes, %v", len(got), err)
+	}
+}
+
+func TestOptionsMaxDecompressedSize(t *testing.T) {
+	spec := testDat{entries: testEntries}
+	large := uint32(firstTestBaseID + 2) // 200000 bytes
+
+	tests := []struct {
+		name    string
+		maxSize uint32
+		fails   bool
+	}{
+		{"default", 0, false},
+		{"below the entry", 100000, true},
+		{"exactly the entry", 200000, false},
+		{"no limit", math.MaxUint32, false},
+	}
+	for _, test := range tests {
+		datFile := spec.open(t, Options{MaxDecompressedSize: test.maxSize})
+		extractions := []struct {
+			name    string
+			extract func() ([]byte, error)
+		}{
+			{"Extract", func() ([]byte, error) { return datFile.Extract(large, false) }},
+			{"ExtractVerified", func() ([]byte, error) { return datFile.ExtractVerified(large, false) }},
+			{"ExtractMany", func() ([]byte, error) {
+				results, err := datFile.ExtractMany([]uint32{large}, false)
+				return results[large], err
+			}},
+		}
+		for _, extraction := range extractions {
+			data, err := extraction.extract()
+			switch {
+			case test.fails && (err == nil || !strings.Contains(err.Error(), "100000 byte limit")):
+				t.Errorf("%s: %s returned %v, want the size limit error", test.name, extraction.name, err)
+			case !test.fails && (err != nil || len(data) != 200000):
+				t.Errorf("%s: %s returned %d bytes, %v", test.name, extraction.name, len(data), err)
+			}
+		}
+
+		// Smaller entries are unaffected
+		if _, err := datFile.Extract(firstTestBaseID+1, false); err != nil {
+			t.Errorf("%s: Extract of a small entry: %v", test.name, err)
+		}
+	}
+}
+
+func TestInflateBufferNilSize(t *testing.T) {
+	data := testPayload(5000)
+	got, err := InflateBuffer(testDeflate(t, data), nil, 0)
+	if err != nil {
+		t.Fatalf("InflateBuffer with a nil size: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("InflateBuffer with a nil size returned %d bytes not matching the input", len(got))
+	}
+}
+
+func TestInflateInto(t *testing.T) {
+	tests := []struct {
+		name   string
+		dst    []byte
+		reused bool
+	}{
+		{"nil", nil, false},
+		{"too small", make([]byte, 0, 4999), false},
+		{"exact", make([]byte, 0, 5000), true},
+		{"larger, with old contents", bytes.Repeat([]byte{0xAA}, 8000), true},
+	}
+	data := testPayload(5000)
+	compressed := testDeflate(t, data)
+	for _, test := range tests {
+		got, err := InflateInto(test.dst, compressed)
+		if err != nil {
+			t.Fatalf("%s: InflateInto: %v", test.name, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("%s: InflateInto returned %d bytes not matching the input", test.name, len(got))
+		}
+		if reused := cap(test.dst) > 0 && &got[:1][0] == &test.dst[:1][0]; reused != test.reused {
+			t.Errorf("%s: InflateInto reused dst = %v, want %v", test.name, reused, test.reused)
+		}
+	}
+
+	if _, err := InflateInto(nil, compressed[:6]); !errors.Is(err, ErrCorruptStream) {
+		t.Errorf("InflateInto of a truncated header returned %v, want ErrCorruptStream", err)
+	}
+}
+
+// BenchmarkInflateInto decodes into one recycled buffer, next to
+// BenchmarkInflateBuffer allocating a new one for every call.
+func BenchmarkInflateInto(b *testing.B) {
+	for _, size := range benchmarkPayloadSizes {
+		compressed := testDeflate(b, testPayload(size))
+		b.Run(fmt.Sprintf("%dB", size), func(b *testing.B) {
+			b.SetBytes(int64(size))
+			b.ReportAllocs()
+			var dst []byte
+			for i := 0; i < b.N; i++ {
+				var err error
+				if dst, err = InflateInto(dst, compressed); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestInflateBlocks(t *testing.T) {
+	// Random bytes stay mostly literals, so each code block of
+	// deflateBlockCodes codes yields a little more bytes than it has codes
+	data := make([]byte, 3*deflateBlockCodes)
+	rand.New(rand.NewSource(1)).Read(data)
+	compressed := testDeflate(t, data)
+
+	tests := []struct {
+		name            string
+		input           []byte
+		stopAfterBlocks int
+		min, max        int // Bounds of the output length, inclusive
+	}{
+		{"whole stream", compressed, 0, len(data), len(data)},
+		{"one block", compressed, 1, deflateBlockCodes, deflateBlockCodes + 1000},
+		{"two blocks", compressed, 2, 2 * deflateBlockCodes, 2*deflateBlockCodes + 2000},
+		{"more blocks than the stream", compressed, 10, len(data), len(data)},
+		{"one block of a truncated stream", compressed[:len(compressed)/2], 1, deflateBlockCodes, deflateBlockCodes + 1000},
+	}
+	for _, test := range tests {
+		got, err := InflateBlocks(test.input, test.stopAfterBlocks)
+		if err != nil {
+			t.Errorf("%s: InflateBlocks: %v", test.name, err)
+			continue
+		}
+		if len(got) < test.min || len(got) > test.max {
+			t.Errorf("%s: InflateBlocks returned %d bytes, want [%d, %d]", test.name, len(got), test.min, test.max)
+		}
+		if !bytes.HasPrefix(data, got) {
+			t.Errorf("%s: InflateBlocks output is not a prefix of the data", test.name)
+		}
+	}
+
+	if _, err := InflateBlocks(compressed, -1); err == nil {
+		t.Error("InflateBlocks with a negative block count succeeded")
+	}
+	if _, err := InflateBlocks(compressed[:len(compressed)/2], 0); err == nil {
+		t.Error("InflateBlocks of a whole truncated stream succeeded")
+	}
+}
+
+func TestValidateHuffmanTreeDictTables(t *testing.T) {
+	if err := validateHuffmanTreeDictTables(huffmanTreeDictBits, huffmanTreeDictSymbols); err != nil {
+		t.Fatalf("the dictionary tables are invalid: %v", err)
+	}
+
+	last := len(huffmanTreeDictBits) - 1
+	tests := []struct {
+		name   string
+		damage func(bits []uint8, symbols []int16) ([]uint8, []int16)
+	}{
+		{"missing length", func(bits []uint8, symbols []int16) ([]uint8, []int16) { return bits[:last], symbols }},
+		{"zero length", func(bits []uint8, symbols []int16) ([]uint8, []int16) { bits[0] = 0; return bits, symbols }},
+		{"length too long", func(bits []uint8, symbols []int16) ([]uint8, []int16) {
+			bits[last] = MAX_CODE_BITS_LENGTH
+			return bits, symbols
+		}},
+		{"lengths out of order", func(bits []uint8, symbols []int16) ([]uint8, []int16) {
+			bits[0], bits[last] = bits[last], bits[0]
+			return bits, symbols
+		}},
+		{"negative symbol", func(bits []uint8, symbols []int16) ([]uint8, []int16) { symbols[0] = -1; return bits, symbols }},
+		{"symbol out of range", func(bits []uint8, symbols []int16) ([]uint8, []int16) {
+			symbols[0] = MAX_SYMBOL_VALUE
+			return bits, symbols
+		}},
+		{"repeated symbol", func(bits []uint8, symbols []int16) ([]uint8, []int16) {
+			symbols[1] = symbols[0]
+			return bits, symbols
+		}},
+		{"incomplete code", func(bits []uint8, symbols []int16) ([]uint8, []int16) {
+			bits[last]++
+			return bits, symbols
+		}},
+		{"overfull code", func(bits []uint8, symbols []int16) ([]uint8, []int16) {
+			return append(bits, bits[last]), append(symbols, 0x100)
+		}},
+	}
+	for _, test := range tests {
+		bits, symbols := test.damage(slices.Clone(huffmanTreeDictBits), slices.Clone(huffmanTreeDictSymbols))
+		if err := validateHuffmanTreeDictTables(bits, symbols); err == nil {
+			t.Errorf("%s: validateHuffmanTreeDictTables succeeded", test.name)
+		}
+	}
+}
+
+func TestStreamParameters(t *testing.T) {
+	for nibble := uint32(0); nibble < 16; nibble++ {
+		// Header words, the parameter byte with a non-zero unused high
+		// nibble, then a marker that must be read right after it
+		w := &bitWriter{}
+		w.writeBits(0, 32)
+		w.writeBits(1000, 32)
+		w.writeBits(0xA, 4)
+		w.writeBits(nibble, 4)
+		w.writeBits(0xABCDEF, 24)
+		w.writeBits(0, 32)
+
+		stateData, size, err := startStream(convertU8ToU32(w.bytes()), BlockSize)
+		if err != nil || size != 1000 {
+			t.Fatalf("nibble %d: startStream = %d, %v", nibble, size, err)
+		}
+		f := newInflater(stateData)
+		if want := nibble + 1; f.writeSizeConstantAddition != want {
+			t.Errorf("nibble %d: writeSizeConstantAddition = %d, want %d", nibble, f.writeSizeConstantAddition, want)
+		}
+		needBits(stateData, 24)
+		if got := readBits(stateData, 24); got != 0xABCDEF {
+			t.Errorf("nibble %d: read %#x after the parameters, want 0xabcdef: not exactly 8 bits consumed", nibble, got)
+		}
+	}
+
+	// The parameters are byte 11 of a stream: its high nibble is ignored and
+	// its low nibble changes every copy length
+	data := testPayload(5000)
+	compressed := testDeflate(t, data)
+	if compressed[11] != deflateMinMatch-1 {
+		t.Fatalf("Deflate wrote parameter byte %#x, want %#x", compressed[11], deflateMinMatch-1)
+	}
+	highNibble := bytes.Clone(compressed)
+	highNibble[11] |= 0xF0
+	if got, err := InflateBuffer(highNibble, nil, 0); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("InflateBuffer with the high nibble set returned %d bytes, %v", len(got), err)
+	}
+	lowNibble := bytes.Clone(compressed)
+	lowNibble[11]++
+	if got, err := InflateBuffer(lowNibble, nil, 0); err == nil && bytes.Equal(got, data) {
+		t.Error("InflateBuffer ignored the write size constant addition")
+	}
+}
+
+func TestBitReaderErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		words  []uint32
+		misuse func(stateData *State)
+	}{
+		{"need more than 32 bits", []uint32{1, 2}, func(s *State) { needBits(s, 40) }},
+		{"drop more than 32 bits", []uint32{1, 2}, func(s *State) { needBits(s, 32); dropBits(s, 40) }},
+		{"drop bits not loaded", []uint32{1, 2}, func(s *State) { dropBits(s, 1) }},
+		{"read past the input", []uint32{1}, func(s *State) {
+			for i := 0; i < 3; i++ {
+				needBits(s, 32)
+				dropBits(s, 32)
+			}
+		}},
+	}
+	for _, test := range tests {
+		stateData, err := newState(test.words)
+		if err != nil {
+			t.Fatal(err)
+		}
+		test.misuse(stateData)
+		if stateData.Err == nil {
+			t.Errorf("%s: no error recorded", test.name)
+			continue
+		}
+		// The first error is kept
+		first := stateData.Err
+		needBits(stateData, 40)
+		if stateData.Err != first {
+			t.Errorf("%s: error replaced by %v", test.name, stateData.Err)
+		}
+	}
+
+	if _, err := newState(nil); !errors.Is(err, ErrCorruptStream) {
+		t.Errorf("newState of no input returned %v, want ErrCorruptStream", err)
+	}
+}
+
+func TestProbeInflate(t *testing.T) {
+	for _, size := range testPayloadSizes {
+		if err := ProbeInflate(testDeflate(t, testPayload(size))); err != nil {
+			t.Errorf("ProbeInflate of a valid %d byte stream: %v", size, err)
+		}
+	}
+
+	compressed := testDeflate(t, testPayload(5000))
+	flipped := bytes.Clone(compressed)
+	for i := 12; i < len(flipped); i += 7 {
+		flipped[i] ^= 0x5A
+	}
+	// A valid stream whose header claims 4 GiB of output
+	bomb := bytes.Clone(compressed)
+	binary.LittleEndian.PutUint32(bomb[4:], math.MaxUint32)
+
+	tests := []struct {
+		name    string
+		input   []byte
+		corrupt bool // The error wraps ErrCorruptStream
+	}{
+		{"nil", nil, false},
+		{"empty", []byte{}, true},
+		{"header only", compressed[:6], true},
+		{"truncated", compressed[:len(compressed)/2], true},
+		{"flipped bytes", flipped, true},
+		{"size past the stream", bomb, true},
+	}
+	for _, test := range tests {
+		err := ProbeInflate(test.input)
+		if err == nil {
+			t.Errorf("%s: ProbeInflate succeeded", test.name)
+			continue
+		}
+		if errors.Is(err, ErrCorruptStream) != test.corrupt {
+			t.Errorf("%s: ProbeInflate returned %v, ErrCorruptStream expected %v", test.name, err, test.corrupt)
+		}
+	}
+
+	// Blocks of another size are only probed correctly when told
+	custom, err := deflate(testPayload(200000), 0x400)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := probeInflate(custom, 0x400); err != nil {
+		t.Errorf("probeInflate with the stream's block size: %v", err)
+	}
+	if err := ProbeInflate(custom); err == nil {
+		t.Error("ProbeInflate of a stream in 0x400 word blocks succeeded")
+	}
+}
+
+func TestProbeInflateAllocations(t *testing.T) {
+	const size = 16 << 20
+	compressed := testDeflate(t, make([]byte, size))
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if err := ProbeInflate(compressed); err != nil {
+		t.Fatalf("ProbeInflate: %v", err)
+	}
+	runtime.ReadMemStats(&after)
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > size/4 {
+		t.Errorf("ProbeInflate of a %d byte stream allocated %d bytes", size, allocated)
+	}
+}
+
+// sinkWriter counts the bytes written to it and keeps the largest single
+// write. A non-zero limit makes it write at most limit bytes per call without
+// reporting an error, and a non-nil err makes every write fail.
+type sinkWriter struct {
+	n, largest, limit int
+	err               error
+}
+
+func (w *sinkWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	if w.limit > 0 && len(p) > w.limit {
+		p = p[:w.limit]
+	}
+	w.n += len(p)
+	w.largest = max(w.largest, len(p))
+	return len(p), nil
+}
+
+func TestInflateToWriter(t *testing.T) {
+	for _, size := range testPayloadSizes {
+		data := testPayload(size)
+		compressed := testDeflate(t, data)
+
+		stateData, outputBufferSize, err := openStream(compressed, BlockSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buffer bytes.Buffer
+		if err := inflateToWriter(stateData, &buffer, outputBufferSize); err != nil {
+			t.Fatalf("size %d: inflateToWriter to a bytes.Buffer: %v", size, err)
+		}
+		if !bytes.Equal(buffer.Bytes(), data) {
+			t.Errorf("size %d: inflateToWriter wrote %d bytes not matching the input", size, buffer.Len())
+		}
+
+		stateData, outputBufferSize, err = openStream(compressed, BlockSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counter := &sinkWriter{}
+		if err := inflateToWriter(stateData, counter, outputBufferSize); err != nil {
+			t.Fatalf("size %d: inflateToWriter to a counting writer: %v", size, err)
+		}
+		if counter.n != size {
+			t.Errorf("size %d: inflateToWriter wrote %d bytes", size, counter.n)
+		}
+		if counter.largest > BlockSize {
+			t.Errorf("size %d: inflateToWriter wrote %d bytes at once, more than BlockSize", size, counter.largest)
+		}
+	}
+
+	compressed := testDeflate(t, testPayload(70000))
+	errWrite := errors.New("disk full")
+	tests := []struct {
+		name string
+		w    *sinkWriter
+		want error
+	}{
+		{"failing writer", &sinkWriter{err: errWrite}, errWrite},
+		{"short writer", &sinkWriter{limit: 100}, io.ErrShortWrite},
+	}
+	for _, test := range tests {
+		stateData, outputBufferSize, err := openStream(compressed, BlockSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := inflateToWriter(stateData, test.w, outputBufferSize); !errors.Is(err, test.want) {
+			t.Errorf("%s: inflateToWriter returned %v, want %v", test.name, err, test.want)
+		}
+		if test.w.n > BlockSize {
+			t.Errorf("%s: inflateToWriter kept writing %d bytes after the first write failed", test.name, test.w.n)
+		}
+	}
+}
+
+func TestConvertU8ToU32(t *testing.T) {
+	tests := []struct {
+		input []byte
+		want  []uint32
+	}{
+		{[]byte{0x11}, []uint32{0x00000011}},
+		{[]byte{0x11, 0x22}, []uint32{0x00002211}},
+		{[]byte{0x11, 0x22, 0x33}, []uint32{0x00332211}},
+		{[]byte{0x11, 0x22, 0x33, 0x44}, []uint32{0x44332211}},
+		{[]byte{0x11, 0x22, 0x33, 0x44, 0x55}, []uint32{0x44332211, 0x00000055}},
+	}
+	for _, test := range tests {
+		if got := convertU8ToU32(test.input); !slices.Equal(got, test.want) {
+			t.Errorf("convertU8ToU32(% x) = %#08x, want %#08x", test.input, got, test.want)
+		}
+		// Stale words in a reused buffer must not leak into the padding
+		dst := []uint32{math.MaxUint32, math.MaxUint32, math.MaxUint32}
+		if got := convertU8ToU32Into(dst, test.input); !slices.Equal(got, test.want) {
+			t.Errorf("convertU8ToU32Into(% x) = %#08x, want %#08x", test.input, got, test.want)
+		}
+	}
+}
+
+// testCopyBeforeStart returns the stream of literals 'A' followed by one
+// copy of deflateMinMatch bytes at distance offset, which a decoder must
+// refuse when offset goes past the start of the output.
+func testCopyBeforeStart(literals int, offset uint32) []byte {
+	w := &bitWriter{}
+	w.writeBits(0, 32)
+	w.writeBits(uint32(literals+deflateMinMatch), 32)
+	w.writeBits(0, 4)
+	w.writeBits(deflateMinMatch-1, 4)
+
+	// Symbol tree of 'A' and the shortest copy, copy tree of distances up to
+	// 16. Neither code is complete: readCode takes a tree whose smallest
+	// code is all zeros for an empty one.
+	symbolLengths := make([]uint8, 0x101)
+	symbolLengths['A'], symbolLengths[0x100] = 1, 2
+	copyLengths := []uint8{4, 4, 4, 4, 4, 4, 4, 4}
+	writeTreeDescriptor(w, symbolLengths)
+	writeTreeDescriptor(w, copyLengths)
+	w.writeBits(0, 4)
+
+	symbolCodes := huffmanCodes(huffmanTreeFromLengths(symbolLengths))
+	copyCodes := huffmanCodes(huffmanTreeFromLengths(copyLengths))
+	for i := 0; i < literals; i++ {
+		w.writeBits(symbolCodes['A'].code, symbolCodes['A'].bits)
+	}
+	w.writeBits(symbolCodes[0x100].code, symbolCodes[0x100].bits)
+	offsetCode, offsetExtra, offsetExtraBits := writeOffsetCode(offset - 1)
+	w.writeBits(copyCodes[offsetCode].code, copyCodes[offsetCode].bits)
+	w.writeBits(offsetExtra, offsetExtraBits)
+	return w.bytes()
+}
+
+func TestInflateCopyBeforeStart(t *testing.T) {
+	tests := []struct {
+		name     string
+		literals int
+		offset   uint32
+	}{
+		{"copy first", 0, 1},
+		{"offset past one literal", 1, 2},
+		{"offset past several literals", 5, 6},
+	}
+	for _, test := range tests {
+		input := testCopyBeforeStart(test.literals, test.offset)
+		got, err := InflateBuffer(input, nil, 0)
+		if !errors.Is(err, ErrCorruptStream) || !strings.Contains(err.Error(), "back-reference") {
+			t.Errorf("%s: InflateBuffer returned %v, want ErrCorruptStream for the back-reference", test.name, err)
+		}
+		if got != nil {
+			t.Errorf("%s: InflateBuffer returned %d bytes", test.name, len(got))
+		}
+		if err := ProbeInflate(input); !errors.Is(err, ErrCorruptStream) {
+			t.Errorf("%s: ProbeInflate returned %v, want ErrCorruptStream", test.name, err)
+		}
+	}
+
+	// The same stream with the copy reaching back to the first byte decodes
+	want := bytes.Repeat([]byte("A"), 5+deflateMinMatch)
+	if got, err := InflateBuffer(testCopyBeforeStart(5, 5), nil, 0); err != nil || !bytes.Equal(got, want) {
+		t.Errorf("InflateBuffer of a copy from the first byte = %q, %v; want %q", got, err, want)
+	}
+}
+
+func TestReadBits(t *testing.T) {
+	tests := []struct {
+		head uint32
+		bits uint8
+		want uint32
+	}{
+		{0xFFFFFFFF, 0, 0},
+		{0x80000000, 1, 1},
+		{0x7FFFFFFF, 1, 0},
+		{0xA0000000, 3, 0b101},
+		{0xDEADBEEF, 4, 0xD},
+		{0xDEADBEEF, 16, 0xDEAD},
+		{0xDEADBEEF, 31, 0xDEADBEEF >> 1},
+		{0xDEADBEEF, 32, 0xDEADBEEF},
+		{0xFFFFFFFF, 32, 0xFFFFFFFF},
+	}
+	for _, test := range tests {
+		// Bits in Buffer follow Head and are never part of the value
+		stateData := &State{Head: test.head, Buffer: 0xFFFFFFFF, Bits: 64}
+		if got := readBits(stateData, test.bits); got != test.want {
+			t.Errorf("readBits(%#08x, %d) = %#x, want %#x", test.head, test.bits, got, test.want)
+		}
+	}
+
+	// Every width reads the leading bits of Head, most significant first
+	for _, head := range []uint32{0, 0xFFFFFFFF, 0xDEADBEEF, 0x00000001, 0x80000000} {
+		stateData := &State{Head: head, Buffer: 0xFFFFFFFF, Bits: 64}
+		for bits := uint8(0); bits <= 32; bits++ {
+			var want uint32
+			for i := uint8(0); i < bits; i++ {
+				want = want<<1 | head>>(31-i)&1
+			}
+			if got := readBits(stateData, bits); got != want {
+				t.Errorf("readBits(%#08x, %d) = %#x, want %#x", head, bits, got, want)
+			}
+			if bits < 32 && readBits(stateData, bits)>>bits != 0 {
+				t.Errorf("readBits(%#08x, %d) has bits above the width", head, bits)
+			}
+		}
+	}
+}
+
+func TestReadCodeBrokenTree(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(tree *HuffmanTree)
+	}{
+		{"unterminated", func(tree *HuffmanTree) {
+			// Every entry is populated and above any input, so the search
+			// would run off the end of the code lengths
+			for i := range tree.CompressedCodes {
+				tree.CompressedCodes[i] = math.MaxUint32
+				tree.BitsLength[i] = 1
+			}
+		}},
+		{"no matching code", func(tree *HuffmanTree) {
+			tree.CompressedCodes[0] = math.MaxUint32
+			tree.BitsLength[0] = 1
+		}},
+		{"delta past the symbols", func(tree *HuffmanTree) {
+			// Codes 01, 10 and 11 share one symbol
+			tree.CompressedCodes[0] = 0x40000000
+			tree.BitsLength[0] = 2
+			tree.SymbolValueOffset[0] = 0
+		}},
+	}
+	for _, test := range tests {
+		tree := &HuffmanTree{}
+		test.build(tree)
+		stateData, err := newState([]uint32{0xC0000000, 0})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var symbol uint16
+		if err := readCode(tree, stateData, &symbol); err == nil {
+			t.Errorf("%s: readCode succeeded with symbol %d", test.name, symbol)
+		}
+	}
+}
+
+// testStreamOfWords returns a Deflate stream of random bytes whose length is
+// words words, counting the checksum words, and the bytes it holds. The
+// stream grows by about one word for every four bytes of random input.
+func testStreamOfWords(t *testing.T, words int) ([]byte, []byte) {
+	t.Helper()
+	random := rand.New(rand.NewSource(27))
+	data := make([]byte, 4*words)
+	random.Read(data)
+
+	size := 4 * (words - 64)
+	for attempt := 0; attempt < 32; attempt++ {
+		compressed := testDeflate(t, data[:size])
+		got := len(compressed) / 4
+		if got == words {
+			return compressed, data[:size]
+		}
+		step := 4 * (words - got)
+		if step == 0 || attempt > 8 {
+			step = words - got // Close by, creep up or down a byte at a time
+		}
+		size += step
+	}
+	t.Fatalf("no Deflate stream of %d words found", words)
+	return nil, nil
+}
+
+func TestPullByteBlockBoundary(t *testing.T) {
+	// Deflate ends streams with two words of padding, so the last word of
+	// data is the third from the end. A stream of BlockSize words is
+	// impossible: its last word would be the checksum of the block, which is
+	// only written with a word after it.
+	tests := []struct {
+		name  string
+		words int
+	}{
+		{"just under", BlockSize - 1}, // The padding ends right before the checksum word
+		{"at", BlockSize + 1},         // The padding straddles the checksum word
+		{"just over", BlockSize + 3},  // The data straddles the checksum word
+	}
+	for _, test := range tests {
+		compressed, data := testStreamOfWords(t, test.words)
+		if got, err := InflateBuffer(compressed, nil, 0); err != nil || !bytes.Equal(got, data) {
+			t.Errorf("%s: InflateBuffer of %d words returned %d bytes, %v", test.name, test.words, len(got), err)
+		}
+		r, err := NewReader(bytes.NewReader(compressed))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := io.ReadAll(r); err != nil || !bytes.Equal(got, data) {
+			t.Errorf("%s: NewReader of %d words returned %d bytes, %v", test.name, test.words, len(got), err)
+		}
+
+		// Cutting the stream well short fails instead of reading past the
+		// input; the last words only pad the decoder's lookahead
+		if _, err := InflateBuffer(compressed[:len(compressed)-64], nil, 0); !errors.Is(err, ErrCorruptStream) {
+			t.Errorf("%s: InflateBuffer of a truncated stream returned %v, want ErrCorruptStream", test.name, err)
+		}
+		if test.words > BlockSize+2 {
+			for _, words := range []int{BlockSize - 1, BlockSize} {
+				if _, err := InflateBuffer(compressed[:4*words], nil, 0); !errors.Is(err, ErrCorruptStream) {
+					t.Errorf("%s: InflateBuffer cut to %d words returned %v, want ErrCorruptStream", test.name, words, err)
+				}
+			}
+		}
+	}
+
+	// Reading word by word skips exactly the checksum word of the block, and
+	// a checksum word missing from the end of the input is an error
+	for _, words := range []uint32{BlockSize - 1, BlockSize, BlockSize + 1} {
+		input := make([]uint32, words)
+		for i := range input {
+			input[i] = uint32(i)
+		}
+		stateData, err := newState(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []uint32
+		for stateData.Err == nil {
+			needBits(stateData, 32)
+			if stateData.Err == nil {
+				got = append(got, readBits(stateData, 32))
+			}
+			dropBits(stateData, 32)
+		}
+		want := input[:min(words, BlockSize-1)]
+		if words > BlockSize {
+			want = append(slices.Clone(want), input[BlockSize:]...)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("%d words: read %d words, want %d skipping the checksum", words, len(got), len(want))
+		}
+		if words == BlockSize-1 && !strings.Contains(stateData.Err.Error(), "block checksum word") {
+			t.Errorf("%d words: reading past the end returned %v, want the missing checksum word", words, stateData.Err)
+		}
+	}
+}
+
+// TestInflateBufferConcurrent decodes one stream from many goroutines at
+// once; run with -race it checks that decoders share no mutable state.
+func TestInflateBufferConcurrent(t *testing.T) {
+	const goroutines = 32
+	data := testPayload(70000)
+	compressed := testDeflate(t, data)
+
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := InflateBuffer(compressed, nil, 0)
+			if err != nil || !bytes.Equal(got, data) {
+				t.Errorf("goroutine %d: InflateBuffer returned %d bytes, %v", i, len(got), err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// TestInflateConcurrentInputs decodes different streams at once, through
+// InflateBuffer, NewReader and a Decoder per goroutine, so that under -race
+// any state shared between decompressions shows up.
+func TestInflateConcurrentInputs(t *testing.T) {
+	var wg sync.WaitGroup
+	for _, size := range testPayloadSizes {
+		data := testPayload(size)
+		compressed := testDeflate(t, data)
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			if got, err := InflateBuffer(compressed, nil, 0); err != nil || !bytes.Equal(got, data) {
+				t.Errorf("size %d: InflateBuffer returned %d bytes, %v", size, len(got), err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			r, err := NewReader(bytes.NewReader(compressed))
+			if err != nil {
+				t.Errorf("size %d: NewReader: %v", size, err)
+				return
+			}
+			if got, err := io.ReadAll(r); err != nil || !bytes.Equal(got, data) {
+				t.Errorf("size %d: NewReader returned %d bytes, %v", size, len(got), err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			d := NewDecoder()
+			for i := 0; i < 2; i++ {
+				if got, err := d.Inflate(compressed); err != nil || !bytes.Equal(got, data) {
+					t.Errorf("size %d: Decoder.Inflate returned %d bytes, %v", size, len(got), err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,44 @@
+package dat
+
+import (
+	"fmt"
+	"os"
+)
+
+// DatPathEnv names the environment variable FindDatFile checks first.
+const DatPathEnv = "GW2_DAT_PATH"
+
+// FindDatFile returns the path of the first Gw2.dat found among, in order:
+// the path in $GW2_DAT_PATH, the install paths recorded in the registry on
+// Windows, the default location of the standalone installer and the default
+// Steam library.
+func FindDatFile() (string, error) {
+	return findDatFile(datFileCandidates(os.Getenv), fileExists)
+}
+
+// findDatFile returns the first of candidates for which exists is true.
+func findDatFile(candidates []string, exists func(path string) bool) (string, error) {
+	for _, path := range candidates {
+		if exists(path) {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("Gw2.dat not found in any of %d known locations", len(candidates))
+}
+
+// datFileCandidates lists the paths FindDatFile tries, in order, reading the
+// environment through getenv.
+func datFileCandidates(getenv func(key string) string) []string {
+	var candidates []string
+	if path := getenv(DatPathEnv); path != "" {
+		candidates = append(candidates, path)
+	}
+	candidates = append(candidates, registryDatPaths()...)
+	return append(candidates, defaultDatPaths(getenv)...)
+}
+
+// fileExists reports whether path names an existing regular file.
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
@@ -0,0 +1,24 @@
+//go:build !windows
+
+package dat
+
+import "path/filepath"
+
+// registryDatPaths returns nothing: only Windows has a registry.
+func registryDatPaths() []string {
+	return nil
+}
+
+// defaultDatPaths returns the default locations of a Wine standalone install
+// and of the Steam library under the home directory.
+func defaultDatPaths(getenv func(key string) string) []string {
+	home := getenv("HOME")
+	if home == "" {
+		return nil
+	}
+	return []string{
+		filepath.Join(home, ".wine", "drive_c", "Program Files", "Guild Wars 2", "Gw2.dat"),
+		filepath.Join(home, ".local", "share", "Steam", "steamapps", "common", "Guild Wars 2", "Gw2.dat"),
+		filepath.Join(home, ".steam", "steam", "steamapps", "common", "Guild Wars 2", "Gw2.dat"),
+	}
+}
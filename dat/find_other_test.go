@@ -0,0 +1,50 @@
+//go:build !windows
+
+package dat
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindDatFileDefaultPaths(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv(DatPathEnv, "")
+
+	wine := filepath.Join(home, ".wine", "drive_c", "Program Files", "Guild Wars 2", "Gw2.dat")
+	steam := filepath.Join(home, ".local", "share", "Steam", "steamapps", "common", "Guild Wars 2", "Gw2.dat")
+	create := func(path string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got, err := FindDatFile(); err == nil {
+		t.Fatalf("FindDatFile in an empty home = %q, want an error", got)
+	}
+
+	create(steam)
+	if got, err := FindDatFile(); err != nil || got != steam {
+		t.Errorf("FindDatFile with a Steam install = %q, %v; want %q", got, err, steam)
+	}
+
+	// The standalone install comes before Steam
+	create(wine)
+	if got, err := FindDatFile(); err != nil || got != wine {
+		t.Errorf("FindDatFile with both installs = %q, %v; want %q", got, err, wine)
+	}
+
+	// The environment variable comes before both
+	custom := filepath.Join(home, "custom.dat")
+	create(custom)
+	t.Setenv(DatPathEnv, custom)
+	if got, err := FindDatFile(); err != nil || got != custom {
+		t.Errorf("FindDatFile with %s set = %q, %v; want %q", DatPathEnv, got, err, custom)
+	}
+}
@@ -0,0 +1,60 @@
+package dat
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindDatFileOrder(t *testing.T) {
+	candidates := []string{"/first/Gw2.dat", "/second/Gw2.dat", "/third/Gw2.dat"}
+
+	tests := []struct {
+		name     string
+		existing []string
+		want     string // Empty when nothing is found
+	}{
+		{"first", []string{"/first/Gw2.dat", "/third/Gw2.dat"}, "/first/Gw2.dat"},
+		{"later", []string{"/third/Gw2.dat"}, "/third/Gw2.dat"},
+		{"none", nil, ""},
+	}
+	for _, test := range tests {
+		exists := func(path string) bool {
+			for _, existing := range test.existing {
+				if path == existing {
+					return true
+				}
+			}
+			return false
+		}
+		got, err := findDatFile(candidates, exists)
+		if test.want == "" {
+			if err == nil {
+				t.Errorf("%s: findDatFile = %q, want an error", test.name, got)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("%s: findDatFile = %q, %v; want %q", test.name, got, err, test.want)
+		}
+	}
+}
+
+func TestFindDatFileEnv(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "Gw2.dat")
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(DatPathEnv, path)
+	if got, err := FindDatFile(); err != nil || got != path {
+		t.Errorf("FindDatFile with %s set = %q, %v; want %q", DatPathEnv, got, err, path)
+	}
+
+	// A directory is not a dat
+	t.Setenv(DatPathEnv, dir)
+	if got, _ := FindDatFile(); got == dir {
+		t.Errorf("FindDatFile returned the directory %q", dir)
+	}
+}
@@ -0,0 +1,80 @@
+//go:build windows
+
+package dat
+
+import (
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// gw2RegistryKeys are the keys the installer records the client path under,
+// for 64-bit and 32-bit views of the registry.
+var gw2RegistryKeys = []string{
+	`SOFTWARE\ArenaNet\Guild Wars 2`,
+	`SOFTWARE\WOW6432Node\ArenaNet\Guild Wars 2`,
+}
+
+// registryDatPaths returns the Gw2.dat next to each client path recorded in
+// the registry, machine-wide installs first.
+func registryDatPaths() []string {
+	var paths []string
+	for _, root := range []syscall.Handle{syscall.HKEY_LOCAL_MACHINE, syscall.HKEY_CURRENT_USER} {
+		for _, key := range gw2RegistryKeys {
+			path, ok := readRegistryString(root, key, "Path")
+			if !ok {
+				continue
+			}
+			// The value names the executable, or sometimes its directory
+			if strings.EqualFold(filepath.Ext(path), ".exe") {
+				path = filepath.Dir(path)
+			}
+			paths = append(paths, filepath.Join(path, "Gw2.dat"))
+		}
+	}
+	return paths
+}
+
+// readRegistryString reads the REG_SZ value name of the key at path under root.
+func readRegistryString(root syscall.Handle, path, name string) (string, bool) {
+	keyPath, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return "", false
+	}
+	valueName, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return "", false
+	}
+
+	var key syscall.Handle
+	if err := syscall.RegOpenKeyEx(root, keyPath, 0, syscall.KEY_READ, &key); err != nil {
+		return "", false
+	}
+	defer syscall.RegCloseKey(key)
+
+	var valueType, size uint32
+	if err := syscall.RegQueryValueEx(key, valueName, nil, &valueType, nil, &size); err != nil || valueType != syscall.REG_SZ || size < 2 {
+		return "", false
+	}
+	buffer := make([]uint16, size/2)
+	if err := syscall.RegQueryValueEx(key, valueName, nil, &valueType, (*byte)(unsafe.Pointer(&buffer[0])), &size); err != nil {
+		return "", false
+	}
+	value := syscall.UTF16ToString(buffer)
+	return value, value != ""
+}
+
+// defaultDatPaths returns the default standalone and Steam install locations.
+func defaultDatPaths(getenv func(key string) string) []string {
+	var paths []string
+	for _, programFiles := range []string{getenv("ProgramFiles"), getenv("ProgramFiles(x86)")} {
+		if programFiles != "" {
+			paths = append(paths, filepath.Join(programFiles, "Guild Wars 2", "Gw2.dat"))
+		}
+	}
+	if programFiles := getenv("ProgramFiles(x86)"); programFiles != "" {
+		paths = append(paths, filepath.Join(programFiles, "Steam", "steamapps", "common", "Guild Wars 2", "Gw2.dat"))
+	}
+	return paths
+}
@@ -0,0 +1,251 @@
+package dat
+
+import (
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// firstTestBaseID is the base ID of the first entry laid out by testDat,
+// which follows the reserved rows.
+const firstTestBaseID = MftEntryMftNum + 2
+
+// testEntry is an entry of a dat built by testDat.
+type testEntry struct {
+	data       []byte
+	compressed bool   // Stored as the Deflate stream of data
+	deflated   bool   // data is already a compressed stream, stored as is
+	flag       uint16 // MFTEntry.EntryFlag
+	deleted    bool   // The row keeps its size but has the all-ones offset, and data is not written
+}
+
+// testDat describes a dat to lay out for a test: the header, the entries,
+// the index table and the MFT, in that order.
+type testDat struct {
+	entries []testEntry
+	fileIDs [][2]uint32 // Index table rows, file ID then base ID
+	narrow  bool        // 32-bit MFT offsets, see HeaderFlagNarrowOffsets
+
+	// chunkSize is Header.ChunkSize, 0x200 when 0. Compressed entries are
+	// written in blocks of the size it describes, see Header.StreamBlockSize.
+	chunkSize uint32
+}
+
+// narrowMFTEntry is the on-disk form of an MFT row with a 32-bit offset.
+type narrowMFTEntry struct {
+	Offset          uint32
+	Size            uint32
+	CompressionFlag uint16
+	EntryFlag       uint16
+	Counter         uint32
+	CRC             uint32
+}
+
+// build returns the bytes of the dat.
+func (spec testDat) build(t testing.TB) []byte {
+	t.Helper()
+
+	headerSize := binary.Size(Header{})
+	chunkSize := spec.chunkSize
+	if chunkSize == 0 {
+		chunkSize = 0x200
+	}
+	blockWords := (&Header{ChunkSize: chunkSize}).StreamBlockSize()
+	var body bytes.Buffer
+	body.Write(make([]byte, headerSize))
+
+	rows := []MFTEntry{{Size: uint32(headerSize)}, {}, {}}
+	for _, entry := range spec.entries {
+		data, compressionFlag := entry.data, uint16(CompressionNone)
+		if entry.deflated {
+			compressionFlag = CompressionGW2
+		} else if entry.compressed {
+			var err error
+			if data, err = deflate(entry.data, blockWords); err != nil {
+				t.Fatalf("deflate: %v", err)
+			}
+			compressionFlag = CompressionGW2
+		}
+		row := MFTEntry{
+			Offset:          uint64(body.Len()),
+			Size:            uint32(len(data)),
+			CompressionFlag: compressionFlag,
+			EntryFlag:       entry.flag,
+		}
+		if entry.deleted {
+			row.Offset = math.MaxUint64
+		} else {
+			body.Write(data)
+		}
+		rows = append(rows, row)
+	}
+
+	rows[MftEntryIndexNum] = MFTEntry{Offset: uint64(body.Len()), Size: uint32(len(spec.fileIDs) * 8)}
+	for _, row := range spec.fileIDs {
+		binary.Write(&body, binary.LittleEndian, row)
+	}
+
+	rowSize := binary.Size(MFTEntry{})
+	if spec.narrow {
+		rowSize -= 4
+	}
+	mftOffset := body.Len()
+	mftSize := binary.Size(MFTHeader{}) + rowSize*len(rows)
+	rows[MftEntryMftNum] = MFTEntry{Offset: uint64(mftOffset), Size: uint32(mftSize)}
+	binary.Write(&body, binary.LittleEndian, MFTHeader{
+		Identifier: [MftMagicNumber]uint8{'M', 'f', 't', 0x1A},
+		NumEntries: uint32(len(rows)),
+	})
+	for _, row := range rows {
+		if spec.narrow {
+			binary.Write(&body, binary.LittleEndian, narrowMFTEntry{
+				uint32(row.Offset), row.Size, row.CompressionFlag, row.EntryFlag, row.Counter, row.CRC,
+			})
+			continue
+		}
+		binary.Write(&body, binary.LittleEndian, row)
+	}
+
+	header := Header{
+		Version:    DatVersion,
+		Identifier: [DatMagicNumber]uint8{'A', 'N', 0x1A},
+		HeaderSize: uint32(headerSize),
+		ChunkSize:  chunkSize,
+		MftOffset:  uint64(mftOffset),
+		MftSize:    uint32(mftSize),
+	}
+	if spec.narrow {
+		header.Flags = HeaderFlagNarrowOffsets
+	}
+	out := body.Bytes()
+	var headerBytes bytes.Buffer
+	binary.Write(&headerBytes, binary.LittleEndian, header)
+	copy(out, headerBytes.Bytes())
+	return out
+}
+
+// write stores the dat in a temporary file and returns its path.
+func (spec testDat) write(t testing.TB) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "Gw2.dat")
+	if err := os.WriteFile(path, spec.build(t), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// open writes the dat and opens it with opts, closing it when the test ends.
+func (spec testDat) open(t testing.TB, opts Options) *DatFile {
+	t.Helper()
+	datFile, err := OpenWithOptions(spec.write(t), opts)
+	if err != nil {
+		t.Fatalf("OpenWithOptions: %v", err)
+	}
+	t.Cleanup(func() { datFile.Close() })
+	return datFile
+}
+
+// faultyReaderAt reads from r, except that reads starting at offset at go to
+// fault instead.
+type faultyReaderAt struct {
+	r     io.ReaderAt
+	at    int64
+	fault func(p []byte) (int, error)
+}
+
+func (f *faultyReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off == f.at && f.fault != nil {
+		return f.fault(p)
+	}
+	return f.r.ReadAt(p, off)
+}
+
+// testPayload returns size bytes mixing runs of text, which compress into
+// back-references, with random bytes, which stay literals. The same size
+// always gives the same bytes.
+func testPayload(size int) []byte {
+	random := rand.New(rand.NewSource(int64(size)))
+	text := []byte("Guild Wars 2 dat payload; ")
+	data := make([]byte, 0, size)
+	for len(data) < size {
+		if random.Intn(2) == 0 {
+			data = append(data, text[:random.Intn(len(text))+1]...)
+			continue
+		}
+		for n := random.Intn(64); n > 0; n-- {
+			data = append(data, byte(random.Intn(256)))
+		}
+	}
+	return data[:size]
+}
+
+// testDeflate compresses data with Deflate, failing the test on error.
+func testDeflate(t testing.TB, data []byte) []byte {
+	t.Helper()
+	compressed, err := Deflate(data)
+	if err != nil {
+		t.Fatalf("Deflate: %v", err)
+	}
+	return compressed
+}
+
+// updateFixtures makes TestCLIFixture rewrite testdata/cli.dat.
+var updateFixtures = flag.Bool("update", false, "rewrite the dat fixtures in testdata")
+
+// cliFixture describes testdata/cli.dat, the dat the skritto command's tests
+// read. Its base IDs are:
+//
+//   - 4: the stored text "stored entry", file ID 100
+//   - 5: 3000 bytes of testPayload, compressed, file IDs 101 and 102
+//   - 6: the 10 stored bytes "0123456789"
+//   - 7: an 8x4 red DXT1 texture, compressed
+//   - 8: a deleted row
+//   - 9: an empty row
+//   - 10 to 21: the stored texts "entry 10" to "entry 21"
+func cliFixture() testDat {
+	entries := []testEntry{
+		{data: []byte("stored entry")},
+		{data: testPayload(3000), compressed: true},
+		{data: []byte("0123456789")},
+		{data: testTexture("DXT1", 8, 4, 0, nil, 0x001FF800, 0x001FF800, 0, 0), compressed: true},
+		{data: []byte("deleted entry"), deleted: true},
+		{data: []byte{}},
+	}
+	for baseID := 10; baseID <= 21; baseID++ {
+		entries = append(entries, testEntry{data: []byte(fmt.Sprintf("entry %d", baseID))})
+	}
+	return testDat{
+		entries: entries,
+		fileIDs: [][2]uint32{{100, firstTestBaseID}, {101, firstTestBaseID + 1}, {102, firstTestBaseID + 1}},
+	}
+}
+
+const cliFixturePath = "testdata/cli.dat"
+
+// TestCLIFixture checks that testdata/cli.dat is the dat cliFixture
+// describes, or rewrites it when run with -update.
+func TestCLIFixture(t *testing.T) {
+	want := cliFixture().build(t)
+	if *updateFixtures {
+		if err := os.MkdirAll(filepath.Dir(cliFixturePath), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(cliFixturePath, want, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := os.ReadFile(cliFixturePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s is out of date; rerun with -update", cliFixturePath)
+	}
+}
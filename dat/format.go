@@ -0,0 +1,204 @@
+package dat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Format identifies the kind of content held by an extracted entry.
+type Format int
+
+const (
+	FormatUnknown Format = iota
+	FormatATEX           // Generic texture
+	FormatATTX           // Terrain texture
+	FormatATEP           // Texture variant
+	FormatATEC           // Texture variant
+	FormatATEU           // UI texture
+	FormatDDS            // DirectDraw surface
+	FormatPF             // PF chunk container of any other content type
+	FormatMODL           // PF model
+	FormatStrings        // String table
+	FormatABNK           // Audio bank, raw or as a PF
+	FormatAMSP           // Audio script, raw or as a PF
+	FormatMPEG           // MPEG audio
+	FormatWEBM           // WebM video
+	FormatPNG            // PNG image
+	FormatJPEG           // JPEG image
+)
+
+// formatMagics maps the leading bytes of an entry to its format.
+var formatMagics = []struct {
+	magic  string
+	format Format
+}{
+	{"ATEX", FormatATEX},
+	{"ATTX", FormatATTX},
+	{"ATEP", FormatATEP},
+	{"ATEC", FormatATEC},
+	{"ATEU", FormatATEU},
+	{"DDS ", FormatDDS},
+	{"strs", FormatStrings},
+	{"STRS", FormatStrings},
+	{"ABNK", FormatABNK},
+	{"AMSP", FormatAMSP},
+	{"ID3", FormatMPEG},
+	{"\xFF\xFB", FormatMPEG},
+	{"\x1A\x45\xDF\xA3", FormatWEBM},
+	{"\x89PNG\r\n\x1A\n", FormatPNG},
+	{"\xFF\xD8\xFF", FormatJPEG},
+}
+
+// pfFormats maps the content fourCC of a PF file to a more specific format.
+var pfFormats = map[string]Format{
+	"MODL": FormatMODL,
+	"ABNK": FormatABNK,
+	"AMSP": FormatAMSP,
+}
+
+var formatNames = map[Format]string{
+	FormatATEX:    "ATEX",
+	FormatATTX:    "ATTX",
+	FormatATEP:    "ATEP",
+	FormatATEC:    "ATEC",
+	FormatATEU:    "ATEU",
+	FormatDDS:     "DDS",
+	FormatPF:      "PF",
+	FormatMODL:    "MODL",
+	FormatStrings: "strs",
+	FormatABNK:    "ABNK",
+	FormatAMSP:    "AMSP",
+	FormatMPEG:    "MPEG",
+	FormatWEBM:    "WEBM",
+	FormatPNG:     "PNG",
+	FormatJPEG:    "JPEG",
+}
+
+// DetectFormat sniffs the leading magic bytes of decompressed entry data.
+// PF files are further classified by their content fourCC.
+func DetectFormat(data []byte) Format {
+	if len(data) >= 2 && string(data[:2]) == "PF" {
+		if len(data) >= pfHeaderSize {
+			if format, ok := pfFormats[string(data[8:12])]; ok {
+				return format
+			}
+		}
+		return FormatPF
+	}
+
+	for _, candidate := range formatMagics {
+		if len(data) >= len(candidate.magic) && string(data[:len(candidate.magic)]) == candidate.magic {
+			return candidate.format
+		}
+	}
+	return FormatUnknown
+}
+
+// PeekFormat returns the format of the entry with the given base ID, decoding
+// only as much of it as DetectFormat looks at. Compressed entries stop after
+// their first code block at the latest, so this is far cheaper than
+// extracting a large entry.
+func (datFile *DatFile) PeekFormat(id uint32) (Format, error) {
+	index, err := datFile.rowForBaseID(id)
+	if err != nil {
+		return FormatUnknown, err
+	}
+	buffer, err := datFile.readChecked(index)
+	if err != nil {
+		return FormatUnknown, err
+	}
+
+	data := buffer
+	if datFile.MFTData[index].IsCompressed() && !datFile.MFTData[index].IsEmpty() {
+		if r, ok := openStandardStream(buffer); ok {
+			defer r.Close()
+			data, err = inflateStandard(r, pfHeaderSize, datFile.maxSize)
+		} else {
+			data, err = inflateBlocks(buffer, pfHeaderSize, datFile.maxSize, datFile.streamBlockSize(), 1)
+		}
+		if err != nil {
+			return FormatUnknown, fmt.Errorf("decompression failed: %w", err)
+		}
+	}
+	return DetectFormat(data), nil
+}
+
+// FindEntriesByFourCC returns the 0-based MFT indices of the entries whose
+// decompressed contents start with fourCC, in MFT order. Only the first
+// len(fourCC) bytes of each entry are decoded. The scan stops once max
+// entries are found, or covers the whole MFT when max is 0. Rows marked
+// deleted are skipped, as ListEntries does. Entries that fail to decode are
+// skipped and their errors joined into the returned error, alongside the
+// indices found.
+func (datFile *DatFile) FindEntriesByFourCC(fourCC string, max int) ([]int, error) {
+	if fourCC == "" {
+		return nil, errors.New("empty fourCC")
+	}
+
+	var (
+		found []int
+		errs  []error
+	)
+	for index, mftEntry := range datFile.MFTData {
+		if max > 0 && len(found) == max {
+			break
+		}
+		if index <= MftEntryMftNum || mftEntry.IsEmpty() || mftEntry.IsDeleted() {
+			continue
+		}
+
+		head, err := datFile.extractEntryLimit(context.Background(), nil, index, uint32(len(fourCC)))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("MFT entry %d: %w", index, err))
+			continue
+		}
+		if string(head) == fourCC {
+			found = append(found, index)
+		}
+	}
+	return found, errors.Join(errs...)
+}
+
+// IsTexture reports whether f is one of the ATEX texture containers.
+func (f Format) IsTexture() bool {
+	switch f {
+	case FormatATEX, FormatATTX, FormatATEP, FormatATEC, FormatATEU:
+		return true
+	}
+	return false
+}
+
+func (f Format) String() string {
+	if name, ok := formatNames[f]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+var formatExtensions = map[Format]string{
+	FormatATEX:    ".dds",
+	FormatATTX:    ".dds",
+	FormatATEP:    ".dds",
+	FormatATEC:    ".dds",
+	FormatATEU:    ".dds",
+	FormatDDS:     ".dds",
+	FormatPF:      ".pf",
+	FormatMODL:    ".modl",
+	FormatStrings: ".strs",
+	FormatABNK:    ".abnk",
+	FormatAMSP:    ".amsp",
+	FormatMPEG:    ".mp3",
+	FormatWEBM:    ".webm",
+	FormatPNG:     ".png",
+	FormatJPEG:    ".jpg",
+}
+
+// ExtensionFor returns the file extension, including the leading dot, used
+// when saving an entry of format f. Unrecognised formats get ".bin".
+func ExtensionFor(f Format) string {
+	if extension, ok := formatExtensions[f]; ok {
+		return extension
+	}
+	return ".bin"
+}
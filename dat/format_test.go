@@ -0,0 +1,160 @@
+package dat
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want Format
+	}{
+		{"ATEX", "ATEXDXT1\x10\x00\x10\x00", FormatATEX},
+		{"ATTX", "ATTXDXT5", FormatATTX},
+		{"ATEU", "ATEU", FormatATEU},
+		{"DDS", "DDS \x7C\x00\x00\x00", FormatDDS},
+		{"strs", "strs\x00\x00", FormatStrings},
+		{"STRS", "STRS", FormatStrings},
+		{"MPEG with ID3 tag", "ID3\x03", FormatMPEG},
+		{"MPEG frame", "\xFF\xFB\x90", FormatMPEG},
+		{"WebM", "\x1A\x45\xDF\xA3", FormatWEBM},
+		{"PNG", "\x89PNG\r\n\x1A\n", FormatPNG},
+		{"JPEG", "\xFF\xD8\xFF\xE0", FormatJPEG},
+		{"PF model", "PF\x01\x00\x00\x00\x0C\x00MODL", FormatMODL},
+		{"PF audio bank", "PF\x01\x00\x00\x00\x0C\x00ABNK", FormatABNK},
+		{"PF of another type", "PF\x01\x00\x00\x00\x0C\x00AMAT", FormatPF},
+		{"PF header truncated", "PF\x01\x00", FormatPF},
+		{"magic truncated", "ATE", FormatUnknown},
+		{"unknown", "????", FormatUnknown},
+		{"empty", "", FormatUnknown},
+	}
+	for _, test := range tests {
+		if got := DetectFormat([]byte(test.data)); got != test.want {
+			t.Errorf("%s: DetectFormat = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestFormatNames(t *testing.T) {
+	tests := []struct {
+		format    Format
+		name      string
+		extension string
+		texture   bool
+	}{
+		{FormatATEX, "ATEX", ".dds", true},
+		{FormatATEU, "ATEU", ".dds", true},
+		{FormatDDS, "DDS", ".dds", false},
+		{FormatMODL, "MODL", ".modl", false},
+		{FormatStrings, "strs", ".strs", false},
+		{FormatJPEG, "JPEG", ".jpg", false},
+		{FormatUnknown, "unknown", ".bin", false},
+		{Format(1000), "unknown", ".bin", false},
+	}
+	for _, test := range tests {
+		if got := test.format.String(); got != test.name {
+			t.Errorf("Format(%d).String() = %q, want %q", test.format, got, test.name)
+		}
+		if got := ExtensionFor(test.format); got != test.extension {
+			t.Errorf("ExtensionFor(%v) = %q, want %q", test.format, got, test.extension)
+		}
+		if got := test.format.IsTexture(); got != test.texture {
+			t.Errorf("%v.IsTexture() = %v, want %v", test.format, got, test.texture)
+		}
+	}
+}
+
+// formatEntries are entries of several formats, with base IDs 4 to 8.
+var formatEntries = []testEntry{
+	{data: append([]byte("ATEXDXT1\x04\x00\x04\x00"), testPayload(100000)...), compressed: true},
+	{data: []byte("strs\x00\x00"), compressed: true},
+	{data: []byte("ATTXDXT5\x04\x00\x04\x00")},
+	{data: []byte{}},
+	{data: append([]byte("ATEX3DCX\x08\x00\x08\x00"), testPayload(10)...), compressed: true},
+}
+
+func TestPeekFormat(t *testing.T) {
+	datFile := testDat{entries: formatEntries}.open(t, Options{})
+
+	want := []Format{FormatATEX, FormatStrings, FormatATTX, FormatUnknown, FormatATEX}
+	for i, format := range want {
+		id := uint32(firstTestBaseID + i)
+		got, err := datFile.PeekFormat(id)
+		if err != nil {
+			t.Fatalf("PeekFormat(%d): %v", id, err)
+		}
+		if got != format {
+			t.Errorf("PeekFormat(%d) = %v, want %v", id, got, format)
+		}
+	}
+	if _, err := datFile.PeekFormat(999); err == nil {
+		t.Error("PeekFormat of an unknown base ID succeeded")
+	}
+}
+
+func TestFindEntriesByFourCC(t *testing.T) {
+	// A deleted row is neither found nor reported as failing to decode
+	deleted := testEntry{data: formatEntries[0].data, compressed: true, deleted: true}
+	datFile := testDat{entries: append(slices.Clone(formatEntries), deleted)}.open(t, Options{})
+	first := firstTestBaseID - 1 // MFT index of the first entry
+
+	tests := []struct {
+		fourCC string
+		max    int
+		want   []int
+	}{
+		{"ATEX", 0, []int{first, first + 4}},
+		{"ATEX", 1, []int{first}},
+		{"ATTX", 0, []int{first + 2}},
+		{"strs", 0, []int{first + 1}},
+		{"MODL", 0, nil},
+	}
+	for _, test := range tests {
+		got, err := datFile.FindEntriesByFourCC(test.fourCC, test.max)
+		if err != nil {
+			t.Fatalf("FindEntriesByFourCC(%q, %d): %v", test.fourCC, test.max, err)
+		}
+		if !slices.Equal(got, test.want) {
+			t.Errorf("FindEntriesByFourCC(%q, %d) = %v, want %v", test.fourCC, test.max, got, test.want)
+		}
+	}
+	if _, err := datFile.FindEntriesByFourCC("", 0); err == nil {
+		t.Error("FindEntriesByFourCC with an empty fourCC succeeded")
+	}
+}
+
+func TestParseATEX(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    ATEXTexture
+		badData bool // Rejected without ErrBadMagic
+		badType bool // Rejected with ErrBadMagic
+	}{
+		{"ATEX", "ATEXDXT1\x00\x01\x80\x00payload", ATEXTexture{"ATEX", "DXT1", 256, 128, []byte("payload")}, false, false},
+		{"ATTX without payload", "ATTX3DCX\x04\x00\x02\x00", ATEXTexture{"ATTX", "3DCX", 4, 2, []byte{}}, false, false},
+		{"header truncated", "ATEXDXT1\x00\x01", ATEXTexture{}, true, false},
+		{"not a texture", "strs\x00\x00\x00\x00\x00\x00\x00\x00", ATEXTexture{}, false, true},
+	}
+	for _, test := range tests {
+		tex, err := ParseATEX([]byte(test.data))
+		switch {
+		case test.badType:
+			if !errors.Is(err, ErrBadMagic) {
+				t.Errorf("%s: ParseATEX returned %v, want ErrBadMagic", test.name, err)
+			}
+		case test.badData:
+			if err == nil || errors.Is(err, ErrBadMagic) {
+				t.Errorf("%s: ParseATEX returned %v, want a truncation error", test.name, err)
+			}
+		case err != nil:
+			t.Errorf("%s: ParseATEX: %v", test.name, err)
+		case tex.FourCC != test.want.FourCC || tex.Format != test.want.Format ||
+			tex.Width != test.want.Width || tex.Height != test.want.Height || string(tex.Data) != string(test.want.Data):
+			t.Errorf("%s: ParseATEX = %+v, want %+v", test.name, *tex, test.want)
+		}
+	}
+}
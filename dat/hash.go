@@ -0,0 +1,115 @@
+package dat
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// EntryHash returns the SHA-256 of the decompressed contents of the MFT row at
+// the 0-based index. With Options.CacheSize set the entry is read through the
+// cache, like Extract; otherwise it is streamed through the hash rather than
+// buffered. The result is remembered until Refresh reloads the MFT.
+func (datFile *DatFile) EntryHash(index int) ([sha256.Size]byte, error) {
+	if index < 0 || index >= len(datFile.MFTData) {
+		return [sha256.Size]byte{}, fmt.Errorf("MFT index %d out of range [0, %d): %w", index, len(datFile.MFTData), ErrEntryNotFound)
+	}
+
+	datFile.hashMu.Lock()
+	sum, ok := datFile.hashes[index]
+	datFile.hashMu.Unlock()
+	if ok {
+		return sum, nil
+	}
+
+	if datFile.cache != nil {
+		data, err := datFile.extractEntry(context.Background(), index)
+		if err != nil {
+			return [sha256.Size]byte{}, err
+		}
+		sum = sha256.Sum256(data)
+	} else {
+		hash := sha256.New()
+		if _, err := datFile.writeEntryTo(index, hash); err != nil {
+			return [sha256.Size]byte{}, err
+		}
+		hash.Sum(sum[:0])
+	}
+
+	datFile.hashMu.Lock()
+	if datFile.hashes == nil {
+		datFile.hashes = make(map[int][sha256.Size]byte)
+	}
+	datFile.hashes[index] = sum
+	datFile.hashMu.Unlock()
+	return sum, nil
+}
+
+// FindDuplicatesOptions controls FindDuplicatesWith.
+type FindDuplicatesOptions struct {
+	Workers int // Entries hashed concurrently, 1 when less than 1
+}
+
+// FindDuplicates groups the MFT rows with identical decompressed contents. See
+// FindDuplicatesWith.
+func (datFile *DatFile) FindDuplicates() (map[[sha256.Size]byte][]int, error) {
+	return datFile.FindDuplicatesWith(FindDuplicatesOptions{})
+}
+
+// FindDuplicatesWith hashes with EntryHash every non-empty, non-reserved MFT
+// row not marked deleted, which ListEntries leaves out too, and returns the
+// 0-based indices of the rows sharing a hash, keyed by that hash and in
+// ascending order. Rows with unique contents are left out. Entries that fail
+// to extract are skipped and their errors joined into the returned error,
+// alongside the groups found.
+func (datFile *DatFile) FindDuplicatesWith(opts FindDuplicatesOptions) (map[[sha256.Size]byte][]int, error) {
+	workers := max(opts.Workers, 1)
+
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		groups = make(map[[sha256.Size]byte][]int)
+		errs   []error
+		jobs   = make(chan int)
+	)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for index := range jobs {
+				sum, err := datFile.EntryHash(index)
+
+				mu.Lock()
+				if err != nil {
+					errs = append(errs, fmt.Errorf("MFT entry %d: %w", index, err))
+				} else {
+					groups[sum] = append(groups[sum], index)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for index, mftEntry := range datFile.MFTData {
+		if index <= MftEntryMftNum || mftEntry.IsEmpty() || mftEntry.IsDeleted() {
+			continue
+		}
+		jobs <- index
+	}
+	close(jobs)
+	wg.Wait()
+
+	for sum, indices := range groups {
+		if len(indices) < 2 {
+			delete(groups, sum)
+			continue
+		}
+		slices.Sort(indices)
+	}
+	return groups, errors.Join(errs...)
+}
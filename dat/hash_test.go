@@ -0,0 +1,107 @@
+package dat
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestEntryHash(t *testing.T) {
+	entries := append(slices.Clone(testEntries), testEntry{data: []byte("secret"), flag: EntryFlagEncrypted})
+	datFile := testDat{entries: entries}.open(t, Options{})
+	first := firstTestBaseID - 1
+
+	for i, entry := range testEntries {
+		index := first + i
+		got, err := datFile.EntryHash(index)
+		if err != nil {
+			t.Errorf("EntryHash(%d): %v", index, err)
+			continue
+		}
+		if want := sha256.Sum256(entry.data); got != want {
+			t.Errorf("EntryHash(%d) = %x, want %x", index, got, want)
+		}
+		if again, err := datFile.EntryHash(index); err != nil || again != got {
+			t.Errorf("EntryHash(%d) a second time = %x, %v", index, again, err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		index int
+		want  error
+	}{
+		{"negative", -1, ErrEntryNotFound},
+		{"past the end", len(datFile.MFTData), ErrEntryNotFound},
+		{"encrypted", first + len(testEntries), ErrEncryptedEntry},
+	}
+	for _, test := range tests {
+		if _, err := datFile.EntryHash(test.index); !errors.Is(err, test.want) {
+			t.Errorf("%s: EntryHash(%d) returned %v, want %v", test.name, test.index, err, test.want)
+		}
+	}
+}
+
+func TestEntryHashCached(t *testing.T) {
+	datFile := testDat{entries: testEntries}.open(t, Options{CacheSize: 1 << 20})
+	compressed := firstTestBaseID // MFT index of the 3000 byte compressed entry
+
+	// The hash is of what the cache holds, so it reads through the cache
+	datFile.cache.put(compressed, []byte("cached"))
+	if got, err := datFile.EntryHash(compressed); err != nil || got != sha256.Sum256([]byte("cached")) {
+		t.Errorf("EntryHash of a cached entry = %x, %v, want the hash of the cached data", got, err)
+	}
+
+	// Hashing fills the cache like Extract does
+	large := compressed + 1
+	if _, err := datFile.EntryHash(large); err != nil {
+		t.Fatalf("EntryHash(%d): %v", large, err)
+	}
+	if data, ok := datFile.cache.get(large); !ok || !bytes.Equal(data, testEntries[2].data) {
+		t.Errorf("entry %d not cached after EntryHash", large)
+	}
+}
+
+func TestFindDuplicates(t *testing.T) {
+	payload := testPayload(3000)
+	entries := []testEntry{
+		{data: payload, compressed: true},
+		{data: []byte("unique")},
+		{data: payload},
+		{data: []byte("twice")},
+		{data: []byte{}},
+		{data: []byte("twice"), compressed: true},
+		{data: payload, compressed: true},
+		{data: []byte{}},
+		{data: payload, deleted: true}, // Not hashed, so neither grouped nor failing
+	}
+	first := firstTestBaseID - 1
+	want := map[[sha256.Size]byte][]int{
+		sha256.Sum256(payload):         {first, first + 2, first + 6},
+		sha256.Sum256([]byte("twice")): {first + 3, first + 5},
+	}
+
+	for _, cacheSize := range []int64{0, 1 << 20} {
+		for _, workers := range []int{0, 1, 4} {
+			datFile := testDat{entries: entries}.open(t, Options{CacheSize: cacheSize})
+			groups, err := datFile.FindDuplicatesWith(FindDuplicatesOptions{Workers: workers})
+			if err != nil {
+				t.Fatalf("cache %d, %d workers: FindDuplicatesWith: %v", cacheSize, workers, err)
+			}
+			if !maps.EqualFunc(groups, want, slices.Equal) {
+				t.Errorf("cache %d, %d workers: FindDuplicatesWith = %v, want %v", cacheSize, workers, groups, want)
+			}
+		}
+	}
+
+	// Failing entries are reported and the others still grouped
+	entries = append(entries, testEntry{data: []byte("secret"), flag: EntryFlagEncrypted})
+	datFile := testDat{entries: entries}.open(t, Options{})
+	groups, err := datFile.FindDuplicates()
+	if !errors.Is(err, ErrEncryptedEntry) || len(groups) != len(want) {
+		t.Errorf("FindDuplicates with an encrypted entry returned %d groups, %v; want %d groups and ErrEncryptedEntry", len(groups), err, len(want))
+	}
+}
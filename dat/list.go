@@ -0,0 +1,150 @@
+package dat
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+)
+
+// EntryInfo describes one MFT row without reading its data.
+type EntryInfo struct {
+	Index           int // 0-based index into MFTData
+	Offset          uint64
+	Size            uint32
+	CompressionFlag uint16
+	EntryFlag       uint16
+	CRC             uint32
+	BaseID          uint32   // 1-based row number, Index+1
+	FileIDs         []uint32 // File IDs resolving to this row, in ascending order
+}
+
+// Compressed reports whether the entry data is stored compressed.
+func (info EntryInfo) Compressed() bool {
+	return info.CompressionFlag != CompressionNone
+}
+
+// ListOptions selects the rows ListEntriesWith returns.
+type ListOptions struct {
+	IncludeDeleted bool // Also list rows marked deleted, see MFTEntry.IsDeleted
+}
+
+// ListEntries returns an EntryInfo for every MFT row not marked deleted, in
+// MFT order. See ListEntriesWith.
+func (datFile *DatFile) ListEntries() []EntryInfo {
+	return datFile.ListEntriesWith(ListOptions{})
+}
+
+// ListEntriesWith returns an EntryInfo for every MFT row selected by opts, in
+// MFT order. Nothing is read from the dat, so listing is cheap even for large
+// archives.
+func (datFile *DatFile) ListEntriesWith(opts ListOptions) []EntryInfo {
+	entries := make([]EntryInfo, 0, len(datFile.MFTData))
+	for i, mftEntry := range datFile.MFTData {
+		if mftEntry.IsDeleted() && !opts.IncludeDeleted {
+			continue
+		}
+		baseID := uint32(i + 1)
+		entries = append(entries, EntryInfo{
+			Index:           i,
+			Offset:          mftEntry.Offset,
+			Size:            mftEntry.Size,
+			CompressionFlag: mftEntry.CompressionFlag,
+			EntryFlag:       mftEntry.EntryFlag,
+			CRC:             mftEntry.CRC,
+			BaseID:          baseID,
+			FileIDs:         datFile.baseFileIDs[baseID],
+		})
+	}
+	return entries
+}
+
+// ScanEntries returns an iterator over the MFT rows of the dat of the given
+// size read from r, in MFT order, so a row's 0-based index is its position in
+// the iteration. Unlike OpenReaderAt, which loads the whole MFT into MFTData,
+// rows are read as the iteration reaches them and only a small read buffer is
+// held. A dat or MFT header that does not parse, or a row that cannot be read,
+// is yielded as an error and ends the iteration.
+func ScanEntries(r io.ReaderAt, size int64) iter.Seq2[MFTEntry, error] {
+	return func(yield func(MFTEntry, error) bool) {
+		scan := &DatFile{reader: r, size: size}
+		file := io.NewSectionReader(r, 0, size)
+		if err := scan.loadHeaders(file); err != nil {
+			yield(MFTEntry{}, err)
+			return
+		}
+
+		br := bufio.NewReader(file)
+		narrow := scan.Header.NarrowOffsets()
+		for index := 0; index < int(scan.MFTHeader.NumEntries); index++ {
+			var mftEntry MFTEntry
+			if err := readMFTEntry(br, &mftEntry, index, narrow); err != nil {
+				yield(MFTEntry{}, fmt.Errorf("failed to read MFT data: %w", err))
+				return
+			}
+			if !yield(mftEntry, nil) {
+				return
+			}
+		}
+	}
+}
+
+// ForEachOptions selects the entries ForEach visits and how it handles
+// entries that cannot be extracted.
+type ForEachOptions struct {
+	SkipCompressed  bool
+	IncludeDeleted  bool   // Also visit rows marked deleted, see MFTEntry.IsDeleted
+	IncludeReserved bool   // Also visit the header, index and MFT rows, see SystemEntries
+	MinSize         uint32 // Smallest on-disk size visited
+	MaxSize         uint32 // Largest on-disk size visited, no limit when 0
+
+	// OnError is called for each entry that fails to extract. Returning an
+	// error stops the walk with it; returning nil skips the entry. When unset,
+	// failing entries are skipped and their errors joined into the result.
+	OnError func(index int, err error) error
+}
+
+// ForEach calls fn with the contents of every MFT row in order, skipping
+// the reserved rows, as FindDuplicates does, and entries that fail to
+// extract. See ForEachWith.
+func (datFile *DatFile) ForEach(fn func(index int, info EntryInfo, data []byte) error) error {
+	return datFile.ForEachWith(ForEachOptions{}, fn)
+}
+
+// ForEachWith calls fn with the decompressed contents of every MFT row
+// selected by opts, in MFT order. An error returned by fn stops the walk
+// and is returned as is.
+func (datFile *DatFile) ForEachWith(opts ForEachOptions, fn func(index int, info EntryInfo, data []byte) error) error {
+	var errs []error
+	for _, info := range datFile.ListEntriesWith(ListOptions{IncludeDeleted: opts.IncludeDeleted}) {
+		if info.Index <= MftEntryMftNum && !opts.IncludeReserved {
+			continue
+		}
+		if opts.SkipCompressed && info.Compressed() {
+			continue
+		}
+		if info.Size < opts.MinSize || (opts.MaxSize != 0 && info.Size > opts.MaxSize) {
+			continue
+		}
+
+		data, err := datFile.extractEntry(context.Background(), info.Index)
+		if err != nil {
+			err = fmt.Errorf("MFT entry %d: %w", info.Index, err)
+			if opts.OnError == nil {
+				errs = append(errs, err)
+				continue
+			}
+			if err := opts.OnError(info.Index, err); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := fn(info.Index, info, data); err != nil {
+			return err
+		}
+	}
+	return errors.Join(errs...)
+}
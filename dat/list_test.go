@@ -0,0 +1,246 @@
+package dat
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestListEntries(t *testing.T) {
+	datFile := testDat{
+		entries: testEntries,
+		fileIDs: [][2]uint32{{100, firstTestBaseID + 1}, {101, firstTestBaseID}, {102, firstTestBaseID + 1}},
+	}.open(t, Options{})
+
+	entries := datFile.ListEntries()
+	if len(entries) != len(datFile.MFTData) {
+		t.Fatalf("ListEntries returned %d entries, want %d", len(entries), len(datFile.MFTData))
+	}
+	for i, info := range entries {
+		mftEntry := datFile.MFTData[i]
+		switch {
+		case info.Index != i || info.BaseID != uint32(i+1):
+			t.Errorf("entry %d has index %d and base ID %d", i, info.Index, info.BaseID)
+		case info.Offset != mftEntry.Offset || info.Size != mftEntry.Size || info.CompressionFlag != mftEntry.CompressionFlag:
+			t.Errorf("entry %d = %+v, does not match MFT row %+v", i, info, mftEntry)
+		case info.Compressed() != mftEntry.IsCompressed():
+			t.Errorf("entry %d: Compressed() = %v, want %v", i, info.Compressed(), mftEntry.IsCompressed())
+		}
+	}
+
+	tests := []struct {
+		baseID  uint32
+		fileIDs []uint32
+	}{
+		{firstTestBaseID, []uint32{101}},
+		{firstTestBaseID + 1, []uint32{100, 102}},
+		{firstTestBaseID + 2, nil},
+	}
+	for _, test := range tests {
+		if got := entries[test.baseID-1].FileIDs; !slices.Equal(got, test.fileIDs) {
+			t.Errorf("base ID %d lists file IDs %v, want %v", test.baseID, got, test.fileIDs)
+		}
+	}
+}
+
+func TestForEach(t *testing.T) {
+	entries := append(slices.Clone(testEntries), testEntry{data: []byte("secret"), flag: EntryFlagEncrypted})
+	datFile := testDat{entries: entries}.open(t, Options{})
+	encrypted := firstTestBaseID - 1 + len(testEntries)
+	first := firstTestBaseID - 1
+
+	tests := []struct {
+		name    string
+		opts    ForEachOptions
+		want    []int // Indices visited among the reserved rows and the test entries
+		failing bool  // The encrypted entry is selected and fails
+	}{
+		{"all", ForEachOptions{}, []int{first, first + 1, first + 2, first + 3}, true},
+		{"skip compressed", ForEachOptions{SkipCompressed: true}, []int{first, first + 3}, true},
+		{"min size", ForEachOptions{MinSize: 13}, []int{first + 1, first + 2}, false},
+		{"max size", ForEachOptions{MinSize: 1, MaxSize: 12}, []int{first}, true},
+		{"reserved rows", ForEachOptions{IncludeReserved: true}, []int{MftEntryHeaderNum, MftEntryIndexNum, MftEntryMftNum, first, first + 1, first + 2, first + 3}, true},
+	}
+	for _, test := range tests {
+		var visited []int
+		err := datFile.ForEachWith(test.opts, func(index int, info EntryInfo, data []byte) error {
+			if info.Index != index {
+				t.Errorf("%s: info.Index = %d for index %d", test.name, info.Index, index)
+			}
+			if index <= MftEntryMftNum {
+				visited = append(visited, index)
+			} else if index >= first {
+				visited = append(visited, index)
+				if want := entries[index-first].data; !bytes.Equal(data, want) {
+					t.Errorf("%s: entry %d has %d bytes not matching its data", test.name, index, len(data))
+				}
+			}
+			return nil
+		})
+		if errors.Is(err, ErrEncryptedEntry) != test.failing || (err != nil && !test.failing) {
+			t.Errorf("%s: ForEachWith returned %v, failing entry selected %v", test.name, err, test.failing)
+		}
+		if !slices.Equal(visited, test.want) {
+			t.Errorf("%s: visited %v, want %v", test.name, visited, test.want)
+		}
+	}
+
+	var skipped []int
+	err := datFile.ForEachWith(ForEachOptions{OnError: func(index int, err error) error {
+		skipped = append(skipped, index)
+		return nil
+	}}, func(int, EntryInfo, []byte) error { return nil })
+	if err != nil || !slices.Equal(skipped, []int{encrypted}) {
+		t.Errorf("ForEachWith with OnError skipping returned %v and skipped %v, want nil and [%d]", err, skipped, encrypted)
+	}
+
+	stop := errors.New("stop")
+	if err := datFile.ForEachWith(ForEachOptions{OnError: func(int, error) error { return stop }}, func(int, EntryInfo, []byte) error { return nil }); err != stop {
+		t.Errorf("ForEachWith with OnError stopping returned %v, want its error", err)
+	}
+	visits := 0
+	if err := datFile.ForEach(func(int, EntryInfo, []byte) error { visits++; return stop }); err != stop || visits != 1 {
+		t.Errorf("ForEach whose callback fails returned %v after %d visits, want its error after 1", err, visits)
+	}
+}
+
+func TestScanEntries(t *testing.T) {
+	for _, narrow := range []bool{false, true} {
+		data := testDat{entries: writeEntries, fileIDs: [][2]uint32{{100, firstTestBaseID}}, narrow: narrow}.build(t)
+		datFile, err := OpenReaderAt(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var rows []MFTEntry
+		for mftEntry, err := range ScanEntries(bytes.NewReader(data), int64(len(data))) {
+			if err != nil {
+				t.Fatalf("narrow %v: ScanEntries: %v", narrow, err)
+			}
+			rows = append(rows, mftEntry)
+		}
+		if !slices.Equal(rows, datFile.MFTData) {
+			t.Errorf("narrow %v: ScanEntries yielded %v, want the loaded rows %v", narrow, rows, datFile.MFTData)
+		}
+
+		// Breaking out of the loop stops the scan
+		count := 0
+		for range ScanEntries(bytes.NewReader(data), int64(len(data))) {
+			count++
+			if count == 2 {
+				break
+			}
+		}
+		if count != 2 {
+			t.Errorf("narrow %v: %d rows after breaking at the second", narrow, count)
+		}
+	}
+
+	valid := testDat{entries: testEntries}.build(t)
+	mftOffset := bytes.Index(valid, []byte("Mft\x1A"))
+	// The rows are read in one go, starting after the MFT header
+	unreadable := &faultyReaderAt{
+		r:     bytes.NewReader(valid),
+		at:    int64(mftOffset + binary.Size(MFTHeader{})),
+		fault: func(p []byte) (int, error) { return 0, errors.New("read failed") },
+	}
+	tests := []struct {
+		name     string
+		r        io.ReaderAt
+		size     int
+		badMagic bool
+	}{
+		{"dat identifier", bytes.NewReader(append([]byte{valid[0], 'X'}, valid[2:]...)), len(valid), true},
+		{"MFT identifier", bytes.NewReader(append(append(bytes.Clone(valid[:mftOffset]), 'X'), valid[mftOffset+1:]...)), len(valid), true},
+		{"truncated header", bytes.NewReader(valid[:20]), 20, false},
+		{"truncated MFT", bytes.NewReader(valid), len(valid) - 10, false},
+		{"unreadable rows", unreadable, len(valid), false},
+	}
+	for _, test := range tests {
+		rows := 0
+		var scanErr error
+		for _, err := range ScanEntries(test.r, int64(test.size)) {
+			if err != nil {
+				scanErr = err
+				continue
+			}
+			rows++
+		}
+		if scanErr == nil || rows != 0 {
+			t.Errorf("%s: ScanEntries yielded %d rows and error %v, want only an error", test.name, rows, scanErr)
+		}
+		if errors.Is(scanErr, ErrBadMagic) != test.badMagic {
+			t.Errorf("%s: ScanEntries returned %v, ErrBadMagic expected %v", test.name, scanErr, test.badMagic)
+		}
+	}
+}
+
+func TestIsDeleted(t *testing.T) {
+	entries := []testEntry{
+		{data: []byte("deleted entry"), deleted: true},
+		{data: []byte("stored entry")},
+	}
+	tests := []struct {
+		name    string
+		narrow  bool
+		offset  uint64 // Written to the second row when not 0
+		deleted []bool // IsDeleted of the two rows
+	}{
+		{"wide", false, 0, []bool{true, false}},
+		{"narrow", true, 0, []bool{true, false}},
+		{"wide 32-bit all-ones offset", false, math.MaxUint32, []bool{true, false}},
+	}
+	for _, test := range tests {
+		data := testDat{entries: entries, narrow: test.narrow}.build(t)
+		if test.offset != 0 {
+			mftOffset := bytes.Index(data, []byte("Mft\x1A"))
+			row := mftOffset + binary.Size(MFTHeader{}) + firstTestBaseID*binary.Size(MFTEntry{})
+			binary.LittleEndian.PutUint64(data[row:], test.offset)
+		}
+		datFile, err := OpenReaderAt(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatalf("%s: OpenReaderAt: %v", test.name, err)
+		}
+
+		for i, want := range test.deleted {
+			if row := datFile.MFTData[firstTestBaseID-1+i]; row.IsDeleted() != want {
+				t.Errorf("%s: row %d with offset %#x: IsDeleted() = %v", test.name, i, row.Offset, !want)
+			}
+		}
+		listed := len(datFile.ListEntries())
+		if all := len(datFile.ListEntriesWith(ListOptions{IncludeDeleted: true})); listed != all-1 {
+			t.Errorf("%s: ListEntries returned %d of %d rows, want all but the deleted one", test.name, listed, all)
+		}
+	}
+}
+
+func TestFileIDsForBaseID(t *testing.T) {
+	datFile := testDat{
+		entries: testEntries,
+		fileIDs: [][2]uint32{{102, firstTestBaseID + 1}, {101, firstTestBaseID}, {100, firstTestBaseID + 1}},
+	}.open(t, Options{})
+
+	tests := []struct {
+		baseID  uint32
+		fileIDs []uint32
+	}{
+		{firstTestBaseID, []uint32{101}},
+		{firstTestBaseID + 1, []uint32{100, 102}}, // Sorted, unlike the index table
+		{firstTestBaseID + 2, nil},
+	}
+	for _, test := range tests {
+		if got, err := datFile.FileIDsForBaseID(test.baseID); err != nil || !slices.Equal(got, test.fileIDs) {
+			t.Errorf("FileIDsForBaseID(%d) = %v, %v; want %v", test.baseID, got, err, test.fileIDs)
+		}
+	}
+
+	for _, baseID := range []uint32{0, uint32(len(datFile.MFTData) + 1), math.MaxUint32} {
+		if got, err := datFile.FileIDsForBaseID(baseID); !errors.Is(err, ErrEntryNotFound) {
+			t.Errorf("FileIDsForBaseID(%d) of an unknown base ID = %v, %v; want ErrEntryNotFound", baseID, got, err)
+		}
+	}
+}
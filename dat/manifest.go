@@ -0,0 +1,57 @@
+package dat
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Manifest maps human-readable asset names to file IDs.
+type Manifest map[string]uint32
+
+// LoadManifest reads a manifest in CSV form, one "fileID,name" record per
+// line. Lines starting with '#' are ignored.
+func LoadManifest(r io.Reader) (Manifest, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 2
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	manifest := Manifest{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return manifest, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading manifest: %w", err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		fileID, err := strconv.ParseUint(strings.TrimSpace(record[0]), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("manifest line %d: invalid file ID %q", line, record[0])
+		}
+		name := strings.TrimSpace(record[1])
+		if previous, ok := manifest[name]; ok && previous != uint32(fileID) {
+			return nil, fmt.Errorf("manifest line %d: %q already maps to file ID %d", line, name, previous)
+		}
+		manifest[name] = uint32(fileID)
+	}
+}
+
+// ExtractByName returns the contents of the entry named in datFile.Manifest,
+// resolving the name to a file ID and then through MFTIndexData.
+func (datFile *DatFile) ExtractByName(name string) ([]byte, error) {
+	if datFile.Manifest == nil {
+		return nil, errors.New("no manifest loaded")
+	}
+	fileID, ok := datFile.Manifest[name]
+	if !ok {
+		return nil, fmt.Errorf("%q is not in the manifest", name)
+	}
+	return datFile.ExtractByFileID(fileID)
+}
@@ -0,0 +1,62 @@
+package dat
+
+import (
+	"bytes"
+	"maps"
+	"strings"
+	"testing"
+)
+
+func TestLoadManifest(t *testing.T) {
+	tests := []struct {
+		name    string
+		csv     string
+		want    Manifest
+		wantErr bool
+	}{
+		{"empty", "", Manifest{}, false},
+		{"records", "100,ui/icon.png\n101, strings/en.strs\n", Manifest{"ui/icon.png": 100, "strings/en.strs": 101}, false},
+		{"comments and quoting", "# fileID,name\n102,\"maps/lion's arch, west\"\n", Manifest{"maps/lion's arch, west": 102}, false},
+		{"repeated name, same file ID", "100,a\n100,a\n", Manifest{"a": 100}, false},
+		{"names sharing a file ID", "100,a\n100,b\n", Manifest{"a": 100, "b": 100}, false},
+		{"repeated name, other file ID", "100,a\n101,a\n", nil, true},
+		{"invalid file ID", "abc,a\n", nil, true},
+		{"file ID past 32 bits", "4294967296,a\n", nil, true},
+		{"missing name", "100\n", nil, true},
+	}
+	for _, test := range tests {
+		got, err := LoadManifest(strings.NewReader(test.csv))
+		switch {
+		case test.wantErr:
+			if err == nil {
+				t.Errorf("%s: LoadManifest succeeded", test.name)
+			}
+		case err != nil:
+			t.Errorf("%s: LoadManifest: %v", test.name, err)
+		case !maps.Equal(got, test.want):
+			t.Errorf("%s: LoadManifest = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestExtractByName(t *testing.T) {
+	datFile := testDat{entries: testEntries, fileIDs: [][2]uint32{{100, firstTestBaseID + 1}}}.open(t, Options{})
+
+	if _, err := datFile.ExtractByName("entry"); err == nil {
+		t.Error("ExtractByName without a manifest succeeded")
+	}
+
+	datFile.Manifest = Manifest{"entry": 100, "missing": 999}
+	got, err := datFile.ExtractByName("entry")
+	if err != nil {
+		t.Fatalf("ExtractByName: %v", err)
+	}
+	if !bytes.Equal(got, testEntries[1].data) {
+		t.Errorf("ExtractByName returned %d bytes not matching the entry", len(got))
+	}
+	for _, name := range []string{"unknown", "missing"} {
+		if _, err := datFile.ExtractByName(name); err == nil {
+			t.Errorf("ExtractByName(%q) succeeded", name)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package dat
+
+import "encoding/json"
+
+// Metadata is a JSON view of the header and MFT of a dat. Offsets and sizes
+// are plain JSON numbers; the largest offset a dat can hold is far below
+// 2^53, so they survive decoding as float64.
+type Metadata struct {
+	Header    HeaderMetadata    `json:"header"`
+	MFTHeader MFTHeaderMetadata `json:"mftHeader"`
+	Entries   []EntryMetadata   `json:"entries"`
+}
+
+// HeaderMetadata mirrors Header.
+type HeaderMetadata struct {
+	Version       uint8  `json:"version"`
+	Identifier    string `json:"identifier"`
+	HeaderSize    uint32 `json:"headerSize"`
+	UnknownField  uint32 `json:"unknownField"`
+	ChunkSize     uint32 `json:"chunkSize"`
+	CRC           uint32 `json:"crc"`
+	UnknownField2 uint32 `json:"unknownField2"`
+	MftOffset     uint64 `json:"mftOffset"`
+	MftSize       uint32 `json:"mftSize"`
+	Flags         uint32 `json:"flags"`
+}
+
+// MFTHeaderMetadata mirrors MFTHeader.
+type MFTHeaderMetadata struct {
+	Identifier    string `json:"identifier"`
+	Unknown       uint64 `json:"unknown"`
+	NumEntries    uint32 `json:"numEntries"`
+	UnknownField2 uint32 `json:"unknownField2"`
+	UnknownField3 uint32 `json:"unknownField3"`
+}
+
+// EntryMetadata summarizes one MFT row.
+type EntryMetadata struct {
+	Index           int    `json:"index"`
+	Offset          uint64 `json:"offset"`
+	Size            uint32 `json:"size"`
+	CompressionFlag uint16 `json:"compressionFlag"`
+	EntryFlag       uint16 `json:"entryFlag"`
+	CRC             uint32 `json:"crc"`
+}
+
+// Metadata returns the header and MFT of the dat as a Metadata view.
+func (datFile *DatFile) Metadata() Metadata {
+	header, mftHeader := datFile.Header, datFile.MFTHeader
+	metadata := Metadata{
+		Header: HeaderMetadata{
+			Version:       header.Version,
+			Identifier:    string(header.Identifier[:]),
+			HeaderSize:    header.HeaderSize,
+			UnknownField:  header.UnknownField,
+			ChunkSize:     header.ChunkSize,
+			CRC:           header.CRC,
+			UnknownField2: header.UnknownField2,
+			MftOffset:     header.MftOffset,
+			MftSize:       header.MftSize,
+			Flags:         header.Flags,
+		},
+		MFTHeader: MFTHeaderMetadata{
+			Identifier:    string(mftHeader.Identifier[:]),
+			Unknown:       mftHeader.Unknown,
+			NumEntries:    mftHeader.NumEntries,
+			UnknownField2: mftHeader.UnknownField2,
+			UnknownField3: mftHeader.UnknownField3,
+		},
+		Entries: make([]EntryMetadata, len(datFile.MFTData)),
+	}
+	for i, mftEntry := range datFile.MFTData {
+		metadata.Entries[i] = EntryMetadata{
+			Index:           i,
+			Offset:          mftEntry.Offset,
+			Size:            mftEntry.Size,
+			CompressionFlag: mftEntry.CompressionFlag,
+			EntryFlag:       mftEntry.EntryFlag,
+			CRC:             mftEntry.CRC,
+		}
+	}
+	return metadata
+}
+
+// MetadataJSON returns Metadata encoded as JSON. Entry data is not included.
+func (datFile *DatFile) MetadataJSON() ([]byte, error) {
+	return json.Marshal(datFile.Metadata())
+}
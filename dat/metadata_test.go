@@ -0,0 +1,43 @@
+package dat
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMetadataJSON(t *testing.T) {
+	datFile := testDat{entries: testEntries, narrow: true}.open(t, Options{})
+
+	encoded, err := datFile.MetadataJSON()
+	if err != nil {
+		t.Fatalf("MetadataJSON: %v", err)
+	}
+	var decoded Metadata
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("decoding MetadataJSON: %v", err)
+	}
+
+	header := decoded.Header
+	switch {
+	case header.Version != DatVersion || header.Identifier != DatIdentifier:
+		t.Errorf("header version %#x, identifier %q", header.Version, header.Identifier)
+	case header.MftOffset != datFile.Header.MftOffset || header.MftSize != datFile.Header.MftSize:
+		t.Errorf("header MFT at %d, size %d, want %d, %d", header.MftOffset, header.MftSize, datFile.Header.MftOffset, datFile.Header.MftSize)
+	case header.Flags != HeaderFlagNarrowOffsets || header.ChunkSize != datFile.Header.ChunkSize:
+		t.Errorf("header flags %#x, chunk size %d", header.Flags, header.ChunkSize)
+	}
+	if decoded.MFTHeader.Identifier != "Mft\x1A" || decoded.MFTHeader.NumEntries != datFile.MFTHeader.NumEntries {
+		t.Errorf("MFT header identifier %q, %d entries", decoded.MFTHeader.Identifier, decoded.MFTHeader.NumEntries)
+	}
+
+	if len(decoded.Entries) != len(datFile.MFTData) {
+		t.Fatalf("%d entries, want %d", len(decoded.Entries), len(datFile.MFTData))
+	}
+	for i, entry := range decoded.Entries {
+		mftEntry := datFile.MFTData[i]
+		want := EntryMetadata{i, mftEntry.Offset, mftEntry.Size, mftEntry.CompressionFlag, mftEntry.EntryFlag, mftEntry.CRC}
+		if entry != want {
+			t.Errorf("entry %d = %+v, want %+v", i, entry, want)
+		}
+	}
+}
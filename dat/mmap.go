@@ -0,0 +1,38 @@
+package dat
+
+import (
+	"fmt"
+	"os"
+)
+
+// OpenMapped is like Open but memory-maps the file, so entries are read as
+// subslices of the mapping. Uncompressed entries are returned without copying
+// and must not be modified; they stay valid until Close is called.
+func OpenMapped(filePath string) (*DatFile, error) {
+	return OpenWithOptions(filePath, Options{Mapped: true})
+}
+
+// mapFile memory-maps the open file for readMapped.
+func (datFile *DatFile) mapFile() error {
+	datFile.debug("Mapping .dat file")
+	file, ok := datFile.file.(*os.File)
+	if !ok {
+		return fmt.Errorf("failed to map file: not a file on the local filesystem")
+	}
+	mapping, err := mmapFile(file)
+	if err != nil {
+		datFile.debug("Failed to map .dat file", "error", err)
+		return fmt.Errorf("failed to map file: %w", err)
+	}
+	datFile.mapping = mapping
+	return nil
+}
+
+// readMapped returns the bytes of mftEntry as a subslice of the mapping.
+func (datFile *DatFile) readMapped(index int, mftEntry MFTEntry) ([]byte, error) {
+	if !spanFits(mftEntry.Offset, uint64(mftEntry.Size), uint64(len(datFile.mapping))) {
+		return nil, fmt.Errorf("MFT entry %d extends past the end of the file", index)
+	}
+	end := mftEntry.Offset + uint64(mftEntry.Size)
+	return datFile.mapping[mftEntry.Offset:end:end], nil
+}
@@ -0,0 +1,19 @@
+//go:build !unix
+
+package dat
+
+import (
+	"errors"
+	"os"
+)
+
+// errMmapUnsupported is returned by OpenMapped where mmap is unavailable.
+var errMmapUnsupported = errors.New("memory mapping is not supported on this platform")
+
+func mmapFile(file *os.File) ([]byte, error) {
+	return nil, errMmapUnsupported
+}
+
+func munmap(mapping []byte) error {
+	return nil
+}
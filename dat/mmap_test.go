@@ -0,0 +1,31 @@
+//go:build unix
+
+package dat
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestOpenMapped(t *testing.T) {
+	path := testDat{entries: testEntries}.write(t)
+	datFile, err := OpenMapped(path)
+	if err != nil {
+		t.Fatalf("OpenMapped: %v", err)
+	}
+
+	for i, entry := range testEntries {
+		id := uint32(firstTestBaseID + i)
+		got, err := datFile.ExtractByBaseID(id)
+		if err != nil || !bytes.Equal(got, entry.data) {
+			t.Errorf("ExtractByBaseID(%d) returned %d bytes, %v; want the %d byte entry", id, len(got), err, len(entry.data))
+		}
+	}
+
+	if err := datFile.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, err := datFile.ExtractByBaseID(firstTestBaseID); err == nil {
+		t.Error("ExtractByBaseID after Close succeeded")
+	}
+}
@@ -0,0 +1,27 @@
+//go:build unix
+
+package dat
+
+import (
+	"os"
+	"syscall"
+)
+
+// mmapFile maps the whole file read-only.
+func mmapFile(file *os.File) ([]byte, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() == 0 {
+		return []byte{}, nil
+	}
+	return syscall.Mmap(int(file.Fd()), 0, int(info.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+func munmap(mapping []byte) error {
+	if len(mapping) == 0 {
+		return nil
+	}
+	return syscall.Munmap(mapping)
+}
@@ -0,0 +1,28 @@
+package dat
+
+import "fmt"
+
+// Model is a PF file of type MODL. It only enumerates the chunks of the
+// model; the submesh layout inside GEOM differs between chunk versions and
+// is not decoded yet.
+type Model struct {
+	PF       *PFFile
+	Geometry *PFChunk // GEOM chunk holding the meshes, if present
+	Chunks   []PFChunk
+}
+
+// ParseModel checks that pf holds a model and enumerates its chunks.
+func ParseModel(pf *PFFile) (*Model, error) {
+	if pf.FourCC != "MODL" {
+		return nil, fmt.Errorf("not a model: PF content type %q", pf.FourCC)
+	}
+
+	model := &Model{PF: pf, Chunks: pf.Chunks}
+	for i := range pf.Chunks {
+		if pf.Chunks[i].FourCC == "GEOM" {
+			model.Geometry = &pf.Chunks[i]
+			break
+		}
+	}
+	return model, nil
+}
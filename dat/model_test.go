@@ -0,0 +1,43 @@
+package dat
+
+import "testing"
+
+func TestParseModel(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		geometry int // Index of the GEOM chunk, -1 when absent
+		wantErr  bool
+	}{
+		{"with geometry", testPF("MODL", testChunk{"MODL", 1, "header"}, testChunk{"GEOM", 2, "mesh"}, testChunk{"GEOM", 3, "lod"}), 1, false},
+		{"without geometry", testPF("MODL", testChunk{"SKEL", 1, "bones"}), -1, false},
+		{"not a model", testPF("AMAT", testChunk{"GEOM", 1, "mesh"}), -1, true},
+	}
+	for _, test := range tests {
+		pf, err := ParsePF(test.data)
+		if err != nil {
+			t.Fatalf("%s: ParsePF: %v", test.name, err)
+		}
+		model, err := ParseModel(pf)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: ParseModel succeeded", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: ParseModel: %v", test.name, err)
+			continue
+		}
+
+		if len(model.Chunks) != len(pf.Chunks) || model.PF != pf {
+			t.Errorf("%s: model lists %d chunks of its PF file, want %d", test.name, len(model.Chunks), len(pf.Chunks))
+		}
+		switch {
+		case test.geometry < 0 && model.Geometry != nil:
+			t.Errorf("%s: Geometry = %+v, want none", test.name, *model.Geometry)
+		case test.geometry >= 0 && model.Geometry != &pf.Chunks[test.geometry]:
+			t.Errorf("%s: Geometry = %v, want chunk %d", test.name, model.Geometry, test.geometry)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+
+package dat
+
+import "os"
+
+// openShared is os.Open: other platforms do not lock files opened for reading.
+func openShared(filePath string) (*os.File, error) {
+	return os.Open(filePath)
+}
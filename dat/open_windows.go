@@ -0,0 +1,29 @@
+//go:build windows
+
+package dat
+
+import (
+	"os"
+	"syscall"
+)
+
+// openShared opens filePath for reading while letting other processes, such
+// as a running game client, keep reading, writing and renaming it.
+func openShared(filePath string) (*os.File, error) {
+	path, err := syscall.UTF16PtrFromString(filePath)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: filePath, Err: err}
+	}
+
+	handle, err := syscall.CreateFile(path,
+		syscall.GENERIC_READ,
+		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE,
+		nil,
+		syscall.OPEN_EXISTING,
+		syscall.FILE_ATTRIBUTE_NORMAL,
+		0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: filePath, Err: err}
+	}
+	return os.NewFile(uintptr(handle), filePath), nil
+}
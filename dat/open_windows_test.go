@@ -0,0 +1,39 @@
+//go:build windows
+
+package dat
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestSharedAccess(t *testing.T) {
+	entries := []testEntry{{data: []byte("stored entry")}, {data: testPayload(5000), compressed: true}}
+	path := testDat{entries: entries}.write(t)
+
+	// Two handles at once, as a tool reading the dat next to the game would
+	var datFiles []*DatFile
+	for i := 0; i < 2; i++ {
+		datFile, err := OpenWithOptions(path, Options{SharedAccess: true})
+		if err != nil {
+			t.Fatalf("OpenWithOptions with SharedAccess while another handle is open: %v", err)
+		}
+		defer datFile.Close()
+		datFiles = append(datFiles, datFile)
+	}
+	for i, datFile := range datFiles {
+		for j, entry := range entries {
+			if got, err := datFile.ExtractByBaseID(uint32(firstTestBaseID + j)); err != nil || !bytes.Equal(got, entry.data) {
+				t.Errorf("handle %d: ExtractByBaseID(%d) returned %d bytes, %v", i, firstTestBaseID+j, len(got), err)
+			}
+		}
+	}
+
+	// The game may still open the file for writing
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("opening the dat for writing while shared handles are open: %v", err)
+	}
+	f.Close()
+}
@@ -0,0 +1,73 @@
+package dat
+
+import (
+	"io"
+	"os"
+	"sync"
+)
+
+// Opener opens the file behind a dat opened by path. Setting Options.Opener
+// lets callers serve dats from memory or restrict which files can be read.
+type Opener interface {
+	Open(path string) (io.ReadSeekCloser, error)
+}
+
+// OsOpener opens files on the local filesystem with os.Open. It is used when
+// Options.Opener is nil.
+type OsOpener struct{}
+
+func (OsOpener) Open(path string) (io.ReadSeekCloser, error) {
+	return os.Open(path)
+}
+
+// sharedOpener opens files with openShared, see Options.SharedAccess.
+type sharedOpener struct{}
+
+func (sharedOpener) Open(path string) (io.ReadSeekCloser, error) {
+	return openShared(path)
+}
+
+// handleReaderAt returns file as an io.ReaderAt: file itself when it has a
+// ReadAt method, as an *os.File does, and otherwise a wrapper seeking before
+// each read.
+func handleReaderAt(file io.ReadSeeker) io.ReaderAt {
+	if r, ok := file.(io.ReaderAt); ok {
+		return r
+	}
+	return &seekReaderAt{rs: file}
+}
+
+// handleSize returns the size of file, from Stat when it has one and
+// otherwise by seeking to its end.
+func handleSize(file io.ReadSeeker) (int64, error) {
+	if f, ok := file.(interface{ Stat() (os.FileInfo, error) }); ok {
+		info, err := f.Stat()
+		if err != nil {
+			return 0, err
+		}
+		return info.Size(), nil
+	}
+	return file.Seek(0, io.SeekEnd)
+}
+
+// seekReaderAt implements io.ReaderAt over an io.ReadSeeker. Reads are
+// serialized, as each one moves the shared offset.
+type seekReaderAt struct {
+	mu sync.Mutex
+	rs io.ReadSeeker
+}
+
+func (r *seekReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.rs.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(r.rs, p)
+	if err == io.ErrUnexpectedEOF {
+		// ReadAt reports a short read at the end of the input as io.EOF
+		err = io.EOF
+	}
+	return n, err
+}
@@ -0,0 +1,97 @@
+package dat
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"sync"
+	"testing"
+)
+
+// memoryFile is an in-memory dat with no ReadAt or Stat method, so opening
+// it goes through seekReaderAt and handleSize's seek.
+type memoryFile struct {
+	*bytes.Reader
+	closed *bool
+}
+
+func (f memoryFile) ReadAt() {} // Hides bytes.Reader.ReadAt
+
+func (f memoryFile) Close() error {
+	*f.closed = true
+	return nil
+}
+
+// memoryOpener serves dats from memory and records the paths opened.
+type memoryOpener struct {
+	mu     sync.Mutex
+	files  map[string][]byte
+	opened []string
+	closed bool
+}
+
+func (o *memoryOpener) Open(path string) (io.ReadSeekCloser, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.opened = append(o.opened, path)
+	data, ok := o.files[path]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: path, Err: fs.ErrNotExist}
+	}
+	return memoryFile{bytes.NewReader(data), &o.closed}, nil
+}
+
+func TestOpener(t *testing.T) {
+	opener := &memoryOpener{files: map[string][]byte{"memory/Gw2.dat": testDat{entries: testEntries}.build(t)}}
+	if _, ok := any(memoryFile{}).(io.ReaderAt); ok {
+		t.Fatal("memoryFile implements io.ReaderAt")
+	}
+
+	datFile, err := OpenWithOptions("memory/Gw2.dat", Options{Opener: opener})
+	if err != nil {
+		t.Fatalf("OpenWithOptions: %v", err)
+	}
+	for i, entry := range testEntries {
+		got, err := datFile.ExtractByBaseID(uint32(firstTestBaseID + i))
+		if err != nil || !bytes.Equal(got, entry.data) {
+			t.Errorf("ExtractByBaseID(%d) = %d bytes, %v", firstTestBaseID+i, len(got), err)
+		}
+	}
+	if err := datFile.Close(); err != nil || !opener.closed {
+		t.Errorf("Close returned %v, file closed %v", err, opener.closed)
+	}
+
+	if _, err := OpenWithOptions("missing/Gw2.dat", Options{Opener: opener}); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("OpenWithOptions of a missing file returned %v, want fs.ErrNotExist", err)
+	}
+	if len(opener.opened) != 2 || opener.opened[0] != "memory/Gw2.dat" || opener.opened[1] != "missing/Gw2.dat" {
+		t.Errorf("opener opened %v", opener.opened)
+	}
+}
+
+func TestSeekReaderAt(t *testing.T) {
+	r := &seekReaderAt{rs: bytes.NewReader([]byte("0123456789"))}
+
+	tests := []struct {
+		off  int64
+		n    int
+		want string
+		eof  bool
+	}{
+		{0, 4, "0123", false},
+		{6, 4, "6789", false},
+		{8, 4, "89", true},
+		{10, 1, "", true},
+	}
+	for _, test := range tests {
+		p := make([]byte, test.n)
+		n, err := r.ReadAt(p, test.off)
+		if string(p[:n]) != test.want || (err == io.EOF) != test.eof || (err != nil && err != io.EOF) {
+			t.Errorf("ReadAt(%d bytes at %d) = %q, %v; want %q, EOF %v", test.n, test.off, p[:n], err, test.want, test.eof)
+		}
+	}
+	if _, err := r.ReadAt(make([]byte, 1), -1); err == nil {
+		t.Error("ReadAt at a negative offset succeeded")
+	}
+}
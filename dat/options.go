@@ -0,0 +1,83 @@
+package dat
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"time"
+)
+
+// Options configures how a dat is opened.
+type Options struct {
+	// Logger receives progress and diagnostic messages at debug level.
+	// Nothing is logged when it is nil.
+	Logger *slog.Logger
+
+	// Mapped memory-maps the file instead of reading entries with ReadAt,
+	// see OpenMapped.
+	Mapped bool
+
+	// SharedAccess opens the file so that other processes may keep writing,
+	// renaming or deleting it, letting a dat be read while the game is
+	// running. It only changes behaviour on Windows, and is ignored when
+	// Opener is set.
+	SharedAccess bool
+
+	// Opener opens the file named by the path given to OpenWithOptions. The
+	// local filesystem is used when it is nil, see OsOpener. Mapped needs
+	// the opened file to be an *os.File.
+	Opener Opener
+
+	// CacheSize bounds, in bytes, a least-recently-used cache of decompressed
+	// entries consulted by Extract and the other extraction methods. Cached
+	// data is shared between callers and must not be modified. No cache is
+	// kept when it is 0.
+	CacheSize int64
+
+	// OnProgress, when set, is called while a compressed entry is inflated
+	// into memory, every BlockSize bytes and once more at the end, with the
+	// bytes produced so far and the decompressed size. It runs on the
+	// extracting goroutine, so it must be safe for concurrent use with
+	// ExtractAll.
+	OnProgress func(done, total uint32)
+
+	// PerEntryTimeout, when positive, bounds the time spent extracting one
+	// entry into memory with Extract and the other extraction methods. An
+	// entry taking longer fails with an error naming its MFT index and
+	// wrapping context.DeadlineExceeded. GW2-compressed entries are checked
+	// every BlockSize bytes of output; gzip and zlib entries are not.
+	PerEntryTimeout time.Duration
+
+	// MaxDecompressedSize bounds, in bytes, the decompressed size of one
+	// entry, so that a corrupt or crafted size in a stream header cannot
+	// exhaust memory. Entries declaring a larger size fail before anything
+	// is allocated. DefaultMaxDecompressedSize is used when it is 0; set it
+	// to math.MaxUint32 to accept any size the format can express.
+	MaxDecompressedSize uint32
+
+	// BlockSize is the number of 32-bit words per block of the entries'
+	// GW2-compressed streams, whose last word is the block checksum. BlockSize
+	// (the constant) is used when it is 0. BlockSizeFromHeader takes it from
+	// Header.ChunkSize, see Header.StreamBlockSize; ChunkSize is the dat's
+	// allocation unit and need not match the streams, so only set it for dats
+	// known to write them in blocks of that size. Other values must be powers
+	// of two from 0x100 to 0x40000.
+	BlockSize uint32
+
+	// VerifyCRC makes Extract and the other extraction methods check the
+	// block checksums of each entry before returning it, see VerifyEntry.
+	// It is experimental: the checksums are assumed to be CRC-32C, which has
+	// not been confirmed against retail dats.
+	VerifyCRC bool
+}
+
+// BlockSizeFromHeader is the Options.BlockSize taking the stream block size
+// from the dat header.
+const BlockSizeFromHeader = math.MaxUint32
+
+// debug logs msg with key/value args to the configured logger, if any.
+func (datFile *DatFile) debug(msg string, args ...any) {
+	if datFile.logger != nil {
+		datFile.logger.Log(context.Background(), slog.LevelDebug, msg, args...)
+	}
+}
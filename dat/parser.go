@@ -0,0 +1,888 @@
+// Package dat reads Guild Wars 2 .dat archives: the file header, the master
+// file table (MFT) and the entries it points at, decompressing them when needed.
+package dat
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// The first MFT rows describe the dat itself rather than game assets:
+//
+//   - row 0 (MftEntryHeaderNum) covers the dat header,
+//   - row 1 (MftEntryIndexNum) holds the index table mapping file IDs to base
+//     IDs, read into MFTIndexData,
+//   - row 2 (MftEntryMftNum) covers the MFT itself.
+//
+// Asset entries follow; base IDs count rows from 1, so row 3 is base ID 4.
+const (
+	MftEntryHeaderNum = 0
+	MftEntryIndexNum  = 1
+	MftEntryMftNum    = 2
+)
+
+const (
+	DatMagicNumber = 3
+	MftMagicNumber = 4
+
+	DatIdentifier = "AN\x1A" // Header.Identifier of a GW2 dat
+	DatVersion    = 0x97     // Header.Version of a GW2 dat
+)
+
+// Header is the fixed header at the start of a .dat file.
+type Header struct {
+	Version       uint8
+	Identifier    [DatMagicNumber]uint8
+	HeaderSize    uint32
+	UnknownField  uint32
+	ChunkSize     uint32 // Allocation unit of the dat in bytes, see StreamBlockSize
+	CRC           uint32
+	UnknownField2 uint32
+	MftOffset     uint64
+	MftSize       uint32
+	Flags         uint32
+}
+
+// HeaderFlagNarrowOffsets is the Header.Flags bit of dat variants whose MFT
+// rows store 32-bit offsets instead of 64-bit ones, making each row 20 bytes.
+// Retail GW2 dats leave it clear.
+const HeaderFlagNarrowOffsets = 0x80000000
+
+// NarrowOffsets reports whether the MFT rows of the dat hold 32-bit offsets.
+func (header *Header) NarrowOffsets() bool {
+	return header.Flags&HeaderFlagNarrowOffsets != 0
+}
+
+// StreamBlockSize returns the number of words per compressed stream block
+// that ChunkSize describes, used with Options.BlockSize set to
+// BlockSizeFromHeader. ChunkSize is in bytes; BlockSize is returned when it
+// is not a whole number of words making a plausible block size.
+func (header *Header) StreamBlockSize() uint32 {
+	if words := header.ChunkSize / 4; header.ChunkSize%4 == 0 && validStreamBlockSize(words) {
+		return words
+	}
+	return BlockSize
+}
+
+// Validate checks that the header describes a plausible dat of fileSize
+// bytes: the header and MFT must lie within the file and ChunkSize must be set.
+func (header *Header) Validate(fileSize int64) error {
+	if header.HeaderSize < uint32(binary.Size(Header{})) || int64(header.HeaderSize) > fileSize {
+		return fmt.Errorf("invalid header size %d for a %d byte file", header.HeaderSize, fileSize)
+	}
+	if header.ChunkSize == 0 {
+		return fmt.Errorf("invalid chunk size 0")
+	}
+	if fileSize < 0 || !spanFits(header.MftOffset, uint64(header.MftSize), uint64(fileSize)) {
+		return fmt.Errorf("MFT at offset %d with size %d extends past the end of the %d byte file", header.MftOffset, header.MftSize, fileSize)
+	}
+	return nil
+}
+
+// spanFits reports whether size bytes starting at offset lie within the first
+// limit bytes. Unlike comparing offset+size with limit, it cannot overflow,
+// however close offset is to the uint64 maximum.
+func spanFits(offset, size, limit uint64) bool {
+	return offset <= limit && size <= limit-offset
+}
+
+// MFTHeader precedes the master file table.
+//
+// The meaning of Unknown, UnknownField2 and UnknownField3 is not known. None
+// of them has been shown to count reserved rows, so SystemEntries relies on
+// the fixed row layout instead.
+type MFTHeader struct {
+	Identifier    [MftMagicNumber]uint8
+	Unknown       uint64
+	NumEntries    uint32
+	UnknownField2 uint32
+	UnknownField3 uint32
+}
+
+// MFTEntry is a single row of the master file table.
+type MFTEntry struct {
+	Offset          uint64
+	Size            uint32
+	CompressionFlag uint16
+	EntryFlag       uint16
+	Counter         uint32
+	CRC             uint32
+}
+
+// Values of MFTEntry.CompressionFlag. Any non-zero value marks compressed
+// data; GW2 writes CompressionGW2 whether the entry uses its own codec or a
+// standard gzip or zlib stream, which is told apart by its leading bytes.
+const (
+	CompressionNone = 0 // Stored as is
+	CompressionGW2  = 8 // Stored compressed
+)
+
+// EntryFlagEncrypted is the MFTEntry.EntryFlag bit marking an entry whose
+// data is encrypted with a key the dat does not hold.
+const EntryFlagEncrypted = 0x8000
+
+// ErrEncryptedEntry is returned, wrapped, when extracting an entry that
+// EntryFlag marks as encrypted. Its data cannot be decoded without the key,
+// so it is reported instead of being decompressed into garbage.
+var ErrEncryptedEntry = errors.New("entry is encrypted")
+
+// ErrEntryNotFound is returned, wrapped, when a file ID, base ID or MFT index
+// names no entry of the dat.
+var ErrEntryNotFound = errors.New("MFT entry not found")
+
+// ErrBadMagic is returned, wrapped, when data does not start with the magic
+// number of the format it is read as: a dat or its MFT when opening, or an
+// entry parsed as PF, ATEX or string file.
+var ErrBadMagic = errors.New("bad magic number")
+
+// IsCompressed reports whether the entry data is stored compressed, see
+// CompressionGW2.
+func (mftEntry MFTEntry) IsCompressed() bool {
+	return mftEntry.CompressionFlag != CompressionNone
+}
+
+// IsEmpty reports whether the row holds no data, as deleted and placeholder
+// rows do. Such rows extract to an empty slice whatever their
+// CompressionFlag, as there is no stream to decompress.
+func (mftEntry MFTEntry) IsEmpty() bool {
+	return mftEntry.Size == 0
+}
+
+// IsDeleted reports whether the row carries the all-ones offset marking a
+// deleted entry. The 32-bit all-ones offset of a dat with narrow offsets is
+// widened when the row is read, see readMFTEntry, so a 64-bit offset of
+// 0xFFFFFFFF is an ordinary one. Deleted rows point at nothing; ListEntries
+// and ForEach leave them out unless asked not to.
+func (mftEntry MFTEntry) IsDeleted() bool {
+	return mftEntry.Offset == math.MaxUint64
+}
+
+// IsEncrypted reports whether EntryFlag marks the entry as encrypted.
+func (mftEntry MFTEntry) IsEncrypted() bool {
+	return mftEntry.EntryFlag&EntryFlagEncrypted != 0
+}
+
+// MFTIndexData maps a file ID to its base ID. The base ID is the 1-based
+// number of the MFT row holding the data; several file IDs may share one.
+type MFTIndexData struct {
+	FileID uint32
+	BaseID uint32
+}
+
+// DatFile is a parsed .dat file.
+type DatFile struct {
+	Header       Header
+	MFTHeader    MFTHeader
+	MFTData      []MFTEntry
+	MFTIndexData []MFTIndexData
+
+	Manifest Manifest // Names resolved by ExtractByName, see LoadManifest
+
+	reader       io.ReaderAt               // Source entries are read from
+	size         int64                     // Size of the dat in bytes
+	file         io.ReadSeekCloser         // File behind reader when opened by path, see Close
+	logger       *slog.Logger              // See Options.Logger
+	onProgress   func(done, total uint32)  // See Options.OnProgress
+	entryTimeout time.Duration             // See Options.PerEntryTimeout
+	maxSize      uint32                    // See Options.MaxDecompressedSize
+	blockSize    uint32                    // See Options.BlockSize
+	verifyCRC    bool                      // See Options.VerifyCRC
+	mapping      []byte                    // Memory-mapped file contents, see OpenMapped
+	cache        *entryCache               // Decompressed entries, see Options.CacheSize
+	replacements map[int]replacement       // New entry contents written by WriteTo, see ReplaceEntry
+	hashMu       sync.Mutex                // Guards hashes
+	hashes       map[int][sha256.Size]byte // Results of EntryHash by MFT index
+	fileIDIndex  map[uint32]MFTIndexData   // MFTIndexData keyed by FileID
+	baseFileIDs  map[uint32][]uint32       // File IDs referencing each BaseID, in ascending order
+}
+
+// namedField is a value read from the dat, with the name used in errors.
+type namedField struct {
+	name string
+	data any // Pointer to the value
+}
+
+// readFields reads little-endian values in order, reporting which field
+// could not be read.
+func readFields(r io.Reader, fields ...namedField) error {
+	for _, field := range fields {
+		if err := binary.Read(r, binary.LittleEndian, field.data); err != nil {
+			return fmt.Errorf("reading %s: %w", field.name, err)
+		}
+	}
+	return nil
+}
+
+// newDatFile returns a DatFile configured by opts, with nothing loaded yet.
+func newDatFile(opts Options) (*DatFile, error) {
+	if opts.BlockSize != 0 && opts.BlockSize != BlockSizeFromHeader && !validStreamBlockSize(opts.BlockSize) {
+		return nil, fmt.Errorf("invalid stream block size %#x", opts.BlockSize)
+	}
+	datFile := &DatFile{
+		logger:       opts.Logger,
+		onProgress:   opts.OnProgress,
+		entryTimeout: opts.PerEntryTimeout,
+		maxSize:      opts.MaxDecompressedSize,
+		blockSize:    opts.BlockSize,
+		verifyCRC:    opts.VerifyCRC,
+	}
+	if datFile.maxSize == 0 {
+		datFile.maxSize = DefaultMaxDecompressedSize
+	}
+	if opts.CacheSize > 0 {
+		datFile.cache = newEntryCache(opts.CacheSize)
+	}
+	return datFile, nil
+}
+
+// streamBlockSize returns the number of words per block of the entries'
+// compressed streams, see Options.BlockSize.
+func (datFile *DatFile) streamBlockSize() uint32 {
+	switch datFile.blockSize {
+	case 0:
+		return BlockSize
+	case BlockSizeFromHeader:
+		return datFile.Header.StreamBlockSize()
+	}
+	return datFile.blockSize
+}
+
+// Open loads the .dat file at filePath and parses its header and MFT.
+func Open(filePath string) (*DatFile, error) {
+	return OpenWithOptions(filePath, Options{})
+}
+
+// OpenWithOptions is Open with explicit options.
+func OpenWithOptions(filePath string, opts Options) (*DatFile, error) {
+	datFile, err := newDatFile(opts)
+	if err != nil {
+		return nil, err
+	}
+	datFile.debug("Opening .dat file", "path", filePath)
+	var opener Opener = OsOpener{}
+	if opts.Opener != nil {
+		opener = opts.Opener
+	} else if opts.SharedAccess {
+		opener = sharedOpener{}
+	}
+	file, err := opener.Open(filePath)
+	if err != nil {
+		datFile.debug("Failed to open .dat file", "error", err)
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	size, err := handleSize(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	datFile.reader, datFile.size, datFile.file = handleReaderAt(file), size, file
+	if err := datFile.load(); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if opts.Mapped {
+		if err := datFile.mapFile(); err != nil {
+			datFile.Close()
+			return nil, err
+		}
+	}
+	return datFile, nil
+}
+
+// OpenReaderAt parses a dat of the given size read from r, such as one held
+// in memory or embedded in another container. All reads, including those of
+// extracted entries, go through r.ReadAt.
+func OpenReaderAt(r io.ReaderAt, size int64) (*DatFile, error) {
+	return OpenReaderAtWithOptions(r, size, Options{})
+}
+
+// OpenReaderAtWithOptions is OpenReaderAt with explicit options. Options.Mapped
+// is ignored, as there is no file to map.
+func OpenReaderAtWithOptions(r io.ReaderAt, size int64, opts Options) (*DatFile, error) {
+	datFile, err := newDatFile(opts)
+	if err != nil {
+		return nil, err
+	}
+	datFile.reader, datFile.size = r, size
+	if err := datFile.load(); err != nil {
+		return nil, err
+	}
+	return datFile, nil
+}
+
+// loadHeaders parses and checks the dat header and the MFT header, leaving
+// file positioned at the first MFT row.
+func (datFile *DatFile) loadHeaders(file *io.SectionReader) error {
+	datFile.debug("Reading dat header")
+	if err := readHeader(file, &datFile.Header); err != nil {
+		datFile.debug("Failed to read dat header", "error", err)
+		return err
+	}
+
+	datFile.debug("Verifying dat identifier")
+	if string(datFile.Header.Identifier[:]) != DatIdentifier {
+		datFile.debug("Invalid dat header identifier")
+		return fmt.Errorf("not a GW2 dat file: identifier %q: %w", datFile.Header.Identifier[:], ErrBadMagic)
+	}
+	if datFile.Header.Version != DatVersion {
+		datFile.debug("Unsupported dat version")
+		return fmt.Errorf("not a GW2 dat file: version %#x", datFile.Header.Version)
+	}
+	if err := datFile.Header.Validate(datFile.size); err != nil {
+		datFile.debug("Invalid dat header", "error", err)
+		return fmt.Errorf("invalid dat header: %w", err)
+	}
+
+	datFile.debug("Seeking to MFT", "offset", datFile.Header.MftOffset)
+	if _, err := file.Seek(int64(datFile.Header.MftOffset), io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek to MFT: %w", err)
+	}
+
+	datFile.debug("Reading MFTHeader")
+	mftHeader := &datFile.MFTHeader
+	if err := readFields(file,
+		namedField{"MFT Identifier", &mftHeader.Identifier},
+		namedField{"MFT Unknown", &mftHeader.Unknown},
+		namedField{"NumEntries", &mftHeader.NumEntries},
+		namedField{"MFT UnknownField2", &mftHeader.UnknownField2},
+		namedField{"MFT UnknownField3", &mftHeader.UnknownField3},
+	); err != nil {
+		datFile.debug("Failed to read MFT header", "error", err)
+		return fmt.Errorf("failed to read MFT header: %w", err)
+	}
+
+	datFile.debug("Verifying MFT magic number")
+	if string(datFile.MFTHeader.Identifier[:]) != "\x4D\x66\x74\x1A" {
+		datFile.debug("Invalid MFT header magic number")
+		return fmt.Errorf("MFT identifier %q: %w", datFile.MFTHeader.Identifier[:], ErrBadMagic)
+	}
+
+	// Rows 0 and 1 are always present in a GW2 dat; row 1 locates the index
+	if datFile.MFTHeader.NumEntries <= MftEntryIndexNum {
+		datFile.debug("Too few MFT entries", "count", datFile.MFTHeader.NumEntries)
+		return fmt.Errorf("MFT has %d entries, too few to hold the index table at entry %d", datFile.MFTHeader.NumEntries, MftEntryIndexNum)
+	}
+	return nil
+}
+
+// load parses the header, MFT and index table from the reader.
+func (datFile *DatFile) load() error {
+	file := io.NewSectionReader(datFile.reader, 0, datFile.size)
+	if err := datFile.loadHeaders(file); err != nil {
+		return err
+	}
+
+	datFile.debug("Reading MFTData entries", "count", datFile.MFTHeader.NumEntries, "narrowOffsets", datFile.Header.NarrowOffsets())
+	datFile.MFTData = make([]MFTEntry, datFile.MFTHeader.NumEntries)
+	for i := range datFile.MFTData {
+		if err := readMFTEntry(file, &datFile.MFTData[i], i, datFile.Header.NarrowOffsets()); err != nil {
+			datFile.debug("Failed to read MFT data", "error", err)
+			return fmt.Errorf("failed to read MFT data: %w", err)
+		}
+	}
+
+	datFile.debug("Calculating number of MFT index entries")
+	indexEntry := datFile.MFTData[MftEntryIndexNum]
+	if err := datFile.validateIndexEntry(indexEntry); err != nil {
+		datFile.debug("Invalid MFT index entry", "error", err)
+		return err
+	}
+	numIndexEntries := indexEntry.Size / uint32(binary.Size(MFTIndexData{}))
+	datFile.MFTIndexData = make([]MFTIndexData, numIndexEntries)
+
+	datFile.debug("Parsing MFT index data")
+	if _, err := file.Seek(int64(indexEntry.Offset), io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek to MFT index: %w", err)
+	}
+	for i := range datFile.MFTIndexData {
+		if err := readFields(file, namedField{fmt.Sprintf("MFT index entry %d", i), &datFile.MFTIndexData[i]}); err != nil {
+			datFile.debug("Failed to read MFT index data", "error", err)
+			return fmt.Errorf("failed to read MFT index data: %w", err)
+		}
+	}
+
+	datFile.debug("Building MFT index lookup maps")
+	datFile.buildIndexMaps()
+
+	return nil
+}
+
+// IsIndexTable reports whether the 0-based MFT index is the row holding the
+// file ID index table. The row is identified by its position; its flags do not
+// mark it.
+func (datFile *DatFile) IsIndexTable(index int) bool {
+	return index == MftEntryIndexNum && index < len(datFile.MFTData)
+}
+
+// SystemEntries returns the 0-based indices of the MFT rows describing the dat
+// itself rather than game assets: the header, index table and MFT rows, see
+// MftEntryHeaderNum. They are at fixed positions: WriteTo lays them out the
+// same way, and load finds the index table at MftEntryIndexNum. Tools walking
+// the MFT can skip them.
+func (datFile *DatFile) SystemEntries() []int {
+	var indices []int
+	for index := MftEntryHeaderNum; index <= MftEntryMftNum && index < len(datFile.MFTData); index++ {
+		indices = append(indices, index)
+	}
+	return indices
+}
+
+// validateIndexEntry checks that the MFT row expected to hold the index table
+// looks like one: stored uncompressed, a whole number of MFTIndexData records
+// long and inside the file.
+func (datFile *DatFile) validateIndexEntry(indexEntry MFTEntry) error {
+	if indexEntry.IsCompressed() {
+		return fmt.Errorf("MFT entry %d is compressed (flag %#x), not an index table", MftEntryIndexNum, indexEntry.CompressionFlag)
+	}
+	if recordSize := uint32(binary.Size(MFTIndexData{})); indexEntry.Size%recordSize != 0 {
+		return fmt.Errorf("MFT entry %d size %d is not a multiple of the %d byte index record", MftEntryIndexNum, indexEntry.Size, recordSize)
+	}
+	if !spanFits(indexEntry.Offset, uint64(indexEntry.Size), uint64(datFile.size)) {
+		return fmt.Errorf("MFT entry %d at offset %d with size %d extends past the end of the %d byte file", MftEntryIndexNum, indexEntry.Offset, indexEntry.Size, datFile.size)
+	}
+	return nil
+}
+
+// readMFTEntry reads one MFT row, whose offset is a 32-bit value when narrow
+// is set and a 64-bit one otherwise. A 32-bit all-ones offset, marking a
+// deleted row, is widened to the 64-bit one, see MFTEntry.IsDeleted.
+func readMFTEntry(r io.Reader, mftEntry *MFTEntry, index int, narrow bool) error {
+	if !narrow {
+		return readFields(r, namedField{fmt.Sprintf("MFT entry %d", index), mftEntry})
+	}
+
+	var offset uint32
+	if err := readFields(r,
+		namedField{fmt.Sprintf("MFT entry %d Offset", index), &offset},
+		namedField{fmt.Sprintf("MFT entry %d Size", index), &mftEntry.Size},
+		namedField{fmt.Sprintf("MFT entry %d CompressionFlag", index), &mftEntry.CompressionFlag},
+		namedField{fmt.Sprintf("MFT entry %d EntryFlag", index), &mftEntry.EntryFlag},
+		namedField{fmt.Sprintf("MFT entry %d Counter", index), &mftEntry.Counter},
+		namedField{fmt.Sprintf("MFT entry %d CRC", index), &mftEntry.CRC},
+	); err != nil {
+		return err
+	}
+	mftEntry.Offset = uint64(offset)
+	if offset == math.MaxUint32 {
+		mftEntry.Offset = math.MaxUint64
+	}
+	return nil
+}
+
+// readHeader reads the fixed dat header from r.
+func readHeader(r io.Reader, header *Header) error {
+	if err := readFields(r,
+		namedField{"Version", &header.Version},
+		namedField{"Identifier", &header.Identifier},
+		namedField{"HeaderSize", &header.HeaderSize},
+		namedField{"UnknownField", &header.UnknownField},
+		namedField{"ChunkSize", &header.ChunkSize},
+		namedField{"CRC", &header.CRC},
+		namedField{"UnknownField2", &header.UnknownField2},
+		namedField{"MftOffset", &header.MftOffset},
+		namedField{"MftSize", &header.MftSize},
+		namedField{"Flags", &header.Flags},
+	); err != nil {
+		return fmt.Errorf("failed to read dat header: %w", err)
+	}
+	return nil
+}
+
+// Refresh re-reads the dat header through the open handle and reloads the
+// MFT and index table when the game has moved or rewritten them, for example
+// after a patch appended data. It does nothing when the header's CRC, MFT
+// offset and MFT size and the file size are unchanged. On error the DatFile
+// keeps its previous tables. Refresh must not run concurrently with
+// extraction.
+func (datFile *DatFile) Refresh() error {
+	if datFile.reader == nil {
+		return fmt.Errorf("dat file is closed")
+	}
+
+	size := datFile.size
+	if datFile.file != nil {
+		var err error
+		if size, err = handleSize(datFile.file); err != nil {
+			return fmt.Errorf("failed to stat file: %w", err)
+		}
+	}
+
+	var header Header
+	if err := readHeader(io.NewSectionReader(datFile.reader, 0, size), &header); err != nil {
+		return err
+	}
+	if size == datFile.size && header.CRC == datFile.Header.CRC &&
+		header.MftOffset == datFile.Header.MftOffset && header.MftSize == datFile.Header.MftSize {
+		datFile.debug("Dat unchanged, nothing to refresh")
+		return nil
+	}
+
+	datFile.debug("Reloading MFT", "size", size, "mftOffset", header.MftOffset)
+	reloaded := &DatFile{reader: datFile.reader, size: size, logger: datFile.logger}
+	if err := reloaded.load(); err != nil {
+		return fmt.Errorf("failed to refresh: %w", err)
+	}
+
+	if datFile.mapping != nil && size != datFile.size {
+		if err := munmap(datFile.mapping); err != nil {
+			return fmt.Errorf("failed to unmap file: %w", err)
+		}
+		datFile.mapping = nil
+		if err := datFile.mapFile(); err != nil {
+			return err
+		}
+	}
+
+	datFile.size = size
+	datFile.Header = reloaded.Header
+	datFile.MFTHeader = reloaded.MFTHeader
+	datFile.MFTData = reloaded.MFTData
+	datFile.MFTIndexData = reloaded.MFTIndexData
+	datFile.fileIDIndex = reloaded.fileIDIndex
+	datFile.baseFileIDs = reloaded.baseFileIDs
+	if datFile.cache != nil {
+		// Cached entries are keyed by MFT index, which the reload may reassign
+		datFile.cache.clear()
+	}
+	datFile.hashMu.Lock()
+	datFile.hashes = nil
+	datFile.hashMu.Unlock()
+	return nil
+}
+
+// Close releases the file handle and memory mapping held by the DatFile. A
+// reader passed to OpenReaderAt is not closed. Entries cannot be extracted
+// afterwards.
+func (datFile *DatFile) Close() error {
+	var err error
+	if datFile.mapping != nil {
+		err = munmap(datFile.mapping)
+		datFile.mapping = nil
+	}
+	if datFile.file != nil {
+		if closeErr := datFile.file.Close(); err == nil {
+			err = closeErr
+		}
+		datFile.file = nil
+	}
+	datFile.reader = nil
+	if datFile.cache != nil {
+		datFile.cache.clear()
+	}
+	return err
+}
+
+// buildIndexMaps indexes MFTIndexData by file ID and lists the file IDs of
+// each base ID in ascending order. When a file ID appears twice, the first
+// entry wins.
+func (datFile *DatFile) buildIndexMaps() {
+	datFile.fileIDIndex = make(map[uint32]MFTIndexData, len(datFile.MFTIndexData))
+	datFile.baseFileIDs = make(map[uint32][]uint32, len(datFile.MFTIndexData))
+	for _, entry := range datFile.MFTIndexData {
+		datFile.baseFileIDs[entry.BaseID] = append(datFile.baseFileIDs[entry.BaseID], entry.FileID)
+		if previous, ok := datFile.fileIDIndex[entry.FileID]; ok {
+			datFile.debug("Duplicate file ID, keeping the first", "fileID", entry.FileID, "baseID", previous.BaseID, "duplicateBaseID", entry.BaseID)
+		} else {
+			datFile.fileIDIndex[entry.FileID] = entry
+		}
+	}
+	for _, fileIDs := range datFile.baseFileIDs {
+		slices.Sort(fileIDs)
+	}
+}
+
+// FileIDsForBaseID returns every file ID the index table maps to baseID, in
+// ascending order, or nil when none does. A baseID naming no MFT row fails
+// with ErrEntryNotFound. The slice must not be modified.
+func (datFile *DatFile) FileIDsForBaseID(baseID uint32) ([]uint32, error) {
+	if _, err := datFile.rowForBaseID(baseID); err != nil {
+		return nil, err
+	}
+	return datFile.baseFileIDs[baseID], nil
+}
+
+// Extract returns the contents of the entry identified by number, which is a
+// file ID when isFileID is set and a base ID otherwise. Compressed entries are
+// inflated before being returned.
+func (datFile *DatFile) Extract(number uint32, isFileID bool) ([]byte, error) {
+	return datFile.ExtractContext(context.Background(), number, isFileID)
+}
+
+// ExtractContext is like Extract but stops decompressing, returning ctx.Err(),
+// once ctx is done. No partial output is returned on cancellation.
+func (datFile *DatFile) ExtractContext(ctx context.Context, number uint32, isFileID bool) ([]byte, error) {
+	index, err := datFile.resolveIndex(number, isFileID)
+	if err != nil {
+		return nil, err
+	}
+	return datFile.extractEntry(ctx, index)
+}
+
+// ExtractByFileID returns the contents of the entry a file ID refers to. The
+// file ID is looked up in MFTIndexData and resolved through its base ID.
+func (datFile *DatFile) ExtractByFileID(id uint32) ([]byte, error) {
+	return datFile.ExtractContext(context.Background(), id, true)
+}
+
+// ExtractByBaseID returns the contents of the MFT row a base ID names. Base
+// IDs are 1-based row numbers, so no index lookup is needed.
+func (datFile *DatFile) ExtractByBaseID(id uint32) ([]byte, error) {
+	return datFile.ExtractContext(context.Background(), id, false)
+}
+
+// resolveIndex returns the 0-based MFTData index of a file or base ID.
+func (datFile *DatFile) resolveIndex(number uint32, isFileID bool) (int, error) {
+	if !isFileID {
+		datFile.debug("Starting MFT data extraction", "baseID", number)
+		return datFile.rowForBaseID(number)
+	}
+
+	datFile.debug("Starting MFT data extraction", "fileID", number)
+	entry, ok := datFile.fileIDIndex[number]
+	if !ok {
+		datFile.debug("MFT entry not found", "fileID", number)
+		return -1, fmt.Errorf("file ID %d: %w", number, ErrEntryNotFound)
+	}
+	datFile.debug("Resolved file ID", "entry", entry)
+	return datFile.rowForBaseID(entry.BaseID)
+}
+
+// rowForBaseID converts a 1-based base ID into a 0-based index into MFTData,
+// rejecting IDs that do not name an existing row.
+func (datFile *DatFile) rowForBaseID(id uint32) (int, error) {
+	if id == 0 || uint64(id) > uint64(len(datFile.MFTData)) {
+		return -1, fmt.Errorf("base ID %d out of range [1, %d]: %w", id, len(datFile.MFTData), ErrEntryNotFound)
+	}
+	return int(id) - 1, nil
+}
+
+// ExtractPrefix returns at most the first n bytes of the entry identified as
+// in Extract. Decompression stops once n bytes have been produced, which is
+// much cheaper than Extract when only a header is needed.
+func (datFile *DatFile) ExtractPrefix(number uint32, isFileID bool, n uint32) ([]byte, error) {
+	index, err := datFile.resolveIndex(number, isFileID)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return []byte{}, nil
+	}
+	return datFile.extractEntryLimit(context.Background(), nil, index, n)
+}
+
+// extractEntry reads the MFT row at the 0-based index and inflates it if needed.
+func (datFile *DatFile) extractEntry(ctx context.Context, index int) ([]byte, error) {
+	return datFile.extractEntryLimit(ctx, nil, index, 0)
+}
+
+// extractEntryLimit is extractEntry returning at most limit bytes, or the
+// whole entry when limit is 0. Compressed entries go through the cache when
+// one is configured; only whole entries are added to it. GW2 streams are
+// inflated with dec when it is non-nil. Options.PerEntryTimeout applies.
+func (datFile *DatFile) extractEntryLimit(ctx context.Context, dec *Decoder, index int, limit uint32) ([]byte, error) {
+	return datFile.withEntryTimeout(ctx, index, func(ctx context.Context) ([]byte, error) {
+		return datFile.extractEntryCached(ctx, dec, index, limit)
+	})
+}
+
+// withEntryTimeout runs extract, which extracts the MFT row at index, under
+// Options.PerEntryTimeout when one is set.
+func (datFile *DatFile) withEntryTimeout(ctx context.Context, index int, extract func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	if datFile.entryTimeout <= 0 {
+		return extract(ctx)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, datFile.entryTimeout)
+	defer cancel()
+	data, err := extract(ctx)
+	if err != nil && errors.Is(err, context.DeadlineExceeded) {
+		return nil, fmt.Errorf("MFT entry %d not extracted within %v: %w", index, datFile.entryTimeout, err)
+	}
+	return data, err
+}
+
+// extractEntryCached is extractEntryLimit without the per-entry timeout.
+func (datFile *DatFile) extractEntryCached(ctx context.Context, dec *Decoder, index int, limit uint32) ([]byte, error) {
+	if datFile.cache == nil || !datFile.MFTData[index].IsCompressed() {
+		return datFile.readEntry(ctx, dec, index, limit)
+	}
+
+	if data, ok := datFile.cache.get(index); ok {
+		datFile.debug("Returning cached MFT entry data", "index", index)
+		if limit != 0 && uint64(limit) < uint64(len(data)) {
+			data = data[:limit]
+		}
+		return data, nil
+	}
+
+	data, err := datFile.readEntry(ctx, dec, index, limit)
+	if err == nil && limit == 0 {
+		datFile.cache.put(index, data)
+	}
+	return data, err
+}
+
+// readEntry reads and decompresses the MFT row at index, bypassing the cache.
+func (datFile *DatFile) readEntry(ctx context.Context, dec *Decoder, index int, limit uint32) ([]byte, error) {
+	buffer, err := datFile.readChecked(index)
+	if err != nil {
+		return nil, err
+	}
+	return datFile.decodeEntry(ctx, dec, index, buffer, limit)
+}
+
+// decodeEntryHook, when set, is called with the MFT index of every entry
+// decodeEntry decodes. It lets tests count decompressions.
+var decodeEntryHook func(index int)
+
+// decodeEntry decompresses buffer, the on-disk bytes of the MFT row at index,
+// returning at most limit bytes when limit is non-zero. Uncompressed data is
+// returned as is, as is the empty data of an empty row. GW2 streams are
+// inflated with dec when it is non-nil, into a fresh buffer the caller owns.
+func (datFile *DatFile) decodeEntry(ctx context.Context, dec *Decoder, index int, buffer []byte, limit uint32) ([]byte, error) {
+	if decodeEntryHook != nil {
+		decodeEntryHook(index)
+	}
+	mftEntry := datFile.MFTData[index]
+
+	if mftEntry.IsCompressed() && !mftEntry.IsEmpty() {
+		if r, ok := openStandardStream(buffer); ok {
+			defer r.Close()
+			datFile.debug("Decompressing standard gzip/zlib MFT entry data")
+			inflatedData, err := inflateStandard(r, limit, datFile.maxSize)
+			if err != nil {
+				return nil, fmt.Errorf("decompression failed: %w", err)
+			}
+			return inflatedData, nil
+		}
+
+		datFile.debug("Detected compressed MFT entry data")
+
+		outputBufferSize := limit           // Caps the output; decoding stops once it is full
+		customOutputBufferSize := uint32(0) // Adjust as needed for custom size
+		datFile.debug("Attempting to decompress MFT entry data")
+
+		var inflatedData []byte
+		var err error
+		if dec != nil {
+			inflatedData, err = dec.inflate(ctx, buffer, outputBufferSize, datFile.maxSize, datFile.streamBlockSize(), datFile.onProgress, nil)
+		} else {
+			inflatedData, err = inflateBufferContext(ctx, buffer, &outputBufferSize, customOutputBufferSize, datFile.maxSize, datFile.streamBlockSize(), datFile.onProgress)
+		}
+		if err != nil {
+			datFile.debug("Decompression failed", "error", err)
+			return nil, fmt.Errorf("decompression failed: %w", err)
+		}
+		datFile.debug("Decompression successful", "size", len(inflatedData))
+		return inflatedData, nil
+	}
+
+	datFile.debug("Returning uncompressed MFT entry data")
+	if limit != 0 && uint64(limit) < uint64(len(buffer)) {
+		buffer = buffer[:limit]
+	}
+	return buffer, nil
+}
+
+// ExtractRaw returns both the on-disk bytes of the entry identified as in
+// Extract and, when the entry is compressed, its decompressed contents. The
+// decompressed slice is nil for uncompressed entries. The entry is read once.
+// For an encrypted entry only raw is returned, along with an error wrapping
+// ErrEncryptedEntry.
+func (datFile *DatFile) ExtractRaw(number uint32, isFileID bool) (raw []byte, decompressed []byte, err error) {
+	index, err := datFile.resolveIndex(number, isFileID)
+	if err != nil {
+		return nil, nil, err
+	}
+	raw, err = datFile.readVerified(index)
+	if err != nil {
+		return nil, nil, err
+	}
+	if datFile.MFTData[index].IsEncrypted() {
+		return raw, nil, fmt.Errorf("MFT entry %d: %w", index, ErrEncryptedEntry)
+	}
+	if !datFile.MFTData[index].IsCompressed() {
+		return raw, nil, nil
+	}
+
+	decompressed, err = datFile.decodeEntry(context.Background(), nil, index, raw, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	return raw, decompressed, nil
+}
+
+// readChecked is readVerified for entries whose contents are to be decoded.
+// Encrypted entries are refused with ErrEncryptedEntry.
+func (datFile *DatFile) readChecked(index int) ([]byte, error) {
+	if datFile.MFTData[index].IsEncrypted() {
+		datFile.debug("Refusing encrypted MFT entry", "index", index)
+		return nil, fmt.Errorf("MFT entry %d: %w", index, ErrEncryptedEntry)
+	}
+	return datFile.readVerified(index)
+}
+
+// readVerified is readRaw followed by the block checksum check when
+// Options.VerifyCRC is set.
+func (datFile *DatFile) readVerified(index int) ([]byte, error) {
+	buffer, err := datFile.readRaw(index)
+	if err != nil {
+		return nil, err
+	}
+
+	if datFile.verifyCRC && crcApplies(datFile.MFTData[index]) {
+		if err := checkEntryCRC(index, buffer, datFile.streamBlockSize()); err != nil {
+			datFile.debug("CRC check failed", "error", err)
+			return nil, err
+		}
+	}
+	return buffer, nil
+}
+
+// readRaw reads the on-disk bytes of the MFT row at the 0-based index. It
+// uses ReadAt, so concurrent calls do not interfere with each other.
+func (datFile *DatFile) readRaw(index int) ([]byte, error) {
+	datFile.debug("Located MFT entry", "index", index)
+	mftEntry := datFile.MFTData[index]
+	datFile.debug("MFT entry", "entry", mftEntry)
+	if datFile.reader == nil && datFile.mapping == nil {
+		return nil, fmt.Errorf("dat file is closed")
+	}
+	// Empty rows hold no data, and deleted ones may keep a stale offset
+	if mftEntry.IsEmpty() {
+		return []byte{}, nil
+	}
+	if datFile.mapping != nil {
+		return datFile.readMapped(index, mftEntry)
+	}
+	if !spanFits(mftEntry.Offset, uint64(mftEntry.Size), uint64(datFile.size)) {
+		return nil, fmt.Errorf("MFT entry %d at offset %d with size %d extends past the end of the %d byte file", index, mftEntry.Offset, mftEntry.Size, datFile.size)
+	}
+	// Only a concern where int is 32 bits wide
+	if uint64(mftEntry.Size) > math.MaxInt {
+		return nil, fmt.Errorf("MFT entry %d of %d bytes is too large to read on this platform", index, mftEntry.Size)
+	}
+	buffer := make([]byte, mftEntry.Size)
+
+	// ReadFull so that a short read never leaves zeros at the end of buffer
+	datFile.debug("Reading MFT entry data", "size", mftEntry.Size, "offset", mftEntry.Offset)
+	section := io.NewSectionReader(datFile.reader, int64(mftEntry.Offset), int64(mftEntry.Size))
+	if n, err := io.ReadFull(section, buffer); err != nil {
+		datFile.debug("Failed to read MFT data", "error", err, "read", n)
+		return nil, fmt.Errorf("failed to read MFT data: read %d of %d bytes: %w", n, mftEntry.Size, err)
+	}
+
+	return buffer, nil
+}
@@ -0,0 +1,828 @@
+package dat
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testEntries are stored and compressed entries, with base IDs 4 to 7.
+var testEntries = []testEntry{
+	{data: []byte("stored entry")},
+	{data: testPayload(3000), compressed: true},
+	{data: testPayload(200000), compressed: true},
+	{data: []byte{}},
+}
+
+func TestOpenAndExtract(t *testing.T) {
+	for _, narrow := range []bool{false, true} {
+		spec := testDat{
+			entries: testEntries,
+			fileIDs: [][2]uint32{{100, firstTestBaseID}, {101, firstTestBaseID + 1}, {102, firstTestBaseID + 1}},
+			narrow:  narrow,
+		}
+		datFile := spec.open(t, Options{})
+
+		if got := len(datFile.MFTData); got != len(testEntries)+3 {
+			t.Fatalf("narrow %v: %d MFT rows, want %d", narrow, got, len(testEntries)+3)
+		}
+		if datFile.Header.NarrowOffsets() != narrow {
+			t.Errorf("narrow %v: NarrowOffsets() = %v", narrow, datFile.Header.NarrowOffsets())
+		}
+
+		for i, entry := range testEntries {
+			id := uint32(firstTestBaseID + i)
+			got, err := datFile.ExtractByBaseID(id)
+			if err != nil {
+				t.Fatalf("narrow %v: ExtractByBaseID(%d): %v", narrow, id, err)
+			}
+			if !bytes.Equal(got, entry.data) {
+				t.Errorf("narrow %v: ExtractByBaseID(%d) returned %d bytes not matching the entry", narrow, id, len(got))
+			}
+		}
+
+		for _, row := range spec.fileIDs {
+			got, err := datFile.ExtractByFileID(row[0])
+			if err != nil {
+				t.Fatalf("narrow %v: ExtractByFileID(%d): %v", narrow, row[0], err)
+			}
+			if want := testEntries[row[1]-firstTestBaseID].data; !bytes.Equal(got, want) {
+				t.Errorf("narrow %v: ExtractByFileID(%d) does not match base ID %d", narrow, row[0], row[1])
+			}
+		}
+	}
+}
+
+func TestExtractUnknownIDs(t *testing.T) {
+	datFile := testDat{entries: testEntries, fileIDs: [][2]uint32{{100, firstTestBaseID}}}.open(t, Options{})
+
+	tests := []struct {
+		name     string
+		id       uint32
+		isFileID bool
+	}{
+		{"base ID 0", 0, false},
+		{"base ID past the MFT", uint32(len(datFile.MFTData) + 1), false},
+		{"unknown file ID", 999, true},
+	}
+	for _, test := range tests {
+		if _, err := datFile.Extract(test.id, test.isFileID); err == nil {
+			t.Errorf("%s: Extract(%d, %v) succeeded", test.name, test.id, test.isFileID)
+		}
+	}
+}
+
+func TestOpenRejectsDamagedDats(t *testing.T) {
+	valid := testDat{entries: testEntries}.build(t)
+	mftOffset := bytes.Index(valid, []byte("Mft\x1A"))
+	indexRow := mftOffset + binary.Size(MFTHeader{}) + MftEntryIndexNum*binary.Size(MFTEntry{})
+
+	tests := []struct {
+		name   string
+		damage func(data []byte) []byte
+	}{
+		{"dat identifier", func(data []byte) []byte { data[1] = 'X'; return data }},
+		{"version", func(data []byte) []byte { data[0] = 0x42; return data }},
+		{"MFT identifier", func(data []byte) []byte { data[mftOffset] = 'X'; return data }},
+		{"truncated MFT", func(data []byte) []byte { return data[:len(data)-10] }},
+		{"truncated header", func(data []byte) []byte { return data[:20] }},
+		{"MFT of one entry", func(data []byte) []byte {
+			binary.LittleEndian.PutUint32(data[mftOffset+12:], 1)
+			return data
+		}},
+		{"compressed index row", func(data []byte) []byte {
+			binary.LittleEndian.PutUint16(data[indexRow+12:], CompressionGW2)
+			return data
+		}},
+		{"index row of a partial record", func(data []byte) []byte {
+			binary.LittleEndian.PutUint32(data[indexRow+8:], 12)
+			return data
+		}},
+		{"index row past the end", func(data []byte) []byte {
+			binary.LittleEndian.PutUint64(data[indexRow:], uint64(len(data)))
+			binary.LittleEndian.PutUint32(data[indexRow+8:], 8)
+			return data
+		}},
+	}
+	for _, test := range tests {
+		data := test.damage(bytes.Clone(valid))
+		if _, err := OpenReaderAt(bytes.NewReader(data), int64(len(data))); err == nil {
+			t.Errorf("%s: OpenReaderAt succeeded", test.name)
+		}
+	}
+}
+
+func TestHeaderValidate(t *testing.T) {
+	const fileSize = 4096
+	headerSize := uint32(binary.Size(Header{}))
+	valid := Header{HeaderSize: headerSize, ChunkSize: 0x200, MftOffset: 1024, MftSize: 1024}
+	if err := valid.Validate(fileSize); err != nil {
+		t.Fatalf("Validate of a valid header: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		damage func(header *Header)
+		want   string
+	}{
+		{"header size 0", func(h *Header) { h.HeaderSize = 0 }, "header size"},
+		{"header size below the header", func(h *Header) { h.HeaderSize = headerSize - 1 }, "header size"},
+		{"header size past the file", func(h *Header) { h.HeaderSize = fileSize + 1 }, "header size"},
+		{"chunk size 0", func(h *Header) { h.ChunkSize = 0 }, "chunk size"},
+		{"MFT past the file", func(h *Header) { h.MftOffset = fileSize }, "MFT"},
+		{"MFT size past the file", func(h *Header) { h.MftSize = fileSize }, "MFT"},
+		{"MFT offset overflowing", func(h *Header) { h.MftOffset = math.MaxUint64 }, "MFT"},
+	}
+	for _, test := range tests {
+		header := valid
+		test.damage(&header)
+		if err := header.Validate(fileSize); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: Validate returned %v, want an error about the %s", test.name, err, test.want)
+		}
+	}
+
+	// A header exactly as large as the file is plausible
+	header := valid
+	header.HeaderSize, header.MftOffset, header.MftSize = fileSize, fileSize, 0
+	if err := header.Validate(fileSize); err != nil {
+		t.Errorf("Validate of a header filling the file: %v", err)
+	}
+}
+
+func TestLogger(t *testing.T) {
+	spec := testDat{entries: testEntries, fileIDs: [][2]uint32{{100, firstTestBaseID + 1}}}
+
+	// Without Options.Logger nothing reaches the default loggers either
+	var defaults bytes.Buffer
+	previous, previousOutput := slog.Default(), log.Writer()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&defaults, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	log.SetOutput(&defaults)
+	defer func() {
+		slog.SetDefault(previous)
+		log.SetOutput(previousOutput)
+	}()
+	datFile := spec.open(t, Options{})
+	if _, err := datFile.ExtractByFileID(100); err != nil {
+		t.Fatal(err)
+	}
+	if defaults.Len() != 0 {
+		t.Errorf("opening and extracting without a logger logged %q", defaults.String())
+	}
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	datFile = spec.open(t, Options{Logger: logger})
+	if _, err := datFile.ExtractByFileID(100); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"level=DEBUG", `msg="Opening .dat file"`, `msg="Resolved file ID"`, "fileID=100"} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("logs do not contain %s:\n%s", want, logs.String())
+		}
+	}
+	if defaults.Len() != 0 {
+		t.Errorf("logging to Options.Logger also logged %q to the defaults", defaults.String())
+	}
+
+	// Debug messages are dropped by a logger above debug level
+	logs.Reset()
+	logger = slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	datFile = spec.open(t, Options{Logger: logger})
+	if _, err := datFile.ExtractByFileID(100); err != nil {
+		t.Fatal(err)
+	}
+	if logs.Len() != 0 {
+		t.Errorf("a logger at info level received %q", logs.String())
+	}
+}
+
+// BenchmarkResolveFileID compares the file ID map built at load time with the
+// linear scan of MFTIndexData it replaced, on an index of 100k file IDs.
+func BenchmarkResolveFileID(b *testing.B) {
+	const count = 100000
+	fileIDs := make([][2]uint32, count)
+	for i := range fileIDs {
+		fileIDs[i] = [2]uint32{uint32(i + 1), firstTestBaseID}
+	}
+	datFile := testDat{entries: testEntries[:1], fileIDs: fileIDs}.open(b, Options{})
+	last := uint32(count)
+
+	b.Run("map", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := datFile.resolveIndex(last, true); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("linear scan", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			found := false
+			for _, entry := range datFile.MFTIndexData {
+				if entry.FileID == last {
+					found = true
+					break
+				}
+			}
+			if !found {
+				b.Fatal("file ID not found")
+			}
+		}
+	})
+}
+
+func BenchmarkExtract(b *testing.B) {
+	entries := make([]testEntry, len(benchmarkPayloadSizes))
+	for i, size := range benchmarkPayloadSizes {
+		entries[i] = testEntry{data: testPayload(size), compressed: true}
+	}
+	datFile := testDat{entries: entries}.open(b, Options{})
+
+	for i, size := range benchmarkPayloadSizes {
+		id := uint32(firstTestBaseID + i)
+		b.Run(fmt.Sprintf("%dB", size), func(b *testing.B) {
+			b.SetBytes(int64(size))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := datFile.Extract(id, false); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	spec := testDat{entries: testEntries[:2]}
+	path := spec.write(t)
+	datFile, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer datFile.Close()
+
+	if err := datFile.Refresh(); err != nil {
+		t.Fatalf("Refresh of an unchanged dat: %v", err)
+	}
+	if len(datFile.MFTData) != 5 {
+		t.Fatalf("%d MFT rows after an unchanged refresh, want 5", len(datFile.MFTData))
+	}
+
+	// The game appends entries and rewrites the MFT
+	grown := testDat{entries: testEntries, fileIDs: [][2]uint32{{100, firstTestBaseID + 3}}}.build(t)
+	if err := os.WriteFile(path, grown, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := datFile.Refresh(); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if len(datFile.MFTData) != len(testEntries)+3 {
+		t.Errorf("%d MFT rows after refreshing, want %d", len(datFile.MFTData), len(testEntries)+3)
+	}
+	for i, entry := range testEntries {
+		if got, err := datFile.ExtractByBaseID(uint32(firstTestBaseID + i)); err != nil || !bytes.Equal(got, entry.data) {
+			t.Errorf("ExtractByBaseID(%d) after refreshing returned %d bytes, %v", firstTestBaseID+i, len(got), err)
+		}
+	}
+	if _, err := datFile.ExtractByFileID(100); err != nil {
+		t.Errorf("ExtractByFileID of a file ID added by the refresh: %v", err)
+	}
+
+	// A damaged rewrite leaves the previous tables in place
+	if err := os.WriteFile(path, grown[:100], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := datFile.Refresh(); err == nil {
+		t.Error("Refresh of a truncated dat succeeded")
+	}
+	if len(datFile.MFTData) != len(testEntries)+3 {
+		t.Errorf("%d MFT rows after a failed refresh, want %d", len(datFile.MFTData), len(testEntries)+3)
+	}
+
+	datFile.Close()
+	if err := datFile.Refresh(); err == nil {
+		t.Error("Refresh of a closed dat succeeded")
+	}
+}
+
+func TestExtractRaw(t *testing.T) {
+	entries := append(slices.Clone(testEntries), testEntry{data: []byte("secret"), flag: EntryFlagEncrypted})
+	datFile := testDat{entries: entries}.open(t, Options{})
+
+	// Stored entries have no decompressed form
+	raw, decompressed, err := datFile.ExtractRaw(firstTestBaseID, false)
+	if err != nil || !bytes.Equal(raw, testEntries[0].data) || decompressed != nil {
+		t.Errorf("ExtractRaw of a stored entry = %q, %q, %v", raw, decompressed, err)
+	}
+
+	raw, decompressed, err = datFile.ExtractRaw(firstTestBaseID+1, false)
+	if err != nil {
+		t.Fatalf("ExtractRaw of a compressed entry: %v", err)
+	}
+	if !bytes.Equal(raw, testDeflate(t, testEntries[1].data)) || !bytes.Equal(decompressed, testEntries[1].data) {
+		t.Errorf("ExtractRaw of a compressed entry returned %d raw and %d decompressed bytes not matching the entry", len(raw), len(decompressed))
+	}
+
+	// Encrypted entries still give their on-disk bytes
+	raw, decompressed, err = datFile.ExtractRaw(uint32(firstTestBaseID+len(testEntries)), false)
+	if !errors.Is(err, ErrEncryptedEntry) || string(raw) != "secret" || decompressed != nil {
+		t.Errorf("ExtractRaw of an encrypted entry = %q, %q, %v, want its raw bytes and ErrEncryptedEntry", raw, decompressed, err)
+	}
+
+	if _, _, err := datFile.ExtractRaw(999, false); !errors.Is(err, ErrEntryNotFound) {
+		t.Errorf("ExtractRaw of an unknown base ID returned %v, want ErrEntryNotFound", err)
+	}
+}
+
+func TestMFTEntryFlags(t *testing.T) {
+	tests := []struct {
+		name                         string
+		entry                        MFTEntry
+		compressed, empty, encrypted bool
+	}{
+		{"stored", MFTEntry{Size: 10}, false, false, false},
+		{"compressed", MFTEntry{Size: 10, CompressionFlag: CompressionGW2}, true, false, false},
+		{"other compression value", MFTEntry{Size: 10, CompressionFlag: 3}, true, false, false},
+		{"empty", MFTEntry{CompressionFlag: CompressionGW2}, true, true, false},
+		{"encrypted", MFTEntry{Size: 10, EntryFlag: EntryFlagEncrypted | 0x3}, false, false, true},
+		{"other entry flags", MFTEntry{Size: 10, EntryFlag: 0x3}, false, false, false},
+	}
+	for _, test := range tests {
+		entry := test.entry
+		if entry.IsCompressed() != test.compressed || entry.IsEmpty() != test.empty || entry.IsEncrypted() != test.encrypted {
+			t.Errorf("%s: IsCompressed %v, IsEmpty %v, IsEncrypted %v, want %v, %v, %v", test.name,
+				entry.IsCompressed(), entry.IsEmpty(), entry.IsEncrypted(), test.compressed, test.empty, test.encrypted)
+		}
+	}
+}
+
+func TestOnProgress(t *testing.T) {
+	type call struct{ done, total uint32 }
+	var (
+		mu    sync.Mutex
+		calls []call
+	)
+	datFile := testDat{entries: testEntries}.open(t, Options{OnProgress: func(done, total uint32) {
+		mu.Lock()
+		calls = append(calls, call{done, total})
+		mu.Unlock()
+	}})
+	large := uint32(firstTestBaseID + 2) // 200000 bytes
+
+	tests := []struct {
+		name    string
+		extract func() error
+	}{
+		{"Extract", func() error {
+			_, err := datFile.ExtractByBaseID(large)
+			return err
+		}},
+		{"ExtractAll", func() error {
+			_, err := datFile.ExtractAll(context.Background(), []uint32{large}, 1)
+			return err
+		}},
+	}
+	for _, test := range tests {
+		calls = nil
+		if err := test.extract(); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		size := uint32(len(testEntries[2].data))
+		if len(calls) < int(size/BlockSize)+1 {
+			t.Fatalf("%s: OnProgress called %d times for %d bytes: %v", test.name, len(calls), size, calls)
+		}
+		for i, c := range calls {
+			if c.total != size || c.done > size || (i > 0 && c.done < calls[i-1].done) {
+				t.Errorf("%s: call %d reported %d of %d bytes after %d", test.name, i, c.done, c.total, calls[max(i-1, 0)].done)
+			}
+		}
+		if last := calls[len(calls)-1]; last.done != size {
+			t.Errorf("%s: last call reported %d of %d bytes", test.name, last.done, last.total)
+		}
+	}
+
+	// Stored entries are not inflated
+	calls = nil
+	if _, err := datFile.ExtractByBaseID(firstTestBaseID); err != nil || len(calls) != 0 {
+		t.Errorf("Extract of a stored entry returned %v and reported %v", err, calls)
+	}
+}
+
+func TestExtractPastEnd(t *testing.T) {
+	valid := testDat{entries: testEntries}.build(t)
+	mftOffset := bytes.Index(valid, []byte("Mft\x1A"))
+	firstRow := mftOffset + binary.Size(MFTHeader{}) + (firstTestBaseID-1)*binary.Size(MFTEntry{})
+	entryOffset := int64(binary.Size(Header{})) // Where the first entry is stored
+
+	tests := []struct {
+		name   string
+		damage func(data []byte)
+		fault  func(p []byte) (int, error)
+	}{
+		{"offset past the end", func(data []byte) {
+			binary.LittleEndian.PutUint64(data[firstRow:], uint64(len(data)))
+		}, nil},
+		{"size past the end", func(data []byte) {
+			binary.LittleEndian.PutUint32(data[firstRow+8:], uint32(len(data)))
+		}, nil},
+		{"offset overflowing", func(data []byte) {
+			binary.LittleEndian.PutUint64(data[firstRow:], math.MaxUint64-2)
+		}, nil},
+		{"short read", nil, func(p []byte) (int, error) { return len(p) / 2, io.ErrUnexpectedEOF }},
+	}
+	for _, test := range tests {
+		data := bytes.Clone(valid)
+		if test.damage != nil {
+			test.damage(data)
+		}
+		r := &faultyReaderAt{r: bytes.NewReader(data), at: entryOffset}
+		datFile, err := OpenReaderAt(r, int64(len(data)))
+		if err != nil {
+			t.Fatalf("%s: OpenReaderAt: %v", test.name, err)
+		}
+		r.fault = test.fault
+		if got, err := datFile.ExtractByBaseID(firstTestBaseID); err == nil {
+			t.Errorf("%s: ExtractByBaseID succeeded with %q", test.name, got)
+		}
+	}
+}
+
+func TestSpanFits(t *testing.T) {
+	tests := []struct {
+		offset, size, limit uint64
+		want                bool
+	}{
+		{0, 0, 0, true},
+		{0, 10, 10, true},
+		{5, 5, 10, true},
+		{10, 0, 10, true},
+		{5, 6, 10, false},
+		{11, 0, 10, false},
+		{math.MaxUint64, 1, math.MaxUint64, false},
+		{math.MaxUint64 - 2, 4, 100, false}, // offset+size wraps around to 1
+		{1, math.MaxUint64, 100, false},
+		{0, math.MaxUint64, math.MaxUint64, true},
+	}
+	for _, test := range tests {
+		if got := spanFits(test.offset, test.size, test.limit); got != test.want {
+			t.Errorf("spanFits(%d, %d, %d) = %v, want %v", test.offset, test.size, test.limit, got, test.want)
+		}
+	}
+}
+
+func TestExtractEmptyRows(t *testing.T) {
+	data := testDat{entries: []testEntry{
+		{data: []byte{}, deflated: true}, // Compressed flag, no data
+		{data: []byte{}},
+		{data: []byte("stored entry")},
+	}}.build(t)
+
+	// Give the compressed row a stale offset past the end of the file, as
+	// deleted rows may have
+	mftOffset := bytes.Index(data, []byte("Mft\x1A"))
+	row := mftOffset + binary.Size(MFTHeader{}) + (firstTestBaseID-1)*binary.Size(MFTEntry{})
+	binary.LittleEndian.PutUint64(data[row:], uint64(len(data))+100)
+	path := filepath.Join(t.TempDir(), "Gw2.dat")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	extractions := []struct {
+		name    string
+		extract func(datFile *DatFile, id uint32) ([]byte, error)
+	}{
+		{"Extract", func(datFile *DatFile, id uint32) ([]byte, error) { return datFile.Extract(id, false) }},
+		{"ExtractRaw", func(datFile *DatFile, id uint32) ([]byte, error) {
+			raw, _, err := datFile.ExtractRaw(id, false)
+			return raw, err
+		}},
+		{"ExtractTo", func(datFile *DatFile, id uint32) ([]byte, error) {
+			var b bytes.Buffer
+			_, err := datFile.ExtractTo(id, false, &b)
+			return b.Bytes(), err
+		}},
+		{"ExtractVerified", func(datFile *DatFile, id uint32) ([]byte, error) { return datFile.ExtractVerified(id, false) }},
+	}
+	for _, mapped := range []bool{false, true} {
+		datFile, err := OpenWithOptions(path, Options{Mapped: mapped})
+		if err != nil {
+			t.Fatalf("mapped %v: OpenWithOptions: %v", mapped, err)
+		}
+		for _, extraction := range extractions {
+			for _, id := range []uint32{firstTestBaseID, firstTestBaseID + 1} {
+				got, err := extraction.extract(datFile, id)
+				if err != nil || len(got) != 0 {
+					t.Errorf("mapped %v: %s(%d) = %q, %v; want no data", mapped, extraction.name, id, got, err)
+				}
+			}
+		}
+		if format, err := datFile.PeekFormat(firstTestBaseID); err != nil || format != FormatUnknown {
+			t.Errorf("mapped %v: PeekFormat of an empty row = %v, %v", mapped, format, err)
+		}
+
+		datFile.Close()
+		if _, err := datFile.Extract(firstTestBaseID, false); err == nil {
+			t.Errorf("mapped %v: Extract of an empty row after Close succeeded", mapped)
+		}
+	}
+}
+
+func TestPerEntryTimeout(t *testing.T) {
+	entries := []testEntry{{data: testPayload(4 << 20), compressed: true}, {data: []byte("stored entry")}}
+	spec := testDat{entries: entries}
+
+	datFile := spec.open(t, Options{PerEntryTimeout: time.Millisecond})
+	_, err := datFile.ExtractByBaseID(firstTestBaseID)
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), fmt.Sprintf("MFT entry %d", firstTestBaseID-1)) {
+		t.Errorf("ExtractByBaseID past the timeout returned %v, want a deadline error naming the entry", err)
+	}
+	if got, err := datFile.ExtractByBaseID(firstTestBaseID + 1); err != nil || !bytes.Equal(got, entries[1].data) {
+		t.Errorf("ExtractByBaseID of a stored entry = %q, %v", got, err)
+	}
+
+	datFile = spec.open(t, Options{PerEntryTimeout: time.Minute})
+	if got, err := datFile.ExtractByBaseID(firstTestBaseID); err != nil || !bytes.Equal(got, entries[0].data) {
+		t.Errorf("ExtractByBaseID within the timeout returned %d bytes, %v", len(got), err)
+	}
+}
+
+func TestExtractContextCancel(t *testing.T) {
+	const size = 400000
+	data := testPayload(size)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Cancel once the first block of output has been produced, so the
+	// decode is stopped halfway rather than before it starts
+	var reports []uint32
+	datFile := testDat{entries: []testEntry{{data: data, compressed: true}}}.open(t, Options{
+		CacheSize: 1 << 20,
+		OnProgress: func(done, total uint32) {
+			reports = append(reports, done)
+			if done >= BlockSize {
+				cancel()
+			}
+		},
+	})
+	got, err := datFile.ExtractContext(ctx, firstTestBaseID, false)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("ExtractContext cancelled mid-decode returned %v, want context.Canceled", err)
+	}
+	if got != nil {
+		t.Errorf("ExtractContext cancelled mid-decode returned %d bytes", len(got))
+	}
+	if len(reports) != 2 || reports[1] >= size {
+		t.Errorf("progress reported at %v, want the start and one block before cancelling", reports)
+	}
+
+	// Nothing partial was cached
+	got, err = datFile.ExtractContext(context.Background(), firstTestBaseID, false)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Errorf("ExtractContext after the cancellation returned %d bytes, %v", len(got), err)
+	}
+}
+
+// BenchmarkExtractHandle compares reading entries through the handle the
+// DatFile keeps open with opening the file for every entry, as extraction
+// once did. The entries are 4 KiB and stored, so the open dominates.
+func BenchmarkExtractHandle(b *testing.B) {
+	const count = 64
+	entries := make([]testEntry, count)
+	for i := range entries {
+		entries[i] = testEntry{data: testPayload(4096 + i)}
+	}
+	path := testDat{entries: entries}.write(b)
+	datFile, err := Open(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer datFile.Close()
+
+	b.Run("retained", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := datFile.ExtractByBaseID(uint32(firstTestBaseID + i%count)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("reopen per call", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			mftEntry := datFile.MFTData[firstTestBaseID-1+i%count]
+			file, err := os.Open(path)
+			if err != nil {
+				b.Fatal(err)
+			}
+			buffer := make([]byte, mftEntry.Size)
+			if _, err := file.Seek(int64(mftEntry.Offset), io.SeekStart); err != nil {
+				b.Fatal(err)
+			}
+			if _, err := io.ReadFull(file, buffer); err != nil {
+				b.Fatal(err)
+			}
+			file.Close()
+		}
+	})
+}
+
+func TestSystemEntries(t *testing.T) {
+	datFile := testDat{entries: testEntries}.open(t, Options{})
+	if got := datFile.SystemEntries(); !slices.Equal(got, []int{MftEntryHeaderNum, MftEntryIndexNum, MftEntryMftNum}) {
+		t.Errorf("SystemEntries = %v, want the header, index and MFT rows", got)
+	}
+	for index := range datFile.MFTData {
+		if got := datFile.IsIndexTable(index); got != (index == MftEntryIndexNum) {
+			t.Errorf("IsIndexTable(%d) = %v", index, got)
+		}
+	}
+
+	// The rows describe the dat itself
+	if row := datFile.MFTData[MftEntryMftNum]; row.Offset != datFile.Header.MftOffset || row.Size != datFile.Header.MftSize {
+		t.Errorf("MFT row %+v does not match the header's MFT location", row)
+	}
+	if row := datFile.MFTData[MftEntryHeaderNum]; row.Offset != 0 || row.Size != datFile.Header.HeaderSize {
+		t.Errorf("header row %+v does not match the header", row)
+	}
+
+	short := &DatFile{MFTData: make([]MFTEntry, 2)}
+	if got := short.SystemEntries(); !slices.Equal(got, []int{0, 1}) || !short.IsIndexTable(MftEntryIndexNum) {
+		t.Errorf("SystemEntries of a 2 row MFT = %v", got)
+	}
+	if (&DatFile{}).IsIndexTable(MftEntryIndexNum) {
+		t.Error("IsIndexTable of an empty MFT is true")
+	}
+}
+
+func TestStreamBlockSize(t *testing.T) {
+	tests := []struct {
+		chunkSize uint32
+		want      uint32
+	}{
+		{0x1000, 0x400},
+		{0x10000, BlockSize},
+		{4 * minStreamBlockSize, minStreamBlockSize},
+		{4 * maxStreamBlockSize, maxStreamBlockSize},
+		{0, BlockSize},
+		{0x200, BlockSize},                  // Fewer words than minStreamBlockSize
+		{8 * maxStreamBlockSize, BlockSize}, // More words than maxStreamBlockSize
+		{0x3000, BlockSize},                 // Not a power of two
+		{0x1002, BlockSize},                 // Not a whole number of words
+		{math.MaxUint32, BlockSize},
+	}
+	for _, test := range tests {
+		header := Header{ChunkSize: test.chunkSize}
+		if got := header.StreamBlockSize(); got != test.want {
+			t.Errorf("StreamBlockSize with ChunkSize %#x = %#x, want %#x", test.chunkSize, got, test.want)
+		}
+	}
+}
+
+func TestOptionsBlockSize(t *testing.T) {
+	// Streams in 4 KiB blocks, so the large entry spans many of them
+	entries := []testEntry{
+		{data: testPayload(200000), compressed: true},
+		{data: testPayload(3000), compressed: true},
+		{data: []byte("stored entry")},
+	}
+	spec := testDat{entries: entries, chunkSize: 0x1000}
+
+	tests := []struct {
+		name      string
+		blockSize uint32
+		matches   bool // The block size is the one the entries were written with
+	}{
+		{"from header", BlockSizeFromHeader, true},
+		{"explicit", 0x400, true},
+		{"default", 0, false},
+		{"other explicit", 0x800, false},
+	}
+	for _, test := range tests {
+		datFile := spec.open(t, Options{BlockSize: test.blockSize})
+		large := uint32(firstTestBaseID)
+
+		got, err := datFile.ExtractByBaseID(large)
+		if test.matches && (err != nil || !bytes.Equal(got, entries[0].data)) {
+			t.Errorf("%s: ExtractByBaseID returned %d bytes, %v", test.name, len(got), err)
+		} else if !test.matches && err == nil && bytes.Equal(got, entries[0].data) {
+			t.Errorf("%s: ExtractByBaseID decoded a stream with the wrong block size", test.name)
+		}
+		if err := datFile.VerifyEntry(int(large) - 1); (err == nil) != test.matches {
+			t.Errorf("%s: VerifyEntry returned %v", test.name, err)
+		}
+		if !test.matches {
+			continue
+		}
+
+		var streamed bytes.Buffer
+		if _, err := datFile.ExtractTo(large, false, &streamed); err != nil || !bytes.Equal(streamed.Bytes(), entries[0].data) {
+			t.Errorf("%s: ExtractTo wrote %d bytes, %v", test.name, streamed.Len(), err)
+		}
+		if got, err := datFile.ExtractVerified(large, false); err != nil || !bytes.Equal(got, entries[0].data) {
+			t.Errorf("%s: ExtractVerified returned %d bytes, %v", test.name, len(got), err)
+		}
+		report, err := datFile.Verify(VerifyOptions{CheckCRC: true, Decompress: true})
+		if err != nil || !report.OK() {
+			t.Errorf("%s: Verify = %+v, %v", test.name, report, err)
+		}
+
+		// Replacements, here of the second entry, are compressed in blocks of
+		// the same size
+		replaced := testPayload(100000)
+		if err := datFile.ReplaceEntry(int(large), replaced, true); err != nil {
+			t.Fatalf("%s: ReplaceEntry: %v", test.name, err)
+		}
+		var out bytes.Buffer
+		if _, err := datFile.WriteTo(&out); err != nil {
+			t.Fatalf("%s: WriteTo: %v", test.name, err)
+		}
+		written, err := OpenReaderAtWithOptions(bytes.NewReader(out.Bytes()), int64(out.Len()), Options{BlockSize: test.blockSize})
+		if err != nil {
+			t.Fatalf("%s: OpenReaderAtWithOptions of the written dat: %v", test.name, err)
+		}
+		if got, err := written.ExtractByBaseID(large + 1); err != nil || !bytes.Equal(got, replaced) {
+			t.Errorf("%s: replaced entry has %d bytes, %v", test.name, len(got), err)
+		}
+		if err := written.VerifyEntry(int(large)); err != nil {
+			t.Errorf("%s: VerifyEntry of the replaced entry: %v", test.name, err)
+		}
+	}
+
+	for _, blockSize := range []uint32{1, 0x80, 0x3000, 2 * maxStreamBlockSize} {
+		if _, err := OpenWithOptions(spec.write(t), Options{BlockSize: blockSize}); err == nil {
+			t.Errorf("OpenWithOptions with BlockSize %#x succeeded", blockSize)
+		}
+	}
+}
+
+func TestErrorSentinels(t *testing.T) {
+	corrupt := testDeflate(t, testPayload(5000))
+	corrupt = corrupt[:len(corrupt)/2]
+	datFile := testDat{
+		entries: []testEntry{
+			{data: []byte("secret"), flag: EntryFlagEncrypted},
+			{data: corrupt, deflated: true},
+		},
+		fileIDs: [][2]uint32{{100, firstTestBaseID}},
+	}.open(t, Options{})
+	encrypted, broken := uint32(firstTestBaseID), uint32(firstTestBaseID+1)
+
+	valid := testDat{entries: testEntries}.build(t)
+	mftOffset := bytes.Index(valid, []byte("Mft\x1A"))
+	open := func(damage func(data []byte)) func() error {
+		return func() error {
+			data := bytes.Clone(valid)
+			damage(data)
+			_, err := OpenReaderAt(bytes.NewReader(data), int64(len(data)))
+			return err
+		}
+	}
+
+	tests := []struct {
+		name   string
+		err    func() error
+		target error
+	}{
+		{"Extract of base ID 0", func() error { _, err := datFile.Extract(0, false); return err }, ErrEntryNotFound},
+		{"Extract of an unknown file ID", func() error { _, err := datFile.Extract(999, true); return err }, ErrEntryNotFound},
+		{"ExtractRaw past the MFT", func() error { _, _, err := datFile.ExtractRaw(999, false); return err }, ErrEntryNotFound},
+		{"EntryReader past the MFT", func() error { _, err := datFile.EntryReader(len(datFile.MFTData)); return err }, ErrEntryNotFound},
+		{"VerifyEntry of a negative index", func() error { return datFile.VerifyEntry(-1) }, ErrEntryNotFound},
+		{"PeekFormat past the MFT", func() error { _, err := datFile.PeekFormat(999); return err }, ErrEntryNotFound},
+		{"ReplaceEntry past the MFT", func() error { return datFile.ReplaceEntry(len(datFile.MFTData), nil, false) }, ErrEntryNotFound},
+		{"open with a bad dat identifier", open(func(data []byte) { data[1] = 'X' }), ErrBadMagic},
+		{"open with a bad MFT identifier", open(func(data []byte) { data[mftOffset] = 'X' }), ErrBadMagic},
+		{"ParseStrings of another format", func() error { _, err := ParseStrings([]byte("STRX\x00\x00")); return err }, ErrBadMagic},
+		{"Extract of an encrypted entry", func() error { _, err := datFile.Extract(encrypted, false); return err }, ErrEncryptedEntry},
+		{"Extract by file ID of an encrypted entry", func() error { _, err := datFile.Extract(100, true); return err }, ErrEncryptedEntry},
+		{"ExtractVerified of an encrypted entry", func() error { _, err := datFile.ExtractVerified(encrypted, false); return err }, ErrEncryptedEntry},
+		{"EntryReader of an encrypted entry", func() error { _, err := datFile.EntryReader(int(encrypted) - 1); return err }, ErrEncryptedEntry},
+		{"Extract of a truncated stream", func() error { _, err := datFile.Extract(broken, false); return err }, ErrCorruptStream},
+		{"ExtractVerified of a truncated stream", func() error { _, err := datFile.ExtractVerified(broken, false); return err }, ErrCorruptStream},
+		{"ExtractTo of a truncated stream", func() error { _, err := datFile.ExtractTo(broken, false, io.Discard); return err }, ErrCorruptStream},
+		{"InflateBuffer of a truncated stream", func() error { _, err := InflateBuffer(corrupt, nil, 0); return err }, ErrCorruptStream},
+	}
+	for _, test := range tests {
+		err := test.err()
+		if !errors.Is(err, test.target) {
+			t.Errorf("%s returned %v, want %v", test.name, err, test.target)
+			continue
+		}
+		if err.Error() == test.target.Error() {
+			t.Errorf("%s returned the bare %q, without details", test.name, err)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package dat
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// openStandardStream returns a decompressing reader when buffer starts with a
+// valid gzip or zlib header. A few compressed entries, such as embedded web
+// assets, hold such a standard stream instead of the GW2 codec. Raw DEFLATE
+// has no header to recognise and is not detected. It reports false for
+// anything else, which is taken to be the GW2 codec.
+func openStandardStream(buffer []byte) (io.ReadCloser, bool) {
+	if len(buffer) < 2 {
+		return nil, false
+	}
+
+	switch {
+	case buffer[0] == 0x1f && buffer[1] == 0x8b:
+		if r, err := gzip.NewReader(bytes.NewReader(buffer)); err == nil {
+			return r, true
+		}
+	case buffer[0]&0x0f == 8 && buffer[0]>>4 <= 7 && binary.BigEndian.Uint16(buffer)%31 == 0:
+		// CM 8 (deflate), a window of at most 32 KiB and a valid FCHECK
+		if r, err := zlib.NewReader(bytes.NewReader(buffer)); err == nil {
+			return r, true
+		}
+	}
+	return nil, false
+}
+
+// inflateStandard reads the whole of a standard stream, stopping after limit
+// bytes when limit is non-zero and refusing output larger than maxSize, unless
+// maxSize is 0.
+func inflateStandard(r io.Reader, limit, maxSize uint32) ([]byte, error) {
+	if limit != 0 && (maxSize == 0 || limit <= maxSize) {
+		return io.ReadAll(io.LimitReader(r, int64(limit)))
+	}
+	if maxSize == 0 {
+		return io.ReadAll(r)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, int64(maxSize)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > int(maxSize) {
+		return nil, fmt.Errorf("decompressed size exceeds the %d byte limit", maxSize)
+	}
+	return data, nil
+}
@@ -0,0 +1,120 @@
+package dat
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"strings"
+	"testing"
+)
+
+// testGzip and testZlib compress data with the standard library.
+func testGzip(t testing.TB, data []byte) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	w := gzip.NewWriter(&b)
+	w.Write(data)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func testZlib(t testing.TB, data []byte) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	w := zlib.NewWriter(&b)
+	w.Write(data)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestOpenStandardStream(t *testing.T) {
+	data := testPayload(5000)
+	tests := []struct {
+		name   string
+		buffer []byte
+		want   bool
+	}{
+		{"gzip", testGzip(t, data), true},
+		{"zlib", testZlib(t, data), true},
+		{"GW2 stream", testDeflate(t, data), false},
+		{"gzip magic with a bad header", []byte{0x1f, 0x8b, 0, 0}, false},
+		{"zlib header with a bad check", []byte{0x78, 0x9d}, false},
+		{"too short", []byte{0x1f}, false},
+		{"stored text", []byte("plain text"), false},
+	}
+	for _, test := range tests {
+		r, ok := openStandardStream(test.buffer)
+		if ok != test.want {
+			t.Errorf("%s: openStandardStream = %v, want %v", test.name, ok, test.want)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		got, err := io.ReadAll(r)
+		r.Close()
+		if err != nil || !bytes.Equal(got, data) {
+			t.Errorf("%s: read %d bytes, %v, not matching the data", test.name, len(got), err)
+		}
+	}
+}
+
+// inflateStandard reads from the decompressing reader, stood in for by the
+// data itself.
+func TestInflateStandard(t *testing.T) {
+	data := testPayload(5000)
+	tests := []struct {
+		name           string
+		limit, maxSize uint32
+		want           int // Bytes returned, -1 for an error
+	}{
+		{"whole", 0, 0, 5000},
+		{"within the limit", 0, 5000, 5000},
+		{"over the limit", 0, 4999, -1},
+		{"prefix", 100, 0, 100},
+		{"prefix within the limit", 100, 200, 100},
+		{"prefix over the limit", 300, 200, -1},
+	}
+	for _, test := range tests {
+		got, err := inflateStandard(bytes.NewReader(data), test.limit, test.maxSize)
+		switch {
+		case test.want < 0:
+			if err == nil || !strings.Contains(err.Error(), "byte limit") {
+				t.Errorf("%s: inflateStandard returned %v, want the size limit error", test.name, err)
+			}
+		case err != nil:
+			t.Errorf("%s: inflateStandard: %v", test.name, err)
+		case !bytes.Equal(got, data[:test.want]):
+			t.Errorf("%s: inflateStandard returned %d bytes, want the first %d", test.name, len(got), test.want)
+		}
+	}
+}
+
+func TestExtractStandardStreams(t *testing.T) {
+	gzipData, zlibData := testPayload(70000), testPayload(3000)
+	datFile := testDat{entries: []testEntry{
+		{data: testGzip(t, gzipData), deflated: true},
+		{data: testZlib(t, zlibData), deflated: true},
+	}}.open(t, Options{})
+
+	for i, want := range [][]byte{gzipData, zlibData} {
+		id := uint32(firstTestBaseID + i)
+		got, err := datFile.ExtractByBaseID(id)
+		if err != nil || !bytes.Equal(got, want) {
+			t.Errorf("ExtractByBaseID(%d) returned %d bytes, %v, want %d matching bytes", id, len(got), err, len(want))
+		}
+		prefix, err := datFile.ExtractPrefix(id, false, 10)
+		if err != nil || !bytes.Equal(prefix, want[:10]) {
+			t.Errorf("ExtractPrefix(%d, 10) = %q, %v", id, prefix, err)
+		}
+		var b bytes.Buffer
+		if n, err := datFile.ExtractTo(id, false, &b); err != nil || n != int64(len(want)) || !bytes.Equal(b.Bytes(), want) {
+			t.Errorf("ExtractTo(%d) wrote %d bytes, %v", id, n, err)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package dat
+
+import "fmt"
+
+const (
+	pfHeaderSize      = 12 // Magic, flags, zero, header size and content fourCC
+	pfChunkHeaderSize = 16 // FourCC, size, version, header size and descriptor offset
+)
+
+// PFChunk is one typed chunk of a PF file. Offset and Size locate the chunk
+// data, excluding its header, within the PF file.
+type PFChunk struct {
+	FourCC  string
+	Version uint16
+	Offset  uint32
+	Size    uint32
+}
+
+// PFFile is a parsed PF ("packed file") container: a header naming the
+// content type followed by a sequence of typed chunks.
+type PFFile struct {
+	Flags      uint16
+	HeaderSize uint16
+	FourCC     string // Content type, e.g. "MODL", "AMAT" or "eula"
+	Chunks     []PFChunk
+
+	data []byte
+}
+
+// ParsePF reads the PF header of data and enumerates its chunks.
+func ParsePF(data []byte) (*PFFile, error) {
+	if len(data) < pfHeaderSize {
+		return nil, fmt.Errorf("PF header truncated: %d bytes", len(data))
+	}
+	if string(data[0:2]) != "PF" {
+		return nil, fmt.Errorf("not a PF file: %w", ErrBadMagic)
+	}
+
+	r := newByteReader(data)
+	r.Skip(2) // Magic
+	pf := &PFFile{Flags: r.U16(), data: data}
+	r.Skip(2) // Always zero
+	pf.HeaderSize = r.U16()
+	pf.FourCC = string(r.Bytes(4))
+	if pf.HeaderSize < pfHeaderSize || int(pf.HeaderSize) > len(data) {
+		return nil, fmt.Errorf("invalid PF header size %d", pf.HeaderSize)
+	}
+
+	// The size field counts the bytes following it, up to the next chunk.
+	offset := uint64(pf.HeaderSize)
+	for offset < uint64(len(data)) {
+		header := newByteReader(data[offset:])
+		fourCC := string(header.Bytes(4))
+		size := header.U32()
+		version := header.U16()
+		chunkHeaderSize := header.U16()
+		header.Skip(4) // Descriptor offset
+		if header.Err() != nil {
+			return nil, fmt.Errorf("PF chunk header at offset %d truncated", offset)
+		}
+
+		end := offset + 8 + uint64(size)
+		if end > uint64(len(data)) {
+			return nil, fmt.Errorf("PF chunk %q at offset %d truncated: needs %d bytes, have %d", fourCC, offset, end-offset, uint64(len(data))-offset)
+		}
+		if uint64(chunkHeaderSize) < pfChunkHeaderSize || offset+uint64(chunkHeaderSize) > end {
+			return nil, fmt.Errorf("PF chunk %q at offset %d has invalid header size %d", fourCC, offset, chunkHeaderSize)
+		}
+
+		pf.Chunks = append(pf.Chunks, PFChunk{
+			FourCC:  fourCC,
+			Version: version,
+			Offset:  uint32(offset) + uint32(chunkHeaderSize),
+			Size:    uint32(end - offset - uint64(chunkHeaderSize)),
+		})
+		offset = end
+	}
+
+	return pf, nil
+}
+
+// Chunk returns the data of the first chunk with the given fourCC.
+func (pf *PFFile) Chunk(fourCC string) ([]byte, bool) {
+	for _, chunk := range pf.Chunks {
+		if chunk.FourCC == fourCC {
+			return pf.data[chunk.Offset : chunk.Offset+chunk.Size], true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,109 @@
+package dat
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// testChunk is a chunk of a PF file built by testPF.
+type testChunk struct {
+	fourCC  string
+	version uint16
+	data    string
+}
+
+// testPF returns a PF file of the given content type holding chunks.
+func testPF(fourCC string, chunks ...testChunk) []byte {
+	data := []byte("PF")
+	data = binary.LittleEndian.AppendUint16(data, 1) // Flags
+	data = binary.LittleEndian.AppendUint16(data, 0)
+	data = binary.LittleEndian.AppendUint16(data, pfHeaderSize)
+	data = append(data, fourCC...)
+	for _, chunk := range chunks {
+		data = append(data, chunk.fourCC...)
+		data = binary.LittleEndian.AppendUint32(data, uint32(pfChunkHeaderSize-8+len(chunk.data)))
+		data = binary.LittleEndian.AppendUint16(data, chunk.version)
+		data = binary.LittleEndian.AppendUint16(data, pfChunkHeaderSize)
+		data = binary.LittleEndian.AppendUint32(data, 0) // Descriptor offset
+		data = append(data, chunk.data...)
+	}
+	return data
+}
+
+func TestParsePF(t *testing.T) {
+	data := testPF("AMAT", testChunk{"GRMT", 3, "first"}, testChunk{"DX9S", 12, ""}, testChunk{"GRMT", 1, "second"})
+	pf, err := ParsePF(data)
+	if err != nil {
+		t.Fatalf("ParsePF: %v", err)
+	}
+	if pf.FourCC != "AMAT" || pf.Flags != 1 || pf.HeaderSize != pfHeaderSize {
+		t.Errorf("ParsePF header = %q, flags %d, size %d", pf.FourCC, pf.Flags, pf.HeaderSize)
+	}
+
+	want := []PFChunk{
+		{"GRMT", 3, pfHeaderSize + pfChunkHeaderSize, 5},
+		{"DX9S", 12, pfHeaderSize + 2*pfChunkHeaderSize + 5, 0},
+		{"GRMT", 1, pfHeaderSize + 3*pfChunkHeaderSize + 5, 6},
+	}
+	if len(pf.Chunks) != len(want) {
+		t.Fatalf("ParsePF found %d chunks, want %d", len(pf.Chunks), len(want))
+	}
+	for i := range want {
+		if pf.Chunks[i] != want[i] {
+			t.Errorf("chunk %d = %+v, want %+v", i, pf.Chunks[i], want[i])
+		}
+	}
+
+	// Chunk returns the first chunk of a type
+	if chunk, ok := pf.Chunk("GRMT"); !ok || string(chunk) != "first" {
+		t.Errorf("Chunk(GRMT) = %q, %v, want \"first\"", chunk, ok)
+	}
+	if chunk, ok := pf.Chunk("DX9S"); !ok || len(chunk) != 0 {
+		t.Errorf("Chunk(DX9S) = %q, %v, want an empty chunk", chunk, ok)
+	}
+	if _, ok := pf.Chunk("GEOM"); ok {
+		t.Error("Chunk(GEOM) found a chunk")
+	}
+}
+
+func TestParsePFRejects(t *testing.T) {
+	valid := testPF("MODL", testChunk{"GEOM", 1, "mesh data"})
+	sizeField := pfHeaderSize + 4 // Offset of the size of the chunk
+
+	tests := []struct {
+		name     string
+		data     []byte
+		badMagic bool
+	}{
+		{"header truncated", valid[:pfHeaderSize-1], false},
+		{"bad magic", append([]byte("XF"), valid[2:]...), true},
+		{"header size too small", func() []byte {
+			data := append([]byte(nil), valid...)
+			binary.LittleEndian.PutUint16(data[6:], 4)
+			return data
+		}(), false},
+		{"chunk header truncated", valid[:pfHeaderSize+10], false},
+		{"chunk data truncated", valid[:len(valid)-1], false},
+		{"chunk header size too small", func() []byte {
+			data := append([]byte(nil), valid...)
+			binary.LittleEndian.PutUint16(data[pfHeaderSize+10:], 8)
+			return data
+		}(), false},
+		{"chunk size past the end", func() []byte {
+			data := append([]byte(nil), valid...)
+			binary.LittleEndian.PutUint32(data[sizeField:], 1000)
+			return data
+		}(), false},
+	}
+	for _, test := range tests {
+		_, err := ParsePF(test.data)
+		if err == nil {
+			t.Errorf("%s: ParsePF succeeded", test.name)
+			continue
+		}
+		if errors.Is(err, ErrBadMagic) != test.badMagic {
+			t.Errorf("%s: ParsePF returned %v, ErrBadMagic expected %v", test.name, err, test.badMagic)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package dat
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// maxWriteOffset is the largest back-reference distance a copy code can
+// encode, and so the amount of history a streaming decoder has to keep.
+const maxWriteOffset = 1 << 17
+
+// reader decompresses a stream incrementally through a sliding window.
+type reader struct {
+	inflater  *inflater
+	window    []uint8 // Decoded history followed by output not yet read
+	readPos   uint32  // Start of the unread output in window
+	writePos  uint32  // End of the decoded output in window
+	remaining uint32  // Decompressed bytes not yet decoded
+}
+
+// NewReader returns a reader that decompresses the GW2-compressed stream read
+// from r, pulling compressed input only as the caller consumes output.
+func NewReader(r io.Reader) (io.Reader, error) {
+	return newReader(r, BlockSize)
+}
+
+// newReader is NewReader for a stream cut into blocks of blockWords words.
+func newReader(r io.Reader, blockWords uint32) (io.Reader, error) {
+	if err := prepareHuffmanTreeDict(); err != nil {
+		return nil, err
+	}
+
+	stateData := &State{InputReader: bufio.NewReader(r), BlockWords: blockWords}
+	outputBufferSize, err := readStreamHeader(stateData)
+	if err != nil {
+		return nil, err
+	}
+	return newWindowReader(stateData, outputBufferSize), nil
+}
+
+// newWindowReader returns a reader producing outputBufferSize decompressed
+// bytes from a State positioned just after the stream header.
+func newWindowReader(stateData *State, outputBufferSize uint32) *reader {
+	return &reader{
+		inflater:  newInflater(stateData),
+		window:    make([]uint8, 2*maxWriteOffset),
+		remaining: outputBufferSize,
+	}
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if r.readPos == r.writePos {
+		if r.remaining == 0 {
+			return 0, io.EOF
+		}
+
+		if r.writePos == uint32(len(r.window)) {
+			// Keep only the history back-references can still reach
+			copy(r.window, r.window[r.writePos-maxWriteOffset:r.writePos])
+			r.writePos = maxWriteOffset
+			r.readPos = r.writePos
+		}
+
+		limit := r.writePos + min(uint32(len(r.window))-r.writePos, r.remaining)
+		position, err := r.inflater.inflate(r.window, r.writePos, limit)
+		r.remaining -= position - r.writePos
+		r.writePos = position
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.window[r.readPos:r.writePos])
+	r.readPos += uint32(n)
+	return n, nil
+}
+
+// ExtractTo writes the contents of the entry identified as in Extract to w
+// and returns the number of bytes written. Compressed entries are inflated
+// through a sliding window instead of into a buffer of the full size.
+func (datFile *DatFile) ExtractTo(number uint32, isFileID bool, w io.Writer) (int64, error) {
+	index, err := datFile.resolveIndex(number, isFileID)
+	if err != nil {
+		return 0, err
+	}
+	return datFile.writeEntryTo(index, w)
+}
+
+// EntryReader returns a reader over the contents of the MFT row at the 0-based
+// index. Compressed entries are inflated as they are read, through a sliding
+// window, and uncompressed ones are passed through, so the reader always
+// yields the decompressed contents. The on-disk bytes are read up front.
+func (datFile *DatFile) EntryReader(index int) (io.ReadCloser, error) {
+	if index < 0 || index >= len(datFile.MFTData) {
+		return nil, fmt.Errorf("MFT index %d out of range [0, %d): %w", index, len(datFile.MFTData), ErrEntryNotFound)
+	}
+
+	buffer, err := datFile.readChecked(index)
+	if err != nil {
+		return nil, err
+	}
+
+	if !datFile.MFTData[index].IsCompressed() || datFile.MFTData[index].IsEmpty() {
+		return io.NopCloser(bytes.NewReader(buffer)), nil
+	}
+	if r, ok := openStandardStream(buffer); ok {
+		return r, nil
+	}
+
+	datFile.debug("Streaming decompressed MFT entry data")
+	r, err := newReader(bytes.NewReader(buffer), datFile.streamBlockSize())
+	if err != nil {
+		return nil, fmt.Errorf("decompression failed: %w", err)
+	}
+	return io.NopCloser(r), nil
+}
+
+// writeEntryTo writes the contents of the MFT row at index to w, streaming
+// compressed entries through a sliding window.
+func (datFile *DatFile) writeEntryTo(index int, w io.Writer) (int64, error) {
+	r, err := datFile.EntryReader(index)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	return io.Copy(w, r)
+}
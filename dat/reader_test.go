@@ -0,0 +1,102 @@
+package dat
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func TestNewReaderRoundTrip(t *testing.T) {
+	for _, size := range testPayloadSizes {
+		data := testPayload(size)
+		compressed := testDeflate(t, data)
+
+		tests := []struct {
+			name  string
+			input io.Reader
+			read  func(r io.Reader) io.Reader
+		}{
+			{"whole reads", bytes.NewReader(compressed), func(r io.Reader) io.Reader { return r }},
+			{"one byte reads", bytes.NewReader(compressed), iotest.OneByteReader},
+			{"short input reads", iotest.HalfReader(bytes.NewReader(compressed)), func(r io.Reader) io.Reader { return r }},
+		}
+		for _, test := range tests {
+			r, err := NewReader(test.input)
+			if err != nil {
+				t.Fatalf("size %d, %s: NewReader: %v", size, test.name, err)
+			}
+			got, err := io.ReadAll(test.read(r))
+			if err != nil {
+				t.Fatalf("size %d, %s: reading: %v", size, test.name, err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("size %d, %s: read %d bytes not matching the input", size, test.name, len(got))
+			}
+		}
+	}
+}
+
+func TestNewReaderTruncated(t *testing.T) {
+	compressed := testDeflate(t, testPayload(70000))
+	r, err := NewReader(bytes.NewReader(compressed[:len(compressed)/2]))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	if _, err := io.ReadAll(r); err == nil {
+		t.Error("reading a truncated stream succeeded")
+	}
+
+	if _, err := NewReader(bytes.NewReader(compressed[:3])); err == nil {
+		t.Error("NewReader of a truncated header succeeded")
+	}
+}
+
+func TestEntryReaderAndExtractTo(t *testing.T) {
+	datFile := testDat{entries: testEntries}.open(t, Options{})
+
+	for i, entry := range testEntries {
+		index := MftEntryMftNum + 1 + i
+		r, err := datFile.EntryReader(index)
+		if err != nil {
+			t.Fatalf("EntryReader(%d): %v", index, err)
+		}
+		got, err := io.ReadAll(r)
+		r.Close()
+		if err != nil || !bytes.Equal(got, entry.data) {
+			t.Errorf("EntryReader(%d) read %d bytes, %v; want the %d byte entry", index, len(got), err, len(entry.data))
+		}
+
+		var buffer bytes.Buffer
+		n, err := datFile.ExtractTo(uint32(index+1), false, &buffer)
+		if err != nil || n != int64(len(entry.data)) || !bytes.Equal(buffer.Bytes(), entry.data) {
+			t.Errorf("ExtractTo(%d) wrote %d bytes, %v; want the %d byte entry", index+1, n, err, len(entry.data))
+		}
+	}
+
+	for _, index := range []int{-1, len(datFile.MFTData)} {
+		if _, err := datFile.EntryReader(index); err == nil {
+			t.Errorf("EntryReader(%d) succeeded", index)
+		}
+	}
+}
+
+func BenchmarkNewReader(b *testing.B) {
+	for _, size := range benchmarkPayloadSizes {
+		compressed := testDeflate(b, testPayload(size))
+		b.Run(fmt.Sprintf("%dB", size), func(b *testing.B) {
+			b.SetBytes(int64(size))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				r, err := NewReader(bytes.NewReader(compressed))
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := io.Copy(io.Discard, r); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
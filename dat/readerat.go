@@ -0,0 +1,157 @@
+package dat
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// checkpointInterval is the distance in decompressed bytes between the
+// checkpoints a DecompressedReaderAt records, a multiple of BlockSize. Each
+// checkpoint keeps maxWriteOffset bytes of history.
+const checkpointInterval = 64 * BlockSize
+
+// checkpoint is a snapshot of the decoder from which inflation can resume.
+type checkpoint struct {
+	position  uint32   // Decompressed offset the snapshot resumes at
+	stateData State    // Bit reader state
+	inflater  inflater // Block trees and pending back-reference; stateData is repointed on restore
+	history   []uint8  // Output preceding position that back-references may reach
+}
+
+// DecompressedReaderAt gives random access to the decompressed contents of a
+// GW2-compressed buffer without inflating all of it up front. It keeps the
+// most recently decoded output in a sliding window and records a checkpoint
+// every checkpointInterval bytes, so a ReadAt behind the window resumes from
+// the nearest checkpoint instead of from the start of the stream.
+type DecompressedReaderAt struct {
+	mu          sync.Mutex
+	size        uint32        // Decompressed size read from the stream header
+	checkpoints []*checkpoint // Indexed by position / checkpointInterval, nil until reached
+
+	inflater    *inflater // Live decoder, nil after a decoding error
+	window      []uint8   // Decoded history followed by the latest output
+	windowStart uint32    // Decompressed offset of window[0]
+	writePos    uint32    // End of the decoded output in window
+}
+
+// NewDecompressedReaderAt parses the stream header of inputBuffer and returns
+// a reader over its decompressed contents. inputBuffer must not be modified
+// while the reader is in use.
+func NewDecompressedReaderAt(inputBuffer []uint8) (*DecompressedReaderAt, error) {
+	stateData, outputBufferSize, err := openStream(inputBuffer, BlockSize)
+	if err != nil {
+		return nil, err
+	}
+
+	f := newInflater(stateData)
+	if stateData.Err != nil {
+		return nil, corruptStream(stateData.Err)
+	}
+
+	r := &DecompressedReaderAt{
+		size:        outputBufferSize,
+		checkpoints: make([]*checkpoint, outputBufferSize/checkpointInterval+1),
+		inflater:    f,
+		window:      make([]uint8, 2*maxWriteOffset),
+	}
+	r.checkpoints[0] = &checkpoint{stateData: *stateData, inflater: *f}
+	return r, nil
+}
+
+// Size returns the decompressed size of the stream.
+func (r *DecompressedReaderAt) Size() int64 {
+	return int64(r.size)
+}
+
+// ReadAt reads len(p) decompressed bytes starting at off. It is safe to call
+// from several goroutines, though calls are serialized.
+func (r *DecompressedReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if off >= int64(r.size) {
+		return 0, io.EOF
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	start := uint32(off)
+	end := uint32(min(off+int64(len(p)), int64(r.size)))
+	n := 0
+	for position := start; position < end; position = start + uint32(n) {
+		if r.inflater != nil && position >= r.windowStart && position < r.windowStart+r.writePos {
+			n += copy(p[n:end-start], r.window[position-r.windowStart:r.writePos])
+			continue
+		}
+
+		r.seek(position)
+		if err := r.advance(); err != nil {
+			return n, err
+		}
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// seek restores the checkpoint nearest before position, unless the live
+// decoder is already between that checkpoint and position.
+func (r *DecompressedReaderAt) seek(position uint32) {
+	index := position / checkpointInterval
+	for r.checkpoints[index] == nil {
+		index--
+	}
+	cp := r.checkpoints[index]
+
+	if r.inflater != nil {
+		decoded := r.windowStart + r.writePos
+		if decoded <= position && decoded >= cp.position {
+			return
+		}
+	}
+
+	stateData := cp.stateData
+	f := cp.inflater
+	f.stateData = &stateData
+	r.inflater = &f
+	r.windowStart = cp.position - uint32(len(cp.history))
+	r.writePos = uint32(copy(r.window, cp.history))
+}
+
+// advance decodes the next piece of output into the window, stopping at the
+// next checkpoint boundary to record it.
+func (r *DecompressedReaderAt) advance() error {
+	if r.writePos == uint32(len(r.window)) {
+		// Keep only the history back-references can still reach
+		copy(r.window, r.window[r.writePos-maxWriteOffset:r.writePos])
+		r.windowStart += r.writePos - maxWriteOffset
+		r.writePos = maxWriteOffset
+	}
+
+	position := r.windowStart + r.writePos
+	nextCheckpoint := (position/checkpointInterval + 1) * checkpointInterval
+	count := min(uint32(len(r.window))-r.writePos, r.size-position, nextCheckpoint-position)
+	writePos, err := r.inflater.inflate(r.window, r.writePos, r.writePos+count)
+	if err != nil {
+		// The decoder state is unusable; the next read starts over from a checkpoint
+		r.inflater = nil
+		return err
+	}
+	r.writePos = writePos
+
+	position = r.windowStart + r.writePos
+	if index := position / checkpointInterval; position%checkpointInterval == 0 && r.checkpoints[index] == nil {
+		historyStart := r.writePos - min(r.writePos, maxWriteOffset)
+		r.checkpoints[index] = &checkpoint{
+			position:  position,
+			stateData: *r.inflater.stateData,
+			inflater:  *r.inflater,
+			history:   append([]uint8(nil), r.window[historyStart:r.writePos]...),
+		}
+	}
+	return nil
+}
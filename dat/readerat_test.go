@@ -0,0 +1,113 @@
+package dat
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+)
+
+func TestDecompressedReaderAt(t *testing.T) {
+	// Long enough for three checkpoints past the start
+	data := testPayload(3*checkpointInterval + 12345)
+	r, err := NewDecompressedReaderAt(testDeflate(t, data))
+	if err != nil {
+		t.Fatalf("NewDecompressedReaderAt: %v", err)
+	}
+	if r.Size() != int64(len(data)) {
+		t.Fatalf("Size() = %d, want %d", r.Size(), len(data))
+	}
+
+	// In order: forward within the window, across checkpoints, back behind
+	// the window to a recorded checkpoint and to the start
+	tests := []struct {
+		name string
+		off  int
+		n    int
+	}{
+		{"start", 0, 100},
+		{"forward in the window", 1000, 5000},
+		{"across the first checkpoint", checkpointInterval - 50, 100},
+		{"far forward", 3*checkpointInterval + 10, 1000},
+		{"back to the second checkpoint", 2 * checkpointInterval, 64},
+		{"back just before a checkpoint", 2*checkpointInterval - 1, 2},
+		{"back to the start", 10, 10},
+		{"spanning two checkpoints", checkpointInterval / 2, 2 * checkpointInterval},
+		{"end", len(data) - 7, 7},
+	}
+	for _, test := range tests {
+		p := make([]byte, test.n)
+		n, err := r.ReadAt(p, int64(test.off))
+		if err != nil || n != test.n {
+			t.Fatalf("%s: ReadAt(%d bytes at %d) = %d, %v", test.name, test.n, test.off, n, err)
+		}
+		if !bytes.Equal(p, data[test.off:test.off+test.n]) {
+			t.Errorf("%s: ReadAt(%d bytes at %d) does not match the data", test.name, test.n, test.off)
+		}
+	}
+
+	for i, cp := range r.checkpoints {
+		if cp == nil || cp.position != uint32(i*checkpointInterval) {
+			t.Errorf("checkpoint %d = %v, want one at %d", i, cp, i*checkpointInterval)
+		}
+	}
+
+	// Reads reaching past the end return what is there and io.EOF
+	p := make([]byte, 100)
+	if n, err := r.ReadAt(p, int64(len(data)-40)); n != 40 || err != io.EOF || !bytes.Equal(p[:n], data[len(data)-40:]) {
+		t.Errorf("ReadAt across the end = %d, %v, want 40 matching bytes and io.EOF", n, err)
+	}
+	if n, err := r.ReadAt(p, int64(len(data))); n != 0 || err != io.EOF {
+		t.Errorf("ReadAt at the end = %d, %v, want 0 and io.EOF", n, err)
+	}
+	if _, err := r.ReadAt(p, -1); err == nil {
+		t.Error("ReadAt at a negative offset succeeded")
+	}
+}
+
+func TestDecompressedReaderAtSections(t *testing.T) {
+	data := testPayload(2*checkpointInterval + 100)
+	r, err := NewDecompressedReaderAt(testDeflate(t, data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Readers of separate sections interleave their ReadAt calls
+	const sections = 4
+	sectionSize := len(data) / sections
+	var wg sync.WaitGroup
+	for i := 0; i < sections; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := i * sectionSize
+			got, err := io.ReadAll(io.NewSectionReader(r, int64(start), int64(sectionSize)))
+			if err != nil || !bytes.Equal(got, data[start:start+sectionSize]) {
+				t.Errorf("section %d: read %d bytes, %v, not matching the data", i, len(got), err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestDecompressedReaderAtCorrupt(t *testing.T) {
+	if _, err := NewDecompressedReaderAt([]byte{1, 2, 3}); err == nil {
+		t.Error("NewDecompressedReaderAt of a truncated header succeeded")
+	}
+
+	// Each read decodes a window ahead, so on a truncated stream even reads
+	// of the start fail; they must keep failing instead of returning stale
+	// output once the decoder has given up
+	compressed := testDeflate(t, testPayload(400000))
+	r, err := NewDecompressedReaderAt(compressed[:len(compressed)/2])
+	if err != nil {
+		t.Fatalf("NewDecompressedReaderAt of a truncated stream: %v", err)
+	}
+	p := make([]byte, 1000)
+	for _, off := range []int64{r.Size() - 1000, 0} {
+		if _, err := r.ReadAt(p, off); !errors.Is(err, ErrCorruptStream) {
+			t.Errorf("ReadAt(%d) of a truncated stream returned %v, want ErrCorruptStream", off, err)
+		}
+	}
+}
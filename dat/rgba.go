@@ -0,0 +1,154 @@
+package dat
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// ExtractTextureRGBA extracts the ATEX texture with the given base ID and
+// decodes its top mip level to 8-bit RGBA pixels, four bytes per pixel in
+// row-major order. DXT1, DXT3 and DXT5 blocks are supported, along with the
+// formats stored the same way (DXT2, DXT4, DXTL and DXTN).
+func (datFile *DatFile) ExtractTextureRGBA(id uint32) (width, height int, pixels []byte, err error) {
+	tex, blocks, err := datFile.extractTextureBlocks(id)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	pixels, err = decodeDXT(tex.Format, int(tex.Width), int(tex.Height), blocks)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	return int(tex.Width), int(tex.Height), pixels, nil
+}
+
+// extractTextureBlocks extracts the ATEX texture with the given base ID and
+// returns its header with the raw DXT/BC blocks of its top mip level.
+func (datFile *DatFile) extractTextureBlocks(id uint32) (*ATEXTexture, []byte, error) {
+	data, err := datFile.ExtractByBaseID(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	tex, err := ParseATEX(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	blocks, err := inflateTextureBuffer(data, tex.Width, tex.Height)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tex, blocks, nil
+}
+
+// decodeDXT decodes the DXT blocks of a width x height image in the given
+// ATEX pixel format to RGBA. Blocks are in row-major order; the parts of edge
+// blocks outside the image are dropped.
+func decodeDXT(format string, width, height int, blocks []byte) ([]byte, error) {
+	dds, ok := ddsFormats[format]
+	if !ok || dds.fourCC == "DX10" {
+		return nil, fmt.Errorf("cannot decode texture format %q to RGBA", format)
+	}
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid texture size %dx%d", width, height)
+	}
+
+	blocksWide, blocksHigh := (width+3)/4, (height+3)/4
+	if len(blocks) < blocksWide*blocksHigh*dds.blockBytes {
+		return nil, fmt.Errorf("texture data truncated: %d bytes for %d blocks of %d", len(blocks), blocksWide*blocksHigh, dds.blockBytes)
+	}
+
+	pixels := make([]byte, width*height*4)
+	var block [16][4]uint8
+	for by := 0; by < blocksHigh; by++ {
+		for bx := 0; bx < blocksWide; bx++ {
+			data := blocks[(by*blocksWide+bx)*dds.blockBytes:]
+			switch dds.fourCC {
+			case "DXT1":
+				decodeColorBlock(data, &block, true)
+			case "DXT3":
+				decodeColorBlock(data[8:], &block, false)
+				decodeExplicitAlpha(data, &block)
+			case "DXT5":
+				decodeColorBlock(data[8:], &block, false)
+				decodeInterpolatedAlpha(data, &block)
+			}
+
+			for i, pixel := range block {
+				x, y := bx*4+i%4, by*4+i/4
+				if x < width && y < height {
+					copy(pixels[(y*width+x)*4:], pixel[:])
+				}
+			}
+		}
+	}
+	return pixels, nil
+}
+
+// expand565 converts an RGB565 color to 8-bit channels, replicating the high
+// bits into the low ones so that full intensity maps to 255.
+func expand565(color uint16) [4]uint8 {
+	r, g, b := uint8(color>>11), uint8(color>>5&0x3F), uint8(color&0x1F)
+	return [4]uint8{r<<3 | r>>2, g<<2 | g>>4, b<<3 | b>>2, 0xFF}
+}
+
+// decodeColorBlock decodes the 8-byte BC1 color part of a block: two RGB565
+// endpoints followed by 2-bit indices, pixel 0 in the lowest bits. With
+// punchThrough, as in DXT1, c0 <= c1 selects the three-color mode whose
+// fourth color is transparent black; DXT3 and DXT5 always use four colors.
+func decodeColorBlock(data []byte, block *[16][4]uint8, punchThrough bool) {
+	c0, c1 := binary.LittleEndian.Uint16(data[0:]), binary.LittleEndian.Uint16(data[2:])
+	indices := binary.LittleEndian.Uint32(data[4:])
+
+	var palette [4][4]uint8
+	palette[0], palette[1] = expand565(c0), expand565(c1)
+	for channel := 0; channel < 3; channel++ {
+		a, b := uint16(palette[0][channel]), uint16(palette[1][channel])
+		if c0 > c1 || !punchThrough {
+			palette[2][channel] = uint8((2*a + b) / 3)
+			palette[3][channel] = uint8((a + 2*b) / 3)
+		} else {
+			palette[2][channel] = uint8((a + b) / 2)
+		}
+	}
+	palette[2][3] = 0xFF
+	if c0 > c1 || !punchThrough {
+		palette[3][3] = 0xFF
+	}
+
+	for i := range block {
+		block[i] = palette[indices>>(2*i)&3]
+	}
+}
+
+// decodeExplicitAlpha applies the 8-byte DXT3 alpha part of a block: 4 bits
+// per pixel, pixel 0 in the lowest bits.
+func decodeExplicitAlpha(data []byte, block *[16][4]uint8) {
+	alphas := binary.LittleEndian.Uint64(data)
+	for i := range block {
+		block[i][3] = uint8(alphas>>(4*i)&0xF) * 0x11
+	}
+}
+
+// decodeInterpolatedAlpha applies the 8-byte DXT5 alpha part of a block: two
+// endpoints followed by 3-bit indices into a palette interpolated between
+// them. When a0 <= a1 the palette has four interpolated values plus 0 and 255.
+func decodeInterpolatedAlpha(data []byte, block *[16][4]uint8) {
+	a0, a1 := uint16(data[0]), uint16(data[1])
+	indices := binary.LittleEndian.Uint64(data) >> 16
+
+	var palette [8]uint8
+	palette[0], palette[1] = uint8(a0), uint8(a1)
+	if a0 > a1 {
+		for i := uint16(1); i < 7; i++ {
+			palette[i+1] = uint8(((7-i)*a0 + i*a1) / 7)
+		}
+	} else {
+		for i := uint16(1); i < 5; i++ {
+			palette[i+1] = uint8(((5-i)*a0 + i*a1) / 5)
+		}
+		palette[6], palette[7] = 0, 0xFF
+	}
+
+	for i := range block {
+		block[i][3] = palette[indices>>(3*i)&7]
+	}
+}
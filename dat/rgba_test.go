@@ -0,0 +1,132 @@
+package dat
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// testColorBlock returns the 8-byte BC1 color part of a block.
+func testColorBlock(c0, c1 uint16, indices uint32) []byte {
+	b := binary.LittleEndian.AppendUint16(nil, c0)
+	b = binary.LittleEndian.AppendUint16(b, c1)
+	return binary.LittleEndian.AppendUint32(b, indices)
+}
+
+func TestDecodeDXT(t *testing.T) {
+	const red, blue, white = 0xF800, 0x001F, 0xFFFF
+
+	// Alpha endpoints 255 and 0, then indices 0, 1, 2 and 7 for the first
+	// four pixels
+	dxt5Alpha := binary.LittleEndian.AppendUint64(nil, 0xFF|0x00<<8|uint64(0|1<<3|2<<6|7<<9)<<16)
+
+	tests := []struct {
+		name          string
+		format        string
+		width, height int
+		blocks        []byte
+		want          [4][4]uint8 // The first row of pixels
+	}{
+		{
+			"DXT1 four colors", "DXT1", 4, 4, testColorBlock(red, blue, 0xE4),
+			[4][4]uint8{{255, 0, 0, 255}, {0, 0, 255, 255}, {170, 0, 85, 255}, {85, 0, 170, 255}},
+		},
+		{
+			// c0 <= c1 selects the mode with a transparent fourth color
+			"DXT1 punch-through", "DXT1", 4, 4, testColorBlock(blue, red, 0xE4),
+			[4][4]uint8{{0, 0, 255, 255}, {255, 0, 0, 255}, {127, 0, 127, 255}, {0, 0, 0, 0}},
+		},
+		{
+			"DXT3 explicit alpha", "DXT3", 4, 4,
+			append(binary.LittleEndian.AppendUint64(nil, 0xFEDCBA9876543210), testColorBlock(white, white, 0)...),
+			[4][4]uint8{{255, 255, 255, 0x00}, {255, 255, 255, 0x11}, {255, 255, 255, 0x22}, {255, 255, 255, 0x33}},
+		},
+		{
+			// Always four colors, even with c0 <= c1
+			"DXT5 interpolated alpha", "DXT5", 4, 4, append(dxt5Alpha, testColorBlock(blue, red, 0xE4)...),
+			[4][4]uint8{{0, 0, 255, 255}, {255, 0, 0, 0}, {85, 0, 170, 218}, {170, 0, 85, 36}},
+		},
+		{
+			"DXTL stored as DXT5", "DXTL", 4, 4, append(dxt5Alpha, testColorBlock(blue, red, 0xE4)...),
+			[4][4]uint8{{0, 0, 255, 255}, {255, 0, 0, 0}, {85, 0, 170, 218}, {170, 0, 85, 36}},
+		},
+	}
+	for _, test := range tests {
+		pixels, err := decodeDXT(test.format, test.width, test.height, test.blocks)
+		if err != nil {
+			t.Errorf("%s: decodeDXT: %v", test.name, err)
+			continue
+		}
+		if len(pixels) != test.width*test.height*4 {
+			t.Errorf("%s: decodeDXT returned %d bytes", test.name, len(pixels))
+			continue
+		}
+		for x, want := range test.want {
+			if got := pixels[x*4 : x*4+4]; !bytes.Equal(got, want[:]) {
+				t.Errorf("%s: pixel %d = %v, want %v", test.name, x, got, want)
+			}
+		}
+	}
+}
+
+func TestDecodeDXTEdges(t *testing.T) {
+	// A 5x2 image spans two blocks; the second one only shows its first column
+	blocks := append(testColorBlock(0xF800, 0, 0), testColorBlock(0x001F, 0, 0)...)
+	pixels, err := decodeDXT("DXT1", 5, 2, blocks)
+	if err != nil {
+		t.Fatalf("decodeDXT: %v", err)
+	}
+	if len(pixels) != 5*2*4 {
+		t.Fatalf("decodeDXT returned %d bytes, want %d", len(pixels), 5*2*4)
+	}
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 5; x++ {
+			want := []byte{255, 0, 0, 255}
+			if x == 4 {
+				want = []byte{0, 0, 255, 255}
+			}
+			if got := pixels[(y*5+x)*4:][:4]; !bytes.Equal(got, want) {
+				t.Errorf("pixel (%d, %d) = %v, want %v", x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestDecodeDXTRejects(t *testing.T) {
+	tests := []struct {
+		name          string
+		format        string
+		width, height int
+		blocks        []byte
+	}{
+		{"unknown format", "ABCD", 4, 4, make([]byte, 8)},
+		{"BC5", "3DCX", 4, 4, make([]byte, 16)},
+		{"zero width", "DXT1", 0, 4, make([]byte, 8)},
+		{"truncated", "DXT1", 8, 4, make([]byte, 8)},
+		{"truncated DXT5", "DXT5", 4, 4, make([]byte, 8)},
+	}
+	for _, test := range tests {
+		if _, err := decodeDXT(test.format, test.width, test.height, test.blocks); err == nil {
+			t.Errorf("%s: decodeDXT succeeded", test.name)
+		}
+	}
+}
+
+func TestExtractTextureRGBA(t *testing.T) {
+	datFile := testDat{entries: []testEntry{
+		{data: testTexture("DXT1", 4, 4, 0, nil, 0x001FF800, 0), compressed: true},
+		{data: testTexture("3DCX", 4, 4, 0, nil, 1, 2, 3, 4), compressed: true},
+	}}.open(t, Options{})
+
+	width, height, pixels, err := datFile.ExtractTextureRGBA(firstTestBaseID)
+	if err != nil {
+		t.Fatalf("ExtractTextureRGBA: %v", err)
+	}
+	if width != 4 || height != 4 || !bytes.Equal(pixels, bytes.Repeat([]byte{255, 0, 0, 255}, 16)) {
+		t.Errorf("ExtractTextureRGBA = %dx%d, %v; want 4x4 of red", width, height, pixels)
+	}
+
+	if _, _, _, err := datFile.ExtractTextureRGBA(firstTestBaseID + 1); err == nil {
+		t.Error("ExtractTextureRGBA of a BC5 texture succeeded")
+	}
+}
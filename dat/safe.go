@@ -0,0 +1,57 @@
+package dat
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// PanicError is returned by SafeExtract when extracting an entry panicked.
+type PanicError struct {
+	Index int    // 0-based MFT index of the entry
+	Value any    // Value passed to panic
+	Site  string // Function and line the panic was raised in, if known
+	Stack []byte // Stack of the panicking goroutine
+}
+
+func (e *PanicError) Error() string {
+	if e.Site == "" {
+		return fmt.Sprintf("panic extracting MFT entry %d: %v", e.Index, e.Value)
+	}
+	return fmt.Sprintf("panic extracting MFT entry %d: %v (at %s)", e.Index, e.Value, e.Site)
+}
+
+// SafeExtract is like Extract but turns a panic while reading or decompressing
+// the entry into a *PanicError, so one malformed entry cannot bring down a
+// batch job. Fatal errors that exit the process are not recovered.
+func (datFile *DatFile) SafeExtract(number uint32, isFileID bool) (data []byte, err error) {
+	index, err := datFile.resolveIndex(number, isFileID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if value := recover(); value != nil {
+			data, err = nil, &PanicError{Index: index, Value: value, Site: panicSite(), Stack: debug.Stack()}
+		}
+	}()
+	return datFile.extractEntry(context.Background(), index)
+}
+
+// panicSite returns the first frame outside the runtime in the stack of a
+// deferred function running for a panic, which is where the panic started.
+func panicSite() string {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for {
+		frame, more := frames.Next()
+		if frame.Function != "" && !strings.HasPrefix(frame.Function, "runtime.") {
+			return fmt.Sprintf("%s:%d", frame.Function, frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
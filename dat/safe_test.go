@@ -0,0 +1,39 @@
+package dat
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSafeExtract(t *testing.T) {
+	data := testDat{entries: testEntries}.build(t)
+	r := &faultyReaderAt{r: bytes.NewReader(data), at: int64(binary.Size(Header{}))}
+	datFile, err := OpenReaderAt(r, int64(len(data)))
+	if err != nil {
+		t.Fatalf("OpenReaderAt: %v", err)
+	}
+
+	if got, err := datFile.SafeExtract(firstTestBaseID+1, false); err != nil || !bytes.Equal(got, testEntries[1].data) {
+		t.Errorf("SafeExtract = %d bytes, %v", len(got), err)
+	}
+	if _, err := datFile.SafeExtract(999, false); !errors.Is(err, ErrEntryNotFound) {
+		t.Errorf("SafeExtract of an unknown base ID returned %v, want ErrEntryNotFound", err)
+	}
+
+	// Reading the first entry panics
+	r.fault = func([]byte) (int, error) { panic("bad read") }
+	got, err := datFile.SafeExtract(firstTestBaseID, false)
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) {
+		t.Fatalf("SafeExtract of a panicking entry = %q, %v, want a *PanicError", got, err)
+	}
+	if got != nil || panicErr.Index != firstTestBaseID-1 || panicErr.Value != "bad read" || len(panicErr.Stack) == 0 {
+		t.Errorf("SafeExtract returned %q and %+v", got, *panicErr)
+	}
+	if !strings.HasPrefix(panicErr.Site, "skritto/dat.TestSafeExtract.func") || !strings.Contains(err.Error(), panicErr.Site) {
+		t.Errorf("panic site %q, error %q, want the fault function", panicErr.Site, err)
+	}
+}
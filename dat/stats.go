@@ -0,0 +1,32 @@
+package dat
+
+import "math/bits"
+
+// DatStats summarizes the MFT of a dat.
+type DatStats struct {
+	Entries      int    // MFT rows, including the reserved ones
+	Compressed   int    // Rows stored compressed
+	Uncompressed int    // Rows stored as is, including empty ones
+	DiskBytes    uint64 // Sum of the on-disk sizes of all rows
+
+	// SizeHistogram counts rows by on-disk size in powers of two: bucket 0
+	// holds empty rows and bucket i > 0 rows of [2^(i-1), 2^i) bytes.
+	SizeHistogram [33]int
+}
+
+// Stats returns aggregate figures for the MFT. Only MFTData is consulted;
+// nothing is read from the dat or decompressed.
+func (datFile *DatFile) Stats() DatStats {
+	var stats DatStats
+	for _, mftEntry := range datFile.MFTData {
+		stats.Entries++
+		if mftEntry.IsCompressed() {
+			stats.Compressed++
+		} else {
+			stats.Uncompressed++
+		}
+		stats.DiskBytes += uint64(mftEntry.Size)
+		stats.SizeHistogram[bits.Len32(mftEntry.Size)]++
+	}
+	return stats
+}
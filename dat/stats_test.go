@@ -0,0 +1,40 @@
+package dat
+
+import (
+	"math"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	datFile := &DatFile{MFTData: []MFTEntry{
+		{Size: 0},
+		{Size: 1},
+		{Size: 2, CompressionFlag: CompressionGW2},
+		{Size: 3, CompressionFlag: CompressionGW2},
+		{Size: 4},
+		{Size: 0, CompressionFlag: CompressionGW2},
+		{Size: math.MaxUint32},
+	}}
+
+	stats := datFile.Stats()
+	if stats.Entries != 7 || stats.Compressed != 3 || stats.Uncompressed != 4 {
+		t.Errorf("Stats counts %d rows, %d compressed, %d uncompressed; want 7, 3, 4", stats.Entries, stats.Compressed, stats.Uncompressed)
+	}
+	if want := uint64(1+2+3+4) + math.MaxUint32; stats.DiskBytes != want {
+		t.Errorf("DiskBytes = %d, want %d", stats.DiskBytes, want)
+	}
+
+	var histogram [33]int
+	histogram[0] = 2  // Empty
+	histogram[1] = 1  // 1 byte
+	histogram[2] = 2  // 2 and 3 bytes
+	histogram[3] = 1  // 4 bytes
+	histogram[32] = 1 // The largest size
+	if stats.SizeHistogram != histogram {
+		t.Errorf("SizeHistogram = %v, want %v", stats.SizeHistogram, histogram)
+	}
+
+	if empty := (&DatFile{}).Stats(); empty != (DatStats{}) {
+		t.Errorf("Stats of an empty MFT = %+v", empty)
+	}
+}
@@ -0,0 +1,98 @@
+package dat
+
+import (
+	"encoding/binary"
+	"fmt"
+	"unicode/utf16"
+)
+
+const (
+	stringsMagic           = "strs"
+	stringEntryHeaderSize  = 6 // Size, decryption offset and bits per symbol
+	stringsLanguageSize    = 2 // Trailing language ID
+	stringPlainBitsPerChar = 0x10
+)
+
+// StringEntry is one string of a string file. Entries that are not plain
+// UTF-16 are encrypted or packed with a key the dat does not hold; their
+// Text is empty and Raw keeps the undecoded bytes.
+type StringEntry struct {
+	Text             string // UTF-8, converted with DecodeUTF16
+	Encrypted        bool
+	DecryptionOffset uint16
+	BitsPerSymbol    uint16
+	Raw              []byte // Bytes following the entry header, the UTF-16LE text of plain entries
+}
+
+// StringFile is a parsed "strs" localization string table.
+type StringFile struct {
+	Language uint16 // Language ID stored after the last entry
+	Entries  []StringEntry
+}
+
+// ParseStrings decodes the entries of a strs string table.
+func ParseStrings(data []byte) (*StringFile, error) {
+	if len(data) < len(stringsMagic)+stringsLanguageSize {
+		return nil, fmt.Errorf("string file truncated: %d bytes", len(data))
+	}
+	if string(data[:len(stringsMagic)]) != stringsMagic {
+		return nil, fmt.Errorf("not a string file: %w", ErrBadMagic)
+	}
+
+	end := len(data) - stringsLanguageSize
+	stringFile := &StringFile{Language: binary.LittleEndian.Uint16(data[end:])}
+
+	// Each entry's size field covers its own header
+	for offset := len(stringsMagic); offset < end; {
+		header := newByteReader(data[offset:end])
+		size := int(header.U16())
+		entry := StringEntry{DecryptionOffset: header.U16(), BitsPerSymbol: header.U16()}
+		if header.Err() != nil {
+			return nil, fmt.Errorf("string entry %d at offset %d truncated", len(stringFile.Entries), offset)
+		}
+		if size < stringEntryHeaderSize || offset+size > end {
+			return nil, fmt.Errorf("string entry %d at offset %d has invalid size %d", len(stringFile.Entries), offset, size)
+		}
+
+		entry.Raw = data[offset+stringEntryHeaderSize : offset+size]
+		if entry.DecryptionOffset == 0 && entry.BitsPerSymbol == stringPlainBitsPerChar {
+			entry.Text = DecodeUTF16(entry.Raw)
+		} else {
+			entry.Encrypted = true
+		}
+
+		stringFile.Entries = append(stringFile.Entries, entry)
+		offset += size
+	}
+
+	return stringFile, nil
+}
+
+// String returns the text of the entry at index. It reports false when the
+// index is out of range or the entry is encrypted.
+func (stringFile *StringFile) String(index int) (string, bool) {
+	if index < 0 || index >= len(stringFile.Entries) || stringFile.Entries[index].Encrypted {
+		return "", false
+	}
+	return stringFile.Entries[index].Text, true
+}
+
+// DecodeUTF16 converts UTF-16 text to a UTF-8 string. The text is
+// little-endian unless it starts with a big-endian byte order mark; a leading
+// mark of either order is dropped. Surrogate pairs are combined, unpaired
+// surrogates become U+FFFD and a trailing odd byte is ignored.
+func DecodeUTF16(b []byte) string {
+	order := binary.ByteOrder(binary.LittleEndian)
+	switch {
+	case len(b) >= 2 && b[0] == 0xFF && b[1] == 0xFE:
+		b = b[2:]
+	case len(b) >= 2 && b[0] == 0xFE && b[1] == 0xFF:
+		order, b = binary.BigEndian, b[2:]
+	}
+
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		units[i] = order.Uint16(b[2*i:])
+	}
+	return string(utf16.Decode(units))
+}
@@ -0,0 +1,131 @@
+package dat
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+	"unicode/utf16"
+)
+
+// testStringEntry returns a strs entry with the given header fields and raw
+// bytes.
+func testStringEntry(decryptionOffset, bitsPerSymbol uint16, raw []byte) []byte {
+	entry := binary.LittleEndian.AppendUint16(nil, uint16(stringEntryHeaderSize+len(raw)))
+	entry = binary.LittleEndian.AppendUint16(entry, decryptionOffset)
+	entry = binary.LittleEndian.AppendUint16(entry, bitsPerSymbol)
+	return append(entry, raw...)
+}
+
+// testUTF16 returns s as UTF-16LE.
+func testUTF16(s string) []byte {
+	var b []byte
+	for _, unit := range utf16.Encode([]rune(s)) {
+		b = binary.LittleEndian.AppendUint16(b, unit)
+	}
+	return b
+}
+
+// testStrings returns a strs file holding entries, in the given language.
+func testStrings(language uint16, entries ...[]byte) []byte {
+	data := []byte(stringsMagic)
+	for _, entry := range entries {
+		data = append(data, entry...)
+	}
+	return binary.LittleEndian.AppendUint16(data, language)
+}
+
+func TestParseStrings(t *testing.T) {
+	data := testStrings(3,
+		testStringEntry(0, stringPlainBitsPerChar, testUTF16("Lion's Arch")),
+		testStringEntry(0x1234, stringPlainBitsPerChar, []byte{1, 2, 3, 4}),
+		testStringEntry(0, stringPlainBitsPerChar, nil),
+		testStringEntry(0, 8, []byte{5, 6}),
+		testStringEntry(0, stringPlainBitsPerChar, testUTF16("Tyria ✓")),
+	)
+	stringFile, err := ParseStrings(data)
+	if err != nil {
+		t.Fatalf("ParseStrings: %v", err)
+	}
+	if stringFile.Language != 3 {
+		t.Errorf("Language = %d, want 3", stringFile.Language)
+	}
+
+	tests := []struct {
+		text      string
+		encrypted bool
+		raw       int // Length of Raw
+	}{
+		{"Lion's Arch", false, 22},
+		{"", true, 4},
+		{"", false, 0},
+		{"", true, 2},
+		{"Tyria ✓", false, 14},
+	}
+	if len(stringFile.Entries) != len(tests) {
+		t.Fatalf("ParseStrings found %d entries, want %d", len(stringFile.Entries), len(tests))
+	}
+	for i, test := range tests {
+		entry := stringFile.Entries[i]
+		if entry.Text != test.text || entry.Encrypted != test.encrypted || len(entry.Raw) != test.raw {
+			t.Errorf("entry %d = %q, encrypted %v, %d raw bytes; want %q, %v, %d", i, entry.Text, entry.Encrypted, len(entry.Raw), test.text, test.encrypted, test.raw)
+		}
+		text, ok := stringFile.String(i)
+		if ok == test.encrypted || text != test.text {
+			t.Errorf("String(%d) = %q, %v", i, text, ok)
+		}
+	}
+	if _, ok := stringFile.String(len(tests)); ok {
+		t.Error("String past the last entry succeeded")
+	}
+}
+
+func TestParseStringsRejects(t *testing.T) {
+	valid := testStrings(0, testStringEntry(0, stringPlainBitsPerChar, testUTF16("text")))
+
+	tests := []struct {
+		name     string
+		data     []byte
+		badMagic bool
+	}{
+		{"truncated", []byte("strs"), false},
+		{"bad magic", append([]byte("STRX"), valid[4:]...), true},
+		{"entry header truncated", testStrings(0, []byte{8, 0, 0}), false},
+		{"entry size too small", testStrings(0, []byte{2, 0, 0, 0, 0x10, 0}), false},
+		{"entry past the end", valid[:len(valid)-3], false},
+	}
+	for _, test := range tests {
+		_, err := ParseStrings(test.data)
+		if err == nil {
+			t.Errorf("%s: ParseStrings succeeded", test.name)
+			continue
+		}
+		if errors.Is(err, ErrBadMagic) != test.badMagic {
+			t.Errorf("%s: ParseStrings returned %v, ErrBadMagic expected %v", test.name, err, test.badMagic)
+		}
+	}
+}
+
+func TestDecodeUTF16(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"empty", nil, ""},
+		{"ASCII", testUTF16("Lion's Arch"), "Lion's Arch"},
+		{"BMP", testUTF16("Göttingen ✓"), "Göttingen ✓"},
+		{"surrogate pair", testUTF16("🐉"), "🐉"},
+		{"little-endian mark", append([]byte{0xFF, 0xFE}, testUTF16("Tyria")...), "Tyria"},
+		{"big-endian mark", []byte{0xFE, 0xFF, 0x00, 'h', 0x00, 'i'}, "hi"},
+		{"mark only", []byte{0xFF, 0xFE}, ""},
+		{"unpaired high surrogate", []byte{0x3D, 0xD8, 'a', 0x00}, "�a"},
+		{"unpaired low surrogate", []byte{0x09, 0xDC}, "�"},
+		{"trailing odd byte", append(testUTF16("ab"), 'c'), "ab"},
+		{"single byte", []byte{'a'}, ""},
+	}
+	for _, test := range tests {
+		if got := DecodeUTF16(test.data); got != test.want {
+			t.Errorf("%s: DecodeUTF16(%x) = %q, want %q", test.name, test.data, got, test.want)
+		}
+	}
+}
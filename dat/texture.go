@@ -0,0 +1,35 @@
+package dat
+
+import "fmt"
+
+// atexHeaderSize is the size of the fourCC, format, width and height fields.
+const atexHeaderSize = 12
+
+// ATEXTexture is a parsed ATEX-family texture container.
+type ATEXTexture struct {
+	FourCC string // Container type, e.g. "ATEX" or "ATTX"
+	Format string // Pixel format fourCC, e.g. "DXT1", "DXT5" or "3DCX"
+	Width  uint16
+	Height uint16
+	Data   []byte // Payload following the header, still texture-compressed
+}
+
+// ParseATEX reads the header of an ATEX, ATTX, ATEP, ATEC or ATEU texture.
+func ParseATEX(data []byte) (*ATEXTexture, error) {
+	if !DetectFormat(data).IsTexture() {
+		return nil, fmt.Errorf("not an ATEX texture: %w", ErrBadMagic)
+	}
+
+	r := newByteReader(data)
+	tex := &ATEXTexture{
+		FourCC: string(r.Bytes(4)),
+		Format: string(r.Bytes(4)),
+		Width:  r.U16(),
+		Height: r.U16(),
+	}
+	if r.Err() != nil {
+		return nil, fmt.Errorf("ATEX header truncated: %d bytes", len(data))
+	}
+	tex.Data = data[r.Pos():]
+	return tex, nil
+}
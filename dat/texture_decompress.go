@@ -0,0 +1,417 @@
+package dat
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+)
+
+// Texture format flags, describing which parts of a block the codec stores.
+const (
+	textureFlagColor            = 0x10
+	textureFlagAlpha            = 0x20
+	textureFlagDeducedAlphaComp = 0x40
+	textureFlagPlainComp        = 0x80
+	textureFlagBicolorComp      = 0x200
+)
+
+// Texture compression flags, selecting the constant fill passes of a stream.
+const (
+	textureDecodeWhiteColor             = 0x01
+	textureDecodeConstantAlphaFrom4Bits = 0x02
+	textureDecodeConstantAlphaFrom8Bits = 0x04
+	textureDecodePlainColor             = 0x08
+)
+
+// textureFormat describes how a pixel format is laid out in the codec.
+type textureFormat struct {
+	flags           uint16
+	pixelSizeInBits uint16
+}
+
+var textureFormats = map[string]textureFormat{
+	"DXT1": {textureFlagColor | textureFlagAlpha | textureFlagDeducedAlphaComp, 4},
+	"DXT2": {textureFlagColor | textureFlagAlpha | textureFlagPlainComp, 8},
+	"DXT3": {textureFlagColor | textureFlagAlpha | textureFlagPlainComp, 8},
+	"DXT4": {textureFlagColor | textureFlagAlpha | textureFlagPlainComp, 8},
+	"DXT5": {textureFlagColor | textureFlagAlpha | textureFlagPlainComp, 8},
+	"DXTA": {textureFlagAlpha | textureFlagPlainComp, 4},
+	"DXTL": {textureFlagColor | textureFlagAlpha | textureFlagPlainComp, 8},
+	"DXTN": {textureFlagBicolorComp, 8},
+	"3DCX": {textureFlagBicolorComp, 8},
+}
+
+// textureLayout is a textureFormat resolved for a given texture size.
+type textureLayout struct {
+	textureFormat
+	numBlocks         uint32 // Number of 4x4 pixel blocks
+	bytesPerBlock     uint32
+	bytesPerComponent uint32 // Size of the alpha part of a two-component block
+	colorOffset       uint32 // Offset of the color part within a block
+}
+
+func newTextureLayout(format textureFormat, width, height uint16) textureLayout {
+	layout := textureLayout{
+		textureFormat: format,
+		numBlocks:     ((uint32(width) + 3) / 4) * ((uint32(height) + 3) / 4),
+		bytesPerBlock: uint32(format.pixelSizeInBits) * 4 * 4 / 8,
+	}
+
+	plainColorAlpha := uint16(textureFlagPlainComp | textureFlagColor | textureFlagAlpha)
+	hasTwoComponents := format.flags&plainColorAlpha == plainColorAlpha || format.flags&textureFlagBicolorComp != 0
+
+	layout.bytesPerComponent = layout.bytesPerBlock
+	if hasTwoComponents {
+		layout.bytesPerComponent = layout.bytesPerBlock / 2
+		layout.colorOffset = layout.bytesPerComponent
+	}
+	return layout
+}
+
+// textureHuffmanTreeDict returns the read-only tree of run-length codes used
+// by the constant fill passes.
+var textureHuffmanTreeDict = sync.OnceValue(newTextureHuffmanTreeDict)
+
+func newTextureHuffmanTreeDict() *HuffmanTree {
+	var workingBits [MAX_CODE_BITS_LENGTH]int16
+	var workingCode [MAX_SYMBOL_VALUE]int16
+
+	for i := range workingBits {
+		workingBits[i] = -1
+	}
+	for i := range workingCode {
+		workingCode[i] = -1
+	}
+
+	fillTabsHelper(1, 0x01, &workingBits, &workingCode)
+	fillTabsHelper(2, 0x12, &workingBits, &workingCode)
+	for symbol := int16(0x11); symbol >= 0x02; symbol-- {
+		fillTabsHelper(6, symbol, &workingBits, &workingCode)
+	}
+
+	huffmanTree := &HuffmanTree{}
+	createHuffmanTree(huffmanTree, &workingBits, &workingCode)
+	return huffmanTree
+}
+
+// fillTextureBlocks runs one constant fill pass: run-length codes alternate
+// with a flag bit, and write is applied to every flagged block not yet marked
+// in bitmap. readFlag returns whether the current run is filled.
+func fillTextureBlocks(stateData *State, layout textureLayout, bitmap []bool, readFlag func() bool, write func(block uint32)) error {
+	dict := textureHuffmanTreeDict()
+	blockPosition := uint32(0)
+	for blockPosition < layout.numBlocks {
+		var tempCode uint16
+		if err := readCode(dict, stateData, &tempCode); err != nil {
+			return err
+		}
+		fill := readFlag()
+
+		for tempCode > 0 && blockPosition < layout.numBlocks {
+			if !bitmap[blockPosition] {
+				if fill {
+					write(blockPosition)
+				}
+				tempCode--
+			}
+			blockPosition++
+		}
+
+		for blockPosition < layout.numBlocks && bitmap[blockPosition] {
+			blockPosition++
+		}
+	}
+	return nil
+}
+
+// readFlagBit reads the single flag bit following a run-length code.
+func readFlagBit(stateData *State) bool {
+	needBits(stateData, 1)
+	value := readBits(stateData, 1)
+	dropBits(stateData, 1)
+	return value != 0
+}
+
+func decodeWhiteColor(stateData *State, layout textureLayout, alphaBitmap, colorBitmap []bool, outputBuffer []uint8) error {
+	return fillTextureBlocks(stateData, layout, colorBitmap, func() bool { return readFlagBit(stateData) }, func(block uint32) {
+		binary.LittleEndian.PutUint64(outputBuffer[layout.bytesPerBlock*block:], 0xFFFFFFFFFFFFFFFE)
+		alphaBitmap[block] = true
+		colorBitmap[block] = true
+	})
+}
+
+// decodeConstantAlpha fills blocks with alphaValue, or leaves them zeroed
+// when the run's second flag bit is clear.
+func decodeConstantAlpha(stateData *State, layout textureLayout, alphaBitmap []bool, outputBuffer []uint8, alphaValue uint64) error {
+	var isNotNull bool
+	readFlag := func() bool {
+		needBits(stateData, 2)
+		value := readBits(stateData, 1)
+		dropBits(stateData, 1)
+		isNotNull = readBits(stateData, 1) != 0
+		if value != 0 {
+			dropBits(stateData, 1)
+		}
+		return value != 0
+	}
+
+	return fillTextureBlocks(stateData, layout, alphaBitmap, readFlag, func(block uint32) {
+		if isNotNull {
+			binary.LittleEndian.PutUint64(outputBuffer[layout.bytesPerBlock*block:], alphaValue)
+		}
+		alphaBitmap[block] = true
+	})
+}
+
+func decodeConstantAlphaFrom4Bits(stateData *State, layout textureLayout, alphaBitmap []bool, outputBuffer []uint8) error {
+	needBits(stateData, 4)
+	alpha := uint64(readBits(stateData, 4))
+	dropBits(stateData, 4)
+
+	// Explicit 4-bit alpha repeated for all 16 pixels
+	alphaValue := alpha
+	for i := 0; i < 4; i++ {
+		alphaValue |= alphaValue << 4
+	}
+	return decodeConstantAlpha(stateData, layout, alphaBitmap, outputBuffer, alphaValue)
+}
+
+func decodeConstantAlphaFrom8Bits(stateData *State, layout textureLayout, alphaBitmap []bool, outputBuffer []uint8) error {
+	needBits(stateData, 8)
+	alpha := uint64(readBits(stateData, 8))
+	dropBits(stateData, 8)
+
+	// Interpolated alpha block with both endpoints equal and all indices 0
+	return decodeConstantAlpha(stateData, layout, alphaBitmap, outputBuffer, alpha|alpha<<8)
+}
+
+// colorChannel is one channel of a plain color, split into the two endpoint
+// values of a bits-wide channel that best approximate it.
+type colorChannel struct {
+	base, value1, value2 uint32
+	remainder            uint32 // Rounding remainder of base on a 0-12 scale
+}
+
+func plainColorChannel(value, bits uint32) colorChannel {
+	base := (value - (value >> bits)) >> (8 - bits)
+	expanded := (base << (8 - bits)) + (base >> (2*bits - 8))
+	mask := uint32(0x11)
+	if bits == 6 {
+		mask = 0x1111
+	}
+	divisor := uint32(8)
+	if base&mask == mask {
+		divisor--
+	}
+	channel := colorChannel{base: base, value1: base, value2: base, remainder: 12 * (value - expanded) / divisor}
+
+	switch {
+	case channel.remainder < 2:
+	case channel.remainder < 6:
+		channel.value2++
+	case channel.remainder < 10:
+		channel.value1++
+	default:
+		channel.value1++
+		channel.value2++
+	}
+	return channel
+}
+
+func decodePlainColor(stateData *State, layout textureLayout, colorBitmap []bool, outputBuffer []uint8) error {
+	needBits(stateData, 24)
+	blue := readBits(stateData, 8)
+	dropBits(stateData, 8)
+	green := readBits(stateData, 8)
+	dropBits(stateData, 8)
+	red := readBits(stateData, 8)
+	dropBits(stateData, 8)
+
+	redChannel := plainColorChannel(red, 5)
+	greenChannel := plainColorChannel(green, 6)
+	blueChannel := plainColorChannel(blue, 5)
+
+	color1 := redChannel.value1 | (greenChannel.value1|blueChannel.value1<<6)<<5
+	color2 := redChannel.value2 | (greenChannel.value2|blueChannel.value2<<6)<<5
+
+	// Average position of the wanted color between the two endpoints
+	weight, channels := uint32(0), uint32(0)
+	for _, channel := range []colorChannel{redChannel, greenChannel, blueChannel} {
+		if channel.value1 == channel.value2 {
+			continue
+		}
+		if channel.value1 == channel.base {
+			weight += channel.remainder
+		} else {
+			weight += 12 - channel.remainder
+		}
+		channels++
+	}
+	if channels > 0 {
+		weight = (weight + channels/2) / channels
+	}
+
+	dxt1SpecialCase := layout.flags&textureFlagDeducedAlphaComp != 0 && (weight == 5 || weight == 6 || channels != 0)
+
+	if channels > 0 && !dxt1SpecialCase {
+		if color2 == 0xFFFF {
+			weight = 12
+			color1--
+		} else {
+			weight = 0
+			color2++
+		}
+	}
+
+	if color2 >= color1 {
+		color1, color2 = color2, color1
+		weight = 12 - weight
+	}
+
+	var colorChosen uint64
+	switch {
+	case dxt1SpecialCase:
+		colorChosen = 2
+	case weight < 2:
+		colorChosen = 0
+	case weight < 6:
+		colorChosen = 2
+	case weight < 10:
+		colorChosen = 3
+	default:
+		colorChosen = 1
+	}
+
+	indices := colorChosen | colorChosen<<2 | (colorChosen|colorChosen<<2)<<4
+	indices |= indices << 8
+	indices |= indices << 16
+	finalValue := uint64(color1) | uint64(color2)<<16 | indices<<32
+
+	return fillTextureBlocks(stateData, layout, colorBitmap, func() bool { return readFlagBit(stateData) }, func(block uint32) {
+		binary.LittleEndian.PutUint64(outputBuffer[layout.bytesPerBlock*block+layout.colorOffset:], finalValue)
+		colorBitmap[block] = true
+	})
+}
+
+// inflateTextureData decodes the constant fill passes and then copies the
+// remaining blocks verbatim from the input words.
+func inflateTextureData(stateData *State, layout textureLayout, outputBuffer []uint8) error {
+	alphaBitmap := make([]bool, layout.numBlocks)
+	colorBitmap := make([]bool, layout.numBlocks)
+
+	// Data size, unused
+	needBits(stateData, 32)
+	dropBits(stateData, 32)
+
+	needBits(stateData, 32)
+	compressionFlags := readBits(stateData, 32)
+	dropBits(stateData, 32)
+
+	if compressionFlags&textureDecodeWhiteColor != 0 {
+		if err := decodeWhiteColor(stateData, layout, alphaBitmap, colorBitmap, outputBuffer); err != nil {
+			return err
+		}
+	}
+	if compressionFlags&textureDecodeConstantAlphaFrom4Bits != 0 {
+		if err := decodeConstantAlphaFrom4Bits(stateData, layout, alphaBitmap, outputBuffer); err != nil {
+			return err
+		}
+	}
+	if compressionFlags&textureDecodeConstantAlphaFrom8Bits != 0 {
+		if err := decodeConstantAlphaFrom8Bits(stateData, layout, alphaBitmap, outputBuffer); err != nil {
+			return err
+		}
+	}
+	if compressionFlags&textureDecodePlainColor != 0 {
+		if err := decodePlainColor(stateData, layout, colorBitmap, outputBuffer); err != nil {
+			return err
+		}
+	}
+
+	if stateData.Err != nil {
+		return stateData.Err
+	}
+
+	// The rest is word aligned; give back a word the bit reader fetched early
+	if stateData.Bits >= 32 {
+		stateData.InputPosition--
+	}
+
+	copyWord := func(offset uint32) bool {
+		if stateData.InputPosition >= stateData.InputSize {
+			return false
+		}
+		binary.LittleEndian.PutUint32(outputBuffer[offset:], stateData.InputData[stateData.InputPosition])
+		stateData.InputPosition++
+		return true
+	}
+
+	if layout.flags&textureFlagAlpha != 0 && layout.flags&textureFlagDeducedAlphaComp == 0 {
+		for block := uint32(0); block < layout.numBlocks; block++ {
+			if alphaBitmap[block] {
+				continue
+			}
+			if !copyWord(layout.bytesPerBlock * block) {
+				return nil
+			}
+			if layout.bytesPerComponent > 4 && !copyWord(layout.bytesPerBlock*block+4) {
+				return nil
+			}
+		}
+	}
+
+	if layout.flags&(textureFlagColor|textureFlagBicolorComp) != 0 {
+		for _, wordOffset := range []uint32{0, 4} {
+			for block := uint32(0); block < layout.numBlocks; block++ {
+				if !colorBitmap[block] && !copyWord(layout.bytesPerBlock*block+layout.colorOffset+wordOffset) {
+					return nil
+				}
+			}
+		}
+
+		if layout.flags&textureFlagBicolorComp != 0 {
+			for _, wordOffset := range []uint32{0, 4} {
+				for block := uint32(0); block < layout.numBlocks; block++ {
+					if !copyWord(layout.bytesPerBlock*block + wordOffset) {
+						return nil
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// inflateTextureBuffer decodes an ATEX entry, header included, whose payload
+// uses the texture codec, into the raw DXT/BC blocks of its top mip level.
+func inflateTextureBuffer(inputBuffer []uint8, width, height uint16) ([]byte, error) {
+	tex, err := ParseATEX(inputBuffer)
+	if err != nil {
+		return nil, err
+	}
+	format, ok := textureFormats[tex.Format]
+	if !ok {
+		return nil, fmt.Errorf("unsupported texture format %q", tex.Format)
+	}
+
+	u32InputBuffer := convertU8ToU32(inputBuffer)
+
+	stateData, err := newState(u32InputBuffer)
+	if err != nil {
+		return nil, err
+	}
+
+	// Skipping the fourCC, format and size words of the ATEX header
+	for i := 0; i < atexHeaderSize/4; i++ {
+		needBits(stateData, 32)
+		dropBits(stateData, 32)
+	}
+
+	layout := newTextureLayout(format, width, height)
+	outputBuffer := make([]uint8, layout.bytesPerBlock*layout.numBlocks)
+	if err := inflateTextureData(stateData, layout, outputBuffer); err != nil {
+		return nil, fmt.Errorf("texture decompression failed: %w", err)
+	}
+
+	return outputBuffer, nil
+}
@@ -0,0 +1,146 @@
+package dat
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// testTexture returns an ATEX entry whose payload uses the texture codec:
+// the size and compression flag words, the constant fill passes written by
+// passes, then the verbatim words.
+func testTexture(format string, width, height uint16, flags uint32, passes func(w *bitWriter), words ...uint32) []byte {
+	w := &bitWriter{}
+	w.writeBits(0, 32) // Data size, unused
+	w.writeBits(flags, 32)
+	if passes != nil {
+		passes(w)
+		if w.count > 0 {
+			w.writeBits(0, 32-w.count)
+		}
+	}
+	for _, word := range words {
+		w.writeBits(word, 32)
+	}
+
+	data := append([]byte("ATEX"), format...)
+	data = binary.LittleEndian.AppendUint16(data, width)
+	data = binary.LittleEndian.AppendUint16(data, height)
+	return append(data, w.bytes()...)
+}
+
+// writeTextureRun writes a run of count blocks and its fill flag.
+func writeTextureRun(w *bitWriter, count uint16, fill bool) {
+	code := huffmanCodes(textureHuffmanTreeDict())[count]
+	w.writeBits(code.code, code.bits)
+	flag := uint32(0)
+	if fill {
+		flag = 1
+	}
+	w.writeBits(flag, 1)
+}
+
+func TestInflateTextureBuffer(t *testing.T) {
+	white := binary.LittleEndian.AppendUint64(nil, 0xFFFFFFFFFFFFFFFE)
+
+	tests := []struct {
+		name          string
+		data          []byte
+		width, height uint16
+		want          []byte
+	}{
+		{
+			"DXT1 verbatim",
+			testTexture("DXT1", 4, 4, 0, nil, 0x11111111, 0x22222222),
+			4, 4,
+			binary.LittleEndian.AppendUint32(binary.LittleEndian.AppendUint32(nil, 0x11111111), 0x22222222),
+		},
+		{
+			// Color words come first for all blocks, then the second words
+			"DXT1 two blocks verbatim",
+			testTexture("DXT1", 8, 4, 0, nil, 1, 2, 3, 4),
+			8, 4,
+			[]byte{1, 0, 0, 0, 3, 0, 0, 0, 2, 0, 0, 0, 4, 0, 0, 0},
+		},
+		{
+			"DXT1 white block",
+			testTexture("DXT1", 8, 4, textureDecodeWhiteColor, func(w *bitWriter) {
+				writeTextureRun(w, 1, true)
+				writeTextureRun(w, 1, false)
+			}, 5, 6),
+			8, 4,
+			append(bytes.Clone(white), 5, 0, 0, 0, 6, 0, 0, 0),
+		},
+		{
+			"DXT1 all white",
+			testTexture("DXT1", 8, 4, textureDecodeWhiteColor, func(w *bitWriter) {
+				writeTextureRun(w, 2, true)
+			}),
+			8, 4,
+			append(bytes.Clone(white), white...),
+		},
+		{
+			// The alpha words of every block precede the color words
+			"DXT5 verbatim",
+			testTexture("DXT5", 4, 4, 0, nil, 1, 2, 3, 4),
+			4, 4,
+			[]byte{1, 0, 0, 0, 2, 0, 0, 0, 3, 0, 0, 0, 4, 0, 0, 0},
+		},
+		{
+			// Bicolor blocks store the second component after the first
+			"3DCX verbatim",
+			testTexture("3DCX", 4, 4, 0, nil, 1, 2, 3, 4),
+			4, 4,
+			[]byte{3, 0, 0, 0, 4, 0, 0, 0, 1, 0, 0, 0, 2, 0, 0, 0},
+		},
+	}
+	for _, test := range tests {
+		got, err := inflateTextureBuffer(test.data, test.width, test.height)
+		if err != nil {
+			t.Errorf("%s: inflateTextureBuffer: %v", test.name, err)
+			continue
+		}
+		if !bytes.Equal(got, test.want) {
+			t.Errorf("%s: inflateTextureBuffer = %x, want %x", test.name, got, test.want)
+		}
+	}
+}
+
+func TestInflateTextureBufferRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"not a texture", []byte("strs\x00\x00\x00\x00\x00\x00\x00\x00")},
+		{"unknown pixel format", testTexture("ABCD", 4, 4, 0, nil, 1, 2)},
+		{"fill pass past the input", []byte("ATEXDXT1\x04\x00\x04\x00\x00\x00\x00\x00\x01\x00\x00\x00")},
+	}
+	for _, test := range tests {
+		if _, err := inflateTextureBuffer(test.data, 4, 4); err == nil {
+			t.Errorf("%s: inflateTextureBuffer succeeded", test.name)
+		}
+	}
+}
+
+func TestExtractTextureDDS(t *testing.T) {
+	texture := testTexture("DXT1", 4, 4, 0, nil, 0x11111111, 0x22222222)
+	datFile := testDat{entries: []testEntry{
+		{data: texture, compressed: true},
+		{data: []byte("strs\x00\x00"), compressed: true},
+	}}.open(t, Options{})
+
+	dds, err := datFile.ExtractTextureDDS(firstTestBaseID)
+	if err != nil {
+		t.Fatalf("ExtractTextureDDS: %v", err)
+	}
+	if string(dds[:4]) != ddsMagic || len(dds) != 4+ddsHeaderSize+8 {
+		t.Fatalf("ExtractTextureDDS returned %d bytes, want a DDS header and one DXT1 block", len(dds))
+	}
+	if block := dds[4+ddsHeaderSize:]; binary.LittleEndian.Uint32(block) != 0x11111111 || binary.LittleEndian.Uint32(block[4:]) != 0x22222222 {
+		t.Errorf("ExtractTextureDDS block = %x", block)
+	}
+
+	if _, err := datFile.ExtractTextureDDS(firstTestBaseID + 1); err == nil {
+		t.Error("ExtractTextureDDS of a string file succeeded")
+	}
+}
@@ -0,0 +1,153 @@
+package dat
+
+import (
+	"context"
+	"fmt"
+)
+
+// VerifyOptions selects the checks Verify runs on each entry. Every entry is
+// read and, when compressed, its stream header is checked for a plausible
+// decompressed size.
+type VerifyOptions struct {
+	// CheckCRC checks the block checksums of each compressed entry, see
+	// VerifyEntry.
+	CheckCRC bool
+
+	// Decompress fully decodes compressed entries, discarding the output,
+	// instead of only reading their stream header. This is much slower but
+	// catches corrupt streams.
+	Decompress bool
+}
+
+// VerifyFailure is an entry that failed verification.
+type VerifyFailure struct {
+	Index int // 0-based index into MFTData
+	Err   error
+}
+
+// VerifyReport is the outcome of Verify.
+type VerifyReport struct {
+	Checked  int // Entries read, excluding empty rows
+	Skipped  int // Empty rows, which hold no data to check
+	Failures []VerifyFailure
+}
+
+// OK reports whether every checked entry passed.
+func (report *VerifyReport) OK() bool {
+	return len(report.Failures) == 0
+}
+
+// Verify checks every MFT row without stopping at the first failure and
+// returns a report listing the entries that failed and why. The error is
+// only set when the dat cannot be read at all.
+func (datFile *DatFile) Verify(opts VerifyOptions) (*VerifyReport, error) {
+	if datFile.reader == nil && datFile.mapping == nil {
+		return nil, fmt.Errorf("dat file is closed")
+	}
+	if err := prepareHuffmanTreeDict(); err != nil {
+		return nil, err
+	}
+
+	report := &VerifyReport{}
+	for index, mftEntry := range datFile.MFTData {
+		if mftEntry.IsEmpty() {
+			report.Skipped++
+			continue
+		}
+		report.Checked++
+
+		if err := datFile.verifyEntry(index, mftEntry, opts); err != nil {
+			datFile.debug("Entry failed verification", "index", index, "error", err)
+			report.Failures = append(report.Failures, VerifyFailure{Index: index, Err: err})
+		}
+	}
+	return report, nil
+}
+
+// verifyEntry runs the checks selected by opts on one MFT row.
+func (datFile *DatFile) verifyEntry(index int, mftEntry MFTEntry, opts VerifyOptions) error {
+	buffer, err := datFile.readRaw(index)
+	if err != nil {
+		return err
+	}
+
+	if opts.CheckCRC && crcApplies(mftEntry) {
+		if err := checkEntryCRC(index, buffer, datFile.streamBlockSize()); err != nil {
+			return err
+		}
+	}
+
+	// Without the key the contents of an encrypted entry cannot be checked
+	if !mftEntry.IsCompressed() || mftEntry.IsEncrypted() {
+		return nil
+	}
+	if r, ok := openStandardStream(buffer); ok {
+		defer r.Close()
+		if opts.Decompress {
+			if _, err := inflateStandard(r, 0, datFile.maxSize); err != nil {
+				return fmt.Errorf("decompression failed: %w", err)
+			}
+		}
+		return nil
+	}
+
+	size, err := DecompressedSize(buffer)
+	if err != nil {
+		return err
+	}
+	if err := checkDecompressedSize(size, datFile.maxSize); err != nil {
+		return err
+	}
+
+	if opts.Decompress {
+		if err := probeInflate(buffer, datFile.streamBlockSize()); err != nil {
+			return fmt.Errorf("decompression failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// ExtractVerified is Extract for dats from untrusted sources. Before anything
+// is decompressed it checks that the entry lies within the file, that its
+// block checksums match and, for GW2-compressed entries, that the
+// decompressed size in the stream header is within
+// Options.MaxDecompressedSize. The
+// first failed check is returned, naming the entry. Block checksums are
+// checked whatever Options.VerifyCRC says, on the entries VerifyEntry checks. The
+// cache is bypassed, so the data returned is always freshly checked.
+func (datFile *DatFile) ExtractVerified(number uint32, isFileID bool) ([]byte, error) {
+	index, err := datFile.resolveIndex(number, isFileID)
+	if err != nil {
+		return nil, err
+	}
+	mftEntry := datFile.MFTData[index]
+
+	// readRaw checks the bounds before reading anything
+	buffer, err := datFile.readRaw(index)
+	if err != nil {
+		return nil, err
+	}
+	if crcApplies(mftEntry) {
+		if err := checkEntryCRC(index, buffer, datFile.streamBlockSize()); err != nil {
+			return nil, err
+		}
+	}
+	if mftEntry.IsEncrypted() {
+		return nil, fmt.Errorf("MFT entry %d: %w", index, ErrEncryptedEntry)
+	}
+
+	if mftEntry.IsCompressed() && !mftEntry.IsEmpty() {
+		if r, ok := openStandardStream(buffer); ok {
+			// A gzip or zlib stream records no size; inflateStandard caps it
+			r.Close()
+		} else if size, err := DecompressedSize(buffer); err != nil {
+			return nil, fmt.Errorf("MFT entry %d: %w", index, err)
+		} else if err := checkDecompressedSize(size, datFile.maxSize); err != nil {
+			return nil, fmt.Errorf("MFT entry %d: %w", index, err)
+		}
+	}
+
+	return datFile.withEntryTimeout(context.Background(), index, func(ctx context.Context) ([]byte, error) {
+		return datFile.decodeEntry(ctx, nil, index, buffer, 0)
+	})
+}
@@ -0,0 +1,181 @@
+package dat
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// verifyEntries are entries each failing a different check of Verify, with
+// base IDs 4 to 10.
+func verifyEntries(t *testing.T) []testEntry {
+	t.Helper()
+	truncated := testDeflate(t, testPayload(200000))
+	truncated = truncated[:len(truncated)/2]
+	bomb := testDeflate(t, testPayload(100))
+	binary.LittleEndian.PutUint32(bomb[4:], math.MaxUint32)
+	badChecksum := testDeflate(t, testPayload(400000))
+	badChecksum[4*BlockSize-1] ^= 0x01 // Skipped by the decoder
+
+	return []testEntry{
+		{data: []byte("stored entry")},
+		{data: testPayload(3000), compressed: true},
+		{data: truncated, deflated: true},
+		{data: bomb, deflated: true},
+		{data: []byte{}},
+		{data: badChecksum, deflated: true},
+		{data: []byte("encrypted"), compressed: true, flag: EntryFlagEncrypted},
+	}
+}
+
+func TestVerify(t *testing.T) {
+	datFile := testDat{entries: verifyEntries(t)}.open(t, Options{})
+	first := firstTestBaseID - 1
+	truncated, bomb, badChecksum := first+2, first+3, first+5
+
+	tests := []struct {
+		name string
+		opts VerifyOptions
+		want []int // Indices of the failing entries
+	}{
+		{"headers only", VerifyOptions{}, []int{bomb}},
+		{"block checksums", VerifyOptions{CheckCRC: true}, []int{bomb, badChecksum}},
+		{"decompress", VerifyOptions{Decompress: true}, []int{truncated, bomb}},
+		{"all", VerifyOptions{CheckCRC: true, Decompress: true}, []int{truncated, bomb, badChecksum}},
+	}
+	for _, test := range tests {
+		report, err := datFile.Verify(test.opts)
+		if err != nil {
+			t.Fatalf("%s: Verify: %v", test.name, err)
+		}
+
+		var failed []int
+		for _, failure := range report.Failures {
+			if failure.Err == nil {
+				t.Errorf("%s: entry %d failed without an error", test.name, failure.Index)
+			}
+			failed = append(failed, failure.Index)
+		}
+		if !slices.Equal(failed, test.want) {
+			t.Errorf("%s: Verify failed entries %v, want %v", test.name, failed, test.want)
+		}
+		if report.OK() != (len(test.want) == 0) {
+			t.Errorf("%s: OK() = %v", test.name, report.OK())
+		}
+
+		empty := 0
+		for _, mftEntry := range datFile.MFTData {
+			if mftEntry.IsEmpty() {
+				empty++
+			}
+		}
+		if report.Skipped != empty || report.Checked != len(datFile.MFTData)-empty {
+			t.Errorf("%s: checked %d and skipped %d, want %d and %d", test.name, report.Checked, report.Skipped, len(datFile.MFTData)-empty, empty)
+		}
+	}
+}
+
+func TestVerifyDamagedDat(t *testing.T) {
+	spec := testDat{entries: testEntries}
+	data := spec.build(t)
+	datFile, err := OpenReaderAt(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An entry reaching past the end of the file fails the read
+	datFile.MFTData[firstTestBaseID].Size = uint32(len(data))
+	report, err := datFile.Verify(VerifyOptions{})
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if len(report.Failures) != 1 || report.Failures[0].Index != firstTestBaseID {
+		t.Errorf("Verify failures = %v, want only entry %d", report.Failures, firstTestBaseID)
+	}
+
+	closed := spec.open(t, Options{})
+	closed.Close()
+	if _, err := closed.Verify(VerifyOptions{}); err == nil {
+		t.Error("Verify of a closed dat succeeded")
+	}
+}
+
+func TestExtractVerified(t *testing.T) {
+	entries := verifyEntries(t)
+	datFile := testDat{entries: entries}.open(t, Options{CacheSize: 1 << 20})
+	first := firstTestBaseID - 1
+
+	tests := []struct {
+		name    string
+		index   int
+		want    []byte // Returned data, when it succeeds
+		failure string // Part of the error message, when it fails
+		is      error  // Error wrapped, if any
+		check   bool   // A failed check, whose error names the entry
+	}{
+		{"stored", first, entries[0].data, "", nil, false},
+		{"compressed", first + 1, entries[1].data, "", nil, false},
+		{"truncated stream", first + 2, nil, "decompression failed", ErrCorruptStream, false},
+		{"decompression bomb", first + 3, nil, "byte limit", nil, true},
+		{"empty", first + 4, []byte{}, "", nil, false},
+		{"bad checksum", first + 5, nil, "CRC mismatch in block 0", nil, true},
+		{"encrypted", first + 6, nil, "MFT entry", ErrEncryptedEntry, true},
+	}
+	for _, test := range tests {
+		got, err := datFile.ExtractVerified(uint32(test.index+1), false)
+		if test.failure == "" {
+			if err != nil || !bytes.Equal(got, test.want) {
+				t.Errorf("%s: ExtractVerified = %d bytes, %v", test.name, len(got), err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.failure) || (test.is != nil && !errors.Is(err, test.is)) {
+			t.Errorf("%s: ExtractVerified returned %v, want an error containing %q", test.name, err, test.failure)
+			continue
+		}
+		if test.check && !strings.Contains(err.Error(), fmt.Sprintf("MFT entry %d", test.index)) {
+			t.Errorf("%s: error %q does not name MFT entry %d", test.name, err, test.index)
+		}
+	}
+
+	// Extract skips block checksums, ExtractVerified does not
+	if _, err := datFile.ExtractByBaseID(uint32(first + 6)); err != nil {
+		t.Errorf("ExtractByBaseID of the entry with a bad checksum: %v", err)
+	}
+
+	// The cache is bypassed
+	datFile.cache.put(first+1, []byte("cached"))
+	if got, err := datFile.ExtractVerified(uint32(first+2), false); err != nil || !bytes.Equal(got, entries[1].data) {
+		t.Errorf("ExtractVerified of a cached entry = %q, %v, want the data in the dat", got, err)
+	}
+
+	if _, err := datFile.ExtractVerified(999, false); !errors.Is(err, ErrEntryNotFound) {
+		t.Errorf("ExtractVerified of an unknown base ID returned %v, want ErrEntryNotFound", err)
+	}
+
+	// Options.MaxDecompressedSize applies
+	small := testDat{entries: entries}.open(t, Options{MaxDecompressedSize: 1000})
+	if _, err := small.ExtractVerified(uint32(first+2), false); err == nil || !strings.Contains(err.Error(), "byte limit") {
+		t.Errorf("ExtractVerified past MaxDecompressedSize returned %v", err)
+	}
+}
+
+func TestExtractVerifiedPastEnd(t *testing.T) {
+	data := testDat{entries: testEntries}.build(t)
+	mftOffset := bytes.Index(data, []byte("Mft\x1A"))
+	row := mftOffset + binary.Size(MFTHeader{}) + (firstTestBaseID-1)*binary.Size(MFTEntry{})
+	binary.LittleEndian.PutUint32(data[row+8:], uint32(len(data)))
+
+	datFile, err := OpenReaderAt(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := datFile.ExtractVerified(firstTestBaseID, false); err == nil || !strings.Contains(err.Error(), "past the end") {
+		t.Errorf("ExtractVerified of an entry past the end returned %v", err)
+	}
+}
@@ -0,0 +1,169 @@
+package dat
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// replacement is new data for an MFT row, set by ReplaceEntry.
+type replacement struct {
+	data            []byte // Bytes as they will be stored in the dat
+	compressionFlag uint16
+}
+
+// ReplaceEntry sets new contents for the MFT row at the 0-based index, to be
+// written by WriteTo. The DatFile itself keeps returning the original data.
+// When compress is set the data is stored compressed with Deflate, in blocks
+// of the size the dat is read with, otherwise as is. The reserved header, index and MFT rows cannot be replaced.
+func (datFile *DatFile) ReplaceEntry(index int, data []byte, compress bool) error {
+	if index < 0 || index >= len(datFile.MFTData) {
+		return fmt.Errorf("MFT index %d out of range [0, %d): %w", index, len(datFile.MFTData), ErrEntryNotFound)
+	}
+	if index <= MftEntryMftNum {
+		return fmt.Errorf("MFT entry %d is reserved and cannot be replaced", index)
+	}
+
+	entry := replacement{data: data, compressionFlag: CompressionNone}
+	if compress {
+		compressed, err := deflate(data, datFile.streamBlockSize())
+		if err != nil {
+			return fmt.Errorf("compressing MFT entry %d: %w", index, err)
+		}
+		entry = replacement{data: compressed, compressionFlag: CompressionGW2}
+	}
+	if uint64(len(entry.data)) > math.MaxUint32 {
+		return fmt.Errorf("MFT entry %d: %d bytes do not fit the 32-bit size of an MFT row", index, len(entry.data))
+	}
+
+	if datFile.replacements == nil {
+		datFile.replacements = make(map[int]replacement)
+	}
+	datFile.replacements[index] = entry
+	return nil
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// WriteTo writes a new dat to w holding every entry, with the replacements
+// made by ReplaceEntry, followed by the index table and a rebuilt MFT. Entries
+// are laid out back to back in MFT order, so space left by deleted or
+// shrunken entries is not carried over. Rows marked deleted keep their place
+// in the MFT, still marked deleted, but no data, unless they were replaced.
+// The header is the original one with the MFT location updated and
+// HeaderFlagNarrowOffsets cleared, as the MFT is always written with 64-bit
+// offsets; its other fields, CRC included, and any bytes past them are copied
+// unchanged. It returns the number of bytes written.
+func (datFile *DatFile) WriteTo(w io.Writer) (int64, error) {
+	if datFile.reader == nil {
+		return 0, fmt.Errorf("dat file is closed")
+	}
+
+	// Lay out the new file: header, entries, index table, MFT
+	rows := make([]MFTEntry, len(datFile.MFTData))
+	copy(rows, datFile.MFTData)
+	offset := uint64(datFile.Header.HeaderSize)
+	for index := range rows {
+		if index <= MftEntryMftNum {
+			continue
+		}
+		if r, ok := datFile.replacements[index]; ok {
+			rows[index].Size = uint32(len(r.data))
+			rows[index].CompressionFlag = r.compressionFlag
+			rows[index].CRC = 0 // The MFT CRC algorithm is unknown, see crcTable
+		} else if rows[index].IsDeleted() {
+			rows[index].Offset, rows[index].Size = math.MaxUint64, 0
+			continue
+		}
+		if rows[index].Size == 0 {
+			rows[index].Offset = 0
+			continue
+		}
+		rows[index].Offset = offset
+		offset += uint64(rows[index].Size)
+	}
+
+	indexSize := len(datFile.MFTIndexData) * binary.Size(MFTIndexData{})
+	rows[MftEntryHeaderNum].Offset, rows[MftEntryHeaderNum].Size = 0, datFile.Header.HeaderSize
+	rows[MftEntryIndexNum].Offset, rows[MftEntryIndexNum].Size = offset, uint32(indexSize)
+	offset += uint64(indexSize)
+
+	mftSize := binary.Size(MFTHeader{}) + len(rows)*binary.Size(MFTEntry{})
+	if uint64(indexSize) > math.MaxUint32 || uint64(mftSize) > math.MaxUint32 {
+		return 0, fmt.Errorf("index table of %d bytes or MFT of %d bytes does not fit a 32-bit size", indexSize, mftSize)
+	}
+	rows[MftEntryMftNum].Offset, rows[MftEntryMftNum].Size = offset, uint32(mftSize)
+
+	cw := &countingWriter{w: w}
+	if err := datFile.writeHeader(cw, offset, uint32(mftSize)); err != nil {
+		return cw.n, err
+	}
+
+	for index := range rows {
+		if index <= MftEntryMftNum || rows[index].Size == 0 {
+			continue
+		}
+		data, err := datFile.replacementOrRaw(index)
+		if err != nil {
+			return cw.n, fmt.Errorf("MFT entry %d: %w", index, err)
+		}
+		if _, err := cw.Write(data); err != nil {
+			return cw.n, err
+		}
+	}
+
+	if err := binary.Write(cw, binary.LittleEndian, datFile.MFTIndexData); err != nil {
+		return cw.n, fmt.Errorf("writing MFT index data: %w", err)
+	}
+
+	mftHeader := datFile.MFTHeader
+	mftHeader.NumEntries = uint32(len(rows))
+	if err := binary.Write(cw, binary.LittleEndian, mftHeader); err != nil {
+		return cw.n, fmt.Errorf("writing MFT header: %w", err)
+	}
+	if err := binary.Write(cw, binary.LittleEndian, rows); err != nil {
+		return cw.n, fmt.Errorf("writing MFT data: %w", err)
+	}
+	return cw.n, nil
+}
+
+// writeHeader writes the original header region with the MFT location
+// updated. Bytes past the fixed Header fields are copied unchanged.
+func (datFile *DatFile) writeHeader(w io.Writer, mftOffset uint64, mftSize uint32) error {
+	headerBytes := make([]byte, datFile.Header.HeaderSize)
+	if _, err := io.ReadFull(io.NewSectionReader(datFile.reader, 0, int64(len(headerBytes))), headerBytes); err != nil {
+		return fmt.Errorf("failed to read dat header: %w", err)
+	}
+
+	// The MFT is always written with 64-bit offsets
+	header := datFile.Header
+	header.MftOffset, header.MftSize = mftOffset, mftSize
+	header.Flags &^= HeaderFlagNarrowOffsets
+	if _, err := binary.Encode(headerBytes, binary.LittleEndian, header); err != nil {
+		return fmt.Errorf("encoding dat header: %w", err)
+	}
+
+	if _, err := w.Write(headerBytes); err != nil {
+		return err
+	}
+	return nil
+}
+
+// replacementOrRaw returns the bytes WriteTo stores for the MFT row at index.
+func (datFile *DatFile) replacementOrRaw(index int) ([]byte, error) {
+	if r, ok := datFile.replacements[index]; ok {
+		return r.data, nil
+	}
+	return datFile.readRaw(index)
+}
@@ -0,0 +1,142 @@
+package dat
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// writeEntries are the entries written by TestWriteTo, with base IDs 4 to 8.
+// The fourth one is deleted.
+var writeEntries = []testEntry{
+	{data: []byte("stored entry")},
+	{data: testPayload(3000), compressed: true},
+	{data: testPayload(200000), compressed: true},
+	{data: []byte("deleted entry"), deleted: true},
+	{data: []byte{}},
+}
+
+func TestWriteTo(t *testing.T) {
+	first := firstTestBaseID - 1 // MFT index of the first entry
+	deleted := first + 3
+
+	tests := []struct {
+		name         string
+		narrow       bool
+		replacements map[int]testEntry // By MFT index, compressed means ReplaceEntry compresses
+	}{
+		{"unchanged", false, nil},
+		{"unchanged narrow", true, nil},
+		{"replaced", false, map[int]testEntry{
+			first:     {data: testPayload(5000), compressed: true},
+			first + 2: {data: []byte("now stored")},
+			first + 4: {data: []byte("no longer empty")},
+		}},
+		{"deleted replaced", true, map[int]testEntry{
+			deleted: {data: []byte("restored"), compressed: true},
+		}},
+	}
+	for _, test := range tests {
+		spec := testDat{
+			entries: writeEntries,
+			fileIDs: [][2]uint32{{100, firstTestBaseID}, {101, firstTestBaseID + 2}},
+			narrow:  test.narrow,
+		}
+		datFile := spec.open(t, Options{})
+		for index, entry := range test.replacements {
+			if err := datFile.ReplaceEntry(index, entry.data, entry.compressed); err != nil {
+				t.Fatalf("%s: ReplaceEntry(%d): %v", test.name, index, err)
+			}
+		}
+
+		var out bytes.Buffer
+		n, err := datFile.WriteTo(&out)
+		if err != nil {
+			t.Fatalf("%s: WriteTo: %v", test.name, err)
+		}
+		if n != int64(out.Len()) {
+			t.Errorf("%s: WriteTo returned %d, wrote %d bytes", test.name, n, out.Len())
+		}
+
+		written, err := OpenReaderAt(bytes.NewReader(out.Bytes()), int64(out.Len()))
+		if err != nil {
+			t.Fatalf("%s: OpenReaderAt of the written dat: %v", test.name, err)
+		}
+		// Only the MFT location and the narrow offsets flag change
+		header := datFile.Header
+		header.MftOffset, header.MftSize = written.Header.MftOffset, written.Header.MftSize
+		header.Flags &^= HeaderFlagNarrowOffsets
+		if written.Header != header {
+			t.Errorf("%s: written header %+v, want %+v", test.name, written.Header, header)
+		}
+		if !slices.Equal(written.MFTIndexData, datFile.MFTIndexData) {
+			t.Errorf("%s: index table %v, want %v", test.name, written.MFTIndexData, datFile.MFTIndexData)
+		}
+		if len(written.MFTData) != len(datFile.MFTData) {
+			t.Fatalf("%s: %d MFT rows, want %d", test.name, len(written.MFTData), len(datFile.MFTData))
+		}
+
+		for i, entry := range writeEntries {
+			index := first + i
+			if r, ok := test.replacements[index]; ok {
+				entry = r
+			} else if entry.deleted {
+				if row := written.MFTData[index]; !row.IsDeleted() || row.Size != 0 {
+					t.Errorf("%s: deleted row written as %+v", test.name, row)
+				}
+				continue
+			}
+			got, err := written.ExtractByBaseID(uint32(index + 1))
+			if err != nil {
+				t.Errorf("%s: ExtractByBaseID(%d): %v", test.name, index+1, err)
+				continue
+			}
+			if !bytes.Equal(got, entry.data) {
+				t.Errorf("%s: entry %d has %d bytes not matching its data", test.name, index, len(got))
+			}
+			if written.MFTData[index].IsCompressed() != (entry.compressed && len(entry.data) > 0) {
+				t.Errorf("%s: entry %d has compression flag %d", test.name, index, written.MFTData[index].CompressionFlag)
+			}
+		}
+
+		// The source dat is left alone
+		if got, err := datFile.ExtractByBaseID(firstTestBaseID); err != nil || !bytes.Equal(got, writeEntries[0].data) {
+			t.Errorf("%s: source ExtractByBaseID after WriteTo = %q, %v", test.name, got, err)
+		}
+	}
+}
+
+func TestWriteToClosed(t *testing.T) {
+	datFile := testDat{entries: testEntries}.open(t, Options{})
+	datFile.Close()
+	if _, err := datFile.WriteTo(&bytes.Buffer{}); err == nil {
+		t.Error("WriteTo of a closed dat succeeded")
+	}
+}
+
+func TestReplaceEntryRejects(t *testing.T) {
+	datFile := testDat{entries: testEntries}.open(t, Options{})
+
+	tests := []struct {
+		name     string
+		index    int
+		notFound bool
+	}{
+		{"header row", MftEntryHeaderNum, false},
+		{"index row", MftEntryIndexNum, false},
+		{"MFT row", MftEntryMftNum, false},
+		{"negative", -1, true},
+		{"past the end", len(datFile.MFTData), true},
+	}
+	for _, test := range tests {
+		err := datFile.ReplaceEntry(test.index, []byte("data"), false)
+		if err == nil {
+			t.Errorf("%s: ReplaceEntry(%d) succeeded", test.name, test.index)
+			continue
+		}
+		if errors.Is(err, ErrEntryNotFound) != test.notFound {
+			t.Errorf("%s: ReplaceEntry(%d) returned %v, ErrEntryNotFound expected %v", test.name, test.index, err, test.notFound)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// ledgerName is the file unpack records finished entries in, inside --out.
+const ledgerName = ".skritto-unpack"
+
+// ledgerFlushEvery is how many recorded entries are buffered before the
+// ledger is flushed to disk.
+const ledgerFlushEvery = 64
+
+// unpackLedger is an append-only list of the MFT indices unpack has written,
+// one decimal index per line, so an interrupted run can be resumed.
+type unpackLedger struct {
+	mu      sync.Mutex
+	file    *os.File
+	w       *bufio.Writer
+	pending int
+}
+
+// openLedger opens the ledger in dir. With resume it returns the indices
+// already recorded and appends to the ledger; otherwise the ledger is started
+// afresh.
+func openLedger(dir string, resume bool) (*unpackLedger, map[int]bool, error) {
+	path := filepath.Join(dir, ledgerName)
+	done := make(map[int]bool)
+	if !resume {
+		file, err := os.Create(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("creating ledger: %w", err)
+		}
+		return &unpackLedger{file: file, w: bufio.NewWriter(file)}, done, nil
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, fmt.Errorf("reading ledger: %w", err)
+	}
+
+	// A run killed mid-write can leave a partial last line; drop it so the
+	// next index is not appended onto it
+	contents = contents[:bytes.LastIndexByte(contents, '\n')+1]
+	for _, line := range bytes.Split(contents, []byte("\n")) {
+		if index, err := strconv.Atoi(string(line)); err == nil {
+			done[index] = true
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("opening ledger: %w", err)
+	}
+	if err := file.Truncate(int64(len(contents))); err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("truncating ledger: %w", err)
+	}
+	if _, err := file.Seek(0, io.SeekEnd); err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("seeking ledger: %w", err)
+	}
+	return &unpackLedger{file: file, w: bufio.NewWriter(file)}, done, nil
+}
+
+// record adds index to the ledger, flushing every ledgerFlushEvery entries.
+func (ledger *unpackLedger) record(index int) error {
+	ledger.mu.Lock()
+	defer ledger.mu.Unlock()
+
+	if _, err := fmt.Fprintln(ledger.w, index); err != nil {
+		return err
+	}
+	ledger.pending++
+	if ledger.pending < ledgerFlushEvery {
+		return nil
+	}
+	ledger.pending = 0
+	return ledger.w.Flush()
+}
+
+// Close flushes the ledger and closes its file.
+func (ledger *unpackLedger) Close() error {
+	ledger.mu.Lock()
+	defer ledger.mu.Unlock()
+
+	err := ledger.w.Flush()
+	if closeErr := ledger.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
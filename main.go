@@ -1,50 +1,469 @@
 package main
 
 import (
+	"context"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"image"
+	"image/png"
+	"io"
 	"log"
+	"log/slog"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
 	"strconv"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
 
 	"github.com/k0kubun/pp/v3"
+
+	"skritto/dat"
 )
 
+// datPathEnv names the environment variable checked first when --dat is
+// omitted.
+const datPathEnv = dat.DatPathEnv
+
+const usage = `Usage:
+  skritto extract [--dat <path>] [-v] --id <n> [--file-id] --out <path>
+  skritto list [--dat <path>] [-v] [--json] [--deleted]
+  skritto dump [--dat <path>] [-v] --id <n> [--file-id] [--len <bytes>]
+  skritto texture [--dat <path>] [-v] --id <n> --out <path> [--format png|dds]
+  skritto unpack [--dat <path>] [-v] --out <dir> [--workers N] [--skip-errors] [--resume]
+  skritto <MFT index>
+
+The dat path defaults to $` + datPathEnv + `, then to the usual Guild Wars 2
+install locations.
+`
+
 func main() {
 	// Retrieve command-line arguments
 	args := os.Args
 	if len(args) < 2 {
-		fmt.Println("Usage: program <MFT index>")
-		return
+		fmt.Print(usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch args[1] {
+	case "extract":
+		err = runExtract(args[2:])
+	case "list":
+		err = runList(args[2:], os.Stdout)
+	case "dump":
+		err = runHexDump(args[2:], os.Stdout)
+	case "unpack":
+		err = runUnpack(context.Background(), args[2:], os.Stdout)
+	case "texture":
+		err = runTexture(args[2:])
+	default:
+		err = runDump(args[1:], os.Stdout)
+	}
+
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// resolveDatPath returns path or, when it is empty, the dat found by
+// dat.FindDatFile.
+func resolveDatPath(path string) (string, error) {
+	if path != "" {
+		return path, nil
+	}
+	path, err := dat.FindDatFile()
+	if err != nil {
+		return "", fmt.Errorf("no dat path given and %w: pass --dat or set %s", err, datPathEnv)
+	}
+	return path, nil
+}
+
+// openDat loads the .dat file at path, see resolveDatPath for the default. When
+// verbose is set the library's debug output goes to stderr.
+func openDat(path string, verbose bool) (*dat.DatFile, error) {
+	datFilePath, err := resolveDatPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var opts dat.Options
+	if verbose {
+		opts.Logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	}
+
+	log.Printf("Loading .dat file from path: %s\n", datFilePath)
+	datFile, err := dat.OpenWithOptions(datFilePath, opts)
+	if err != nil {
+		return nil, fmt.Errorf("loading .dat file: %w", err)
+	}
+	log.Println(".dat file loaded successfully.")
+	return datFile, nil
+}
+
+// runExtract writes the decompressed contents of one entry to a file.
+func runExtract(args []string) error {
+	flags := flag.NewFlagSet("extract", flag.ContinueOnError)
+	datPath := flags.String("dat", "", "path to the .dat file (default $"+datPathEnv+" or a detected install)")
+	id := flags.Uint("id", 0, "base ID of the entry, or its file ID with --file-id")
+	isFileID := flags.Bool("file-id", false, "treat --id as a file ID")
+	verbose := flags.Bool("v", false, "log debug output from the dat reader")
+	outPath := flags.String("out", "", "path to write the extracted entry to, or a directory to name it <id>.<ext> in")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *outPath == "" {
+		return fmt.Errorf("extract: --out is required")
+	}
+	if *id > 0xFFFFFFFF {
+		return fmt.Errorf("extract: --id %d out of range", *id)
 	}
 
+	datFile, err := openDat(*datPath, *verbose)
+	if err != nil {
+		return err
+	}
+	defer datFile.Close()
+
+	data, err := datFile.Extract(uint32(*id), *isFileID)
+	if err != nil {
+		return fmt.Errorf("extracting entry %d: %w", *id, err)
+	}
+
+	path := *outPath
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, fmt.Sprintf("%d%s", *id, dat.ExtensionFor(dat.DetectFormat(data))))
+	}
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	log.Printf("Wrote %d bytes to %s.\n", len(data), path)
+	return nil
+}
+
+// listRow is one line of the list output.
+type listRow struct {
+	Index      int    `json:"index"`
+	Offset     uint64 `json:"offset"`
+	Size       uint32 `json:"size"`
+	Compressed bool   `json:"compressed"`
+	CRC        uint32 `json:"crc"`
+}
+
+// runList prints the MFT table to stdout, as aligned columns or as a JSON
+// array.
+func runList(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	datPath := flags.String("dat", "", "path to the .dat file (default $"+datPathEnv+" or a detected install)")
+	asJSON := flags.Bool("json", false, "print the table as a JSON array")
+	deleted := flags.Bool("deleted", false, "also list rows marked deleted")
+	verbose := flags.Bool("v", false, "log debug output from the dat reader")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	datFile, err := openDat(*datPath, *verbose)
+	if err != nil {
+		return err
+	}
+	defer datFile.Close()
+
+	entries := datFile.ListEntriesWith(dat.ListOptions{IncludeDeleted: *deleted})
+	rows := make([]listRow, len(entries))
+	for i, entry := range entries {
+		rows[i] = listRow{entry.Index, entry.Offset, entry.Size, entry.Compressed(), entry.CRC}
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(rows)
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "INDEX\tOFFSET\tSIZE\tCOMPRESSED\tCRC\t")
+	for _, row := range rows {
+		compressed := "n"
+		if row.Compressed {
+			compressed = "y"
+		}
+		fmt.Fprintf(w, "%d\t%d\t%d\t%s\t%08x\t\n", row.Index, row.Offset, row.Size, compressed, row.CRC)
+	}
+	return w.Flush()
+}
+
+// unpackedHook, when set, is called by the unpack workers with the MFT index
+// of every entry written and recorded in the ledger. It lets tests follow and
+// interrupt a run.
+var unpackedHook func(index int)
+
+// runUnpack extracts every entry of the dat into a directory, naming each
+// file <MFT index>.<ext> after its detected format. Finished entries are
+// recorded in a ledger in the directory so that --resume can skip them. The
+// run stops as if interrupted once ctx is done. The summary goes to stdout.
+func runUnpack(ctx context.Context, args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("unpack", flag.ContinueOnError)
+	datPath := flags.String("dat", "", "path to the .dat file (default $"+datPathEnv+" or a detected install)")
+	outDir := flags.String("out", "", "directory to write the entries to, created if missing")
+	workers := flags.Int("workers", runtime.NumCPU(), "number of entries to extract concurrently")
+	skipErrors := flags.Bool("skip-errors", false, "keep going when an entry fails to extract")
+	resume := flags.Bool("resume", false, "skip entries an earlier unpack into --out already finished")
+	verbose := flags.Bool("v", false, "log debug output from the dat reader")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *outDir == "" {
+		return fmt.Errorf("unpack: --out is required")
+	}
+	if *workers < 1 {
+		return fmt.Errorf("unpack: --workers must be at least 1")
+	}
+	if err := os.MkdirAll(*outDir, 0o755); err != nil {
+		return fmt.Errorf("creating %s: %w", *outDir, err)
+	}
+
+	datFile, err := openDat(*datPath, *verbose)
+	if err != nil {
+		return err
+	}
+	defer datFile.Close()
+
+	ledger, done, err := openLedger(*outDir, *resume)
+	if err != nil {
+		return err
+	}
+	defer ledger.Close()
+
+	// Interrupting stops the run with the ledger flushed, ready for --resume
+	interrupted, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	// Without --skip-errors the first failure stops the remaining work
+	ctx, cancel := context.WithCancel(interrupted)
+	defer cancel()
+
+	var (
+		wg                sync.WaitGroup
+		mu                sync.Mutex
+		firstErr          error
+		succeeded, failed atomic.Int64
+		skipped           int
+		jobs              = make(chan dat.EntryInfo)
+	)
+
+	for i := 0; i < *workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for info := range jobs {
+				if ctx.Err() != nil {
+					continue // Draining after a failure
+				}
+
+				if err := unpackEntry(ctx, datFile, info, *outDir); err != nil {
+					failed.Add(1)
+					log.Printf("MFT entry %d: %v\n", info.Index, err)
+					if !*skipErrors {
+						mu.Lock()
+						if firstErr == nil {
+							firstErr = fmt.Errorf("unpacking MFT entry %d: %w", info.Index, err)
+							cancel()
+						}
+						mu.Unlock()
+					}
+					continue
+				}
+				succeeded.Add(1)
+
+				if err := ledger.record(info.Index); err != nil {
+					log.Printf("Recording MFT entry %d in the ledger: %v\n", info.Index, err)
+				}
+				if unpackedHook != nil {
+					unpackedHook(info.Index)
+				}
+			}
+		}()
+	}
+
+	system := make(map[int]bool)
+	for _, index := range datFile.SystemEntries() {
+		system[index] = true
+	}
+	for _, info := range datFile.ListEntries() {
+		// The system rows describe the dat itself, and empty rows hold nothing
+		if system[info.Index] || info.Size == 0 {
+			continue
+		}
+		if done[info.Index] {
+			skipped++
+			continue
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- info
+	}
+	close(jobs)
+	wg.Wait()
+
+	fmt.Fprintf(stdout, "Unpacked %d entries to %s, %d failed, %d already done.\n", succeeded.Load(), *outDir, failed.Load(), skipped)
+	if firstErr == nil && interrupted.Err() != nil {
+		return fmt.Errorf("unpack interrupted; rerun with --resume to continue")
+	}
+	return firstErr
+}
+
+// unpackEntry extracts one entry into dir.
+func unpackEntry(ctx context.Context, datFile *dat.DatFile, info dat.EntryInfo, dir string) error {
+	data, err := datFile.ExtractContext(ctx, info.BaseID, false)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("%d%s", info.Index, dat.ExtensionFor(dat.DetectFormat(data))))
+	return os.WriteFile(path, data, 0o644)
+}
+
+// runTexture decodes an ATEX texture and writes it as a PNG, or as the DDS
+// holding its top mip level with --format dds.
+func runTexture(args []string) error {
+	flags := flag.NewFlagSet("texture", flag.ContinueOnError)
+	datPath := flags.String("dat", "", "path to the .dat file (default $"+datPathEnv+" or a detected install)")
+	id := flags.Uint("id", 0, "base ID of the texture entry")
+	outPath := flags.String("out", "", "path to write the image to")
+	format := flags.String("format", "png", "output format, png or dds")
+	verbose := flags.Bool("v", false, "log debug output from the dat reader")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *outPath == "" {
+		return fmt.Errorf("texture: --out is required")
+	}
+	if *id > 0xFFFFFFFF {
+		return fmt.Errorf("texture: --id %d out of range", *id)
+	}
+	if *format != "png" && *format != "dds" {
+		return fmt.Errorf("texture: unknown --format %q, want png or dds", *format)
+	}
+
+	datFile, err := openDat(*datPath, *verbose)
+	if err != nil {
+		return err
+	}
+	defer datFile.Close()
+
+	if *format == "dds" {
+		data, err := datFile.ExtractTextureDDS(uint32(*id))
+		if err != nil {
+			return fmt.Errorf("extracting texture %d: %w", *id, err)
+		}
+		if err := os.WriteFile(*outPath, data, 0o644); err != nil {
+			return fmt.Errorf("writing %s: %w", *outPath, err)
+		}
+		log.Printf("Wrote %d bytes to %s.\n", len(data), *outPath)
+		return nil
+	}
+
+	width, height, pixels, err := datFile.ExtractTextureRGBA(uint32(*id))
+	if err != nil {
+		return fmt.Errorf("decoding texture %d: %w", *id, err)
+	}
+
+	// DXT alpha is straight, not premultiplied
+	img := &image.NRGBA{Pix: pixels, Stride: 4 * width, Rect: image.Rect(0, 0, width, height)}
+	file, err := os.Create(*outPath)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(file, img); err != nil {
+		file.Close()
+		return fmt.Errorf("encoding %s: %w", *outPath, err)
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	log.Printf("Wrote %dx%d PNG to %s.\n", width, height, *outPath)
+	return nil
+}
+
+// runHexDump hex-dumps the start of one entry to stdout.
+func runHexDump(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("dump", flag.ContinueOnError)
+	datPath := flags.String("dat", "", "path to the .dat file (default $"+datPathEnv+" or a detected install)")
+	id := flags.Uint("id", 0, "base ID of the entry, or its file ID with --file-id")
+	isFileID := flags.Bool("file-id", false, "treat --id as a file ID")
+	verbose := flags.Bool("v", false, "log debug output from the dat reader")
+	length := flags.Uint("len", 256, "bytes to dump, at most the entry size; 0 dumps the whole entry")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *id > 0xFFFFFFFF {
+		return fmt.Errorf("dump: --id %d out of range", *id)
+	}
+	if *length > 0xFFFFFFFF {
+		return fmt.Errorf("dump: --len %d out of range", *length)
+	}
+
+	datFile, err := openDat(*datPath, *verbose)
+	if err != nil {
+		return err
+	}
+	defer datFile.Close()
+
+	// ExtractPrefix stops decompressing once the requested bytes are out
+	var data []byte
+	if *length == 0 {
+		data, err = datFile.Extract(uint32(*id), *isFileID)
+	} else {
+		data, err = datFile.ExtractPrefix(uint32(*id), *isFileID, uint32(*length))
+	}
+	if err != nil {
+		return fmt.Errorf("extracting entry %d: %w", *id, err)
+	}
+
+	fmt.Fprint(stdout, hex.Dump(data))
+	return nil
+}
+
+// runDump is the original invocation: extract a base ID and hex-dump it.
+func runDump(args []string, stdout io.Writer) error {
 	// Convert the MFT index argument to uint32
-	mftIndex, err := strconv.ParseUint(args[1], 10, 32)
+	mftIndex, err := strconv.ParseUint(args[0], 10, 32)
 	if err != nil {
-		fmt.Printf("Error parsing MFT index '%s': %v\n", args[1], err)
-		return
+		fmt.Fprint(stdout, usage)
+		return fmt.Errorf("parsing MFT index '%s': %w", args[0], err)
 	}
 
 	// Load the .dat file
 	log.Println("Attempting to load .dat file...")
-	datFilePath := "C:\\Program Files (x86)\\Steam\\steamapps\\common\\Guild Wars 2\\Gw2.dat"
-	log.Printf("Loading .dat file from path: %s\n", datFilePath)
-	datFile, err := loadDatFile(datFilePath)
+	datFile, err := openDat("", true)
 	if err != nil {
-		fmt.Printf("Error loading .dat file: %v\n", err)
-		return
+		return err
 	}
-	log.Println(".dat file loaded successfully.")
-	pp.Println(&datFile.Header)
+	defer datFile.Close()
+	pp.Fprintln(stdout, &datFile.Header)
 
 	// Extract MFT data
 	log.Printf("Attempting to extract MFT data for index %d...\n", mftIndex)
-	data, err := extractMFTData(datFile, uint32(mftIndex), false)
+	data, err := datFile.Extract(uint32(mftIndex), false)
 	if err != nil {
-		fmt.Printf("Error extracting MFT data for index %d: %v\n", mftIndex, err)
-		return
+		return fmt.Errorf("extracting MFT data for index %d: %w", mftIndex, err)
 	}
 
 	log.Printf("Successfully extracted MFT data for index %d.\n", mftIndex)
-	fmt.Printf("Extracted data (first 128 bytes):\n%s\n", hex.Dump(data[:128]))
+	// Small entries, such as config blobs, are shorter than the usual 128 bytes
+	data = data[:min(128, len(data))]
+	fmt.Fprintf(stdout, "Extracted data (first %d bytes):\n%s\n", len(data), hex.Dump(data))
+	return nil
 }
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"image/png"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"skritto/dat"
+)
+
+// fixtureDat is the dat the tests run the commands on, generated by the dat
+// package's TestCLIFixture. See cliFixture there for its entries.
+const fixtureDat = "dat/testdata/cli.dat"
+
+// update makes the tests rewrite the golden files in testdata.
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func TestMain(m *testing.M) {
+	// The commands report progress through the log package
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// fixtureEntry returns the decompressed contents of the fixture entry with
+// the given base ID, as the library extracts it.
+func fixtureEntry(t *testing.T, baseID uint32) []byte {
+	t.Helper()
+	datFile, err := dat.Open(fixtureDat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer datFile.Close()
+	data, err := datFile.ExtractByBaseID(baseID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestExtract(t *testing.T) {
+	compressed := fixtureEntry(t, 5)
+	if len(compressed) != 3000 {
+		t.Fatalf("fixture base ID 5 holds %d bytes, want 3000", len(compressed))
+	}
+
+	dir := t.TempDir()
+	tests := []struct {
+		name string
+		env  string // GW2_DAT_PATH
+		args []string
+		path string // Written file
+		want []byte
+	}{
+		{
+			"file ID with --dat", "",
+			[]string{"--dat", fixtureDat, "--id", "100", "--file-id", "--out", filepath.Join(dir, "stored")},
+			filepath.Join(dir, "stored"), []byte("stored entry"),
+		},
+		{
+			"compressed file ID from the environment", fixtureDat,
+			[]string{"--id", "102", "--file-id", "--out", filepath.Join(dir, "compressed")},
+			filepath.Join(dir, "compressed"), compressed,
+		},
+		{
+			"base ID into a directory", fixtureDat,
+			[]string{"--id", "6", "--out", dir},
+			filepath.Join(dir, "6.bin"), []byte("0123456789"),
+		},
+	}
+	for _, test := range tests {
+		t.Setenv(datPathEnv, test.env)
+		if err := runExtract(test.args); err != nil {
+			t.Errorf("%s: runExtract: %v", test.name, err)
+			continue
+		}
+		if got, err := os.ReadFile(test.path); err != nil || !bytes.Equal(got, test.want) {
+			t.Errorf("%s: wrote %d bytes, %v; want %d bytes of the entry", test.name, len(got), err, len(test.want))
+		}
+	}
+
+	t.Setenv(datPathEnv, fixtureDat)
+	for _, args := range [][]string{
+		{"--id", "999", "--file-id", "--out", dir},
+		{"--id", "100", "--file-id"},
+		{"--dat", filepath.Join(dir, "missing.dat"), "--id", "100", "--file-id", "--out", dir},
+	} {
+		if err := runExtract(args); err == nil {
+			t.Errorf("runExtract %q succeeded", args)
+		}
+	}
+}
+
+// checkGolden compares got with the file testdata/name, or rewrites the file
+// with -update.
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.MkdirAll("testdata", 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output does not match %s; rerun with -update if the change is intended:\n%s", path, got)
+	}
+}
+
+func TestList(t *testing.T) {
+	tests := []struct {
+		golden string
+		args   []string
+	}{
+		{"list.golden", nil},
+		{"list_deleted.golden", []string{"--deleted"}},
+		{"list_json.golden", []string{"--json"}},
+		{"list_deleted_json.golden", []string{"--json", "--deleted"}},
+	}
+	for _, test := range tests {
+		var stdout bytes.Buffer
+		if err := runList(append([]string{"--dat", fixtureDat}, test.args...), &stdout); err != nil {
+			t.Errorf("runList %q: %v", test.args, err)
+			continue
+		}
+		checkGolden(t, test.golden, stdout.Bytes())
+	}
+}
+
+// unpackedFiles returns the names of the files unpack wrote to dir, leaving
+// out the ledger.
+func unpackedFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.Name() != ledgerName {
+			names = append(names, entry.Name())
+		}
+	}
+	return names
+}
+
+func TestUnpack(t *testing.T) {
+	// Every row but the reserved, deleted and empty ones: base IDs 4 to 7
+	// and 10 to 21, named after their MFT index
+	want := []string{"10.bin", "11.bin", "12.bin", "13.bin", "14.bin", "15.bin", "16.bin", "17.bin",
+		"18.bin", "19.bin", "20.bin", "3.bin", "4.bin", "5.bin", "6.dds", "9.bin"}
+
+	for _, workers := range []string{"1", "4"} {
+		dir := filepath.Join(t.TempDir(), "out")
+		var stdout bytes.Buffer
+		if err := runUnpack(context.Background(), []string{"--dat", fixtureDat, "--out", dir, "--workers", workers}, &stdout); err != nil {
+			t.Fatalf("%s workers: runUnpack: %v", workers, err)
+		}
+		if got := unpackedFiles(t, dir); !slices.Equal(got, want) {
+			t.Errorf("%s workers: unpacked %d files %v, want %d files %v", workers, len(got), got, len(want), want)
+		}
+		if summary := fmt.Sprintf("Unpacked %d entries to %s, 0 failed, 0 already done.\n", len(want), dir); stdout.String() != summary {
+			t.Errorf("%s workers: printed %q, want %q", workers, stdout.String(), summary)
+		}
+		for name, baseID := range map[string]uint32{"3.bin": 4, "4.bin": 5, "6.dds": 7} {
+			if got, err := os.ReadFile(filepath.Join(dir, name)); err != nil || !bytes.Equal(got, fixtureEntry(t, baseID)) {
+				t.Errorf("%s workers: %s holds %d bytes, %v; want base ID %d", workers, name, len(got), err, baseID)
+			}
+		}
+	}
+}
+
+// ledgerIndices returns the MFT indices recorded in the ledger in dir, in
+// order, failing on any line that is not a whole index.
+func ledgerIndices(t *testing.T, dir string) []int {
+	t.Helper()
+	contents, err := os.ReadFile(filepath.Join(dir, ledgerName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var indices []int
+	for _, line := range strings.SplitAfter(string(contents), "\n") {
+		if line == "" {
+			continue
+		}
+		index, err := strconv.Atoi(strings.TrimSuffix(line, "\n"))
+		if err != nil || !strings.HasSuffix(line, "\n") {
+			t.Fatalf("ledger line %q is not an index", line)
+		}
+		indices = append(indices, index)
+	}
+	return indices
+}
+
+func TestUnpackResume(t *testing.T) {
+	all := []int{3, 4, 5, 6, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20}
+	const stopAfter = 5
+	dir := t.TempDir()
+	args := []string{"--dat", fixtureDat, "--out", dir}
+
+	var (
+		mu       sync.Mutex
+		unpacked []int
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	unpackedHook = func(index int) {
+		mu.Lock()
+		defer mu.Unlock()
+		unpacked = append(unpacked, index)
+		if len(unpacked) == stopAfter {
+			cancel()
+		}
+	}
+	defer func() { unpackedHook = nil }()
+
+	// One worker stops right after the entry that cancels the run
+	var stdout bytes.Buffer
+	if err := runUnpack(ctx, append(args, "--workers", "1"), &stdout); err == nil || !strings.Contains(err.Error(), "--resume") {
+		t.Fatalf("runUnpack cancelled partway returned %v, want a hint to resume", err)
+	}
+	first := unpacked
+	if !slices.Equal(first, all[:stopAfter]) {
+		t.Fatalf("first run unpacked %v, want %v", first, all[:stopAfter])
+	}
+	if got := ledgerIndices(t, dir); !slices.Equal(got, first) {
+		t.Errorf("ledger after the first run lists %v, want %v", got, first)
+	}
+
+	// A run killed while writing the ledger leaves a partial last line, here
+	// the start of the next index; resuming must drop it, not extend it
+	ledger, err := os.OpenFile(filepath.Join(dir, ledgerName), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprint(ledger, "1")
+	ledger.Close()
+
+	// Entries already done are never extracted again, whatever the worker count
+	unpacked = nil
+	stdout.Reset()
+	if err := runUnpack(context.Background(), append(args, "--resume", "--workers", "4"), &stdout); err != nil {
+		t.Fatalf("runUnpack --resume: %v", err)
+	}
+	second := slices.Sorted(slices.Values(unpacked))
+	if !slices.Equal(second, all[stopAfter:]) {
+		t.Errorf("resumed run unpacked %v, want %v", second, all[stopAfter:])
+	}
+	summary := fmt.Sprintf("Unpacked %d entries to %s, 0 failed, %d already done.\n", len(all)-stopAfter, dir, stopAfter)
+	if stdout.String() != summary {
+		t.Errorf("resumed run printed %q, want %q", stdout.String(), summary)
+	}
+	recorded := ledgerIndices(t, dir)
+	if !slices.Equal(recorded[:stopAfter], first) || !slices.Equal(slices.Sorted(slices.Values(recorded)), all) {
+		t.Errorf("ledger after resuming lists %v, want %v then the rest of %v once each", recorded, first, all)
+	}
+	if got := unpackedFiles(t, dir); len(got) != len(all) {
+		t.Errorf("unpacked %d files %v, want %d", len(got), got, len(all))
+	}
+
+	// Resuming a finished run extracts nothing
+	unpacked = nil
+	if err := runUnpack(context.Background(), append(args, "--resume"), io.Discard); err != nil || len(unpacked) != 0 {
+		t.Errorf("resuming a finished run returned %v after unpacking %v", err, unpacked)
+	}
+}
+
+func TestTexture(t *testing.T) {
+	dir := t.TempDir()
+	args := []string{"--dat", fixtureDat, "--id", "7"}
+
+	// Base ID 7 is an 8x4 DXT1 texture of solid red
+	pngPath := filepath.Join(dir, "texture.png")
+	if err := runTexture(append(args, "--out", pngPath)); err != nil {
+		t.Fatalf("runTexture: %v", err)
+	}
+	file, err := os.Open(pngPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	img, err := png.Decode(file)
+	if err != nil {
+		t.Fatalf("decoding the written PNG: %v", err)
+	}
+	if size := img.Bounds().Size(); size.X != 8 || size.Y != 4 {
+		t.Errorf("PNG is %dx%d, want 8x4", size.X, size.Y)
+	}
+	if r, g, b, a := img.At(7, 3).RGBA(); r != 0xFFFF || g != 0 || b != 0 || a != 0xFFFF {
+		t.Errorf("PNG pixel (7, 3) = %04x %04x %04x %04x, want opaque red", r, g, b, a)
+	}
+
+	ddsPath := filepath.Join(dir, "texture.dds")
+	if err := runTexture(append(args, "--format", "dds", "--out", ddsPath)); err != nil {
+		t.Fatalf("runTexture --format dds: %v", err)
+	}
+	dds, err := os.ReadFile(ddsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Magic, then the header size, flags, height and width; two DXT1 blocks
+	// follow the 124-byte header
+	if len(dds) != 4+124+2*8 || string(dds[:4]) != "DDS " {
+		t.Fatalf("DDS file is %d bytes starting %q, want a DDS header and two DXT1 blocks", len(dds), dds[:min(4, len(dds))])
+	}
+	header := dds[4:]
+	if size, height, width := binary.LittleEndian.Uint32(header), binary.LittleEndian.Uint32(header[8:]), binary.LittleEndian.Uint32(header[12:]); size != 124 || width != 8 || height != 4 {
+		t.Errorf("DDS header size %d describes %dx%d, want 124 describing 8x4", size, width, height)
+	}
+	if fourCC := string(header[80:84]); fourCC != "DXT1" {
+		t.Errorf("DDS pixel format is %q, want DXT1", fourCC)
+	}
+
+	for _, extra := range [][]string{
+		{"--format", "tga", "--out", filepath.Join(dir, "texture.tga")},
+		{"--format", "png"},
+	} {
+		if err := runTexture(append(args, extra...)); err == nil {
+			t.Errorf("runTexture %q succeeded", extra)
+		}
+	}
+	if err := runTexture([]string{"--dat", fixtureDat, "--id", "6", "--out", filepath.Join(dir, "text.png")}); err == nil {
+		t.Error("runTexture of a plain entry succeeded")
+	}
+}
+
+func TestHexDump(t *testing.T) {
+	compressed := fixtureEntry(t, 5)
+	tests := []struct {
+		name string
+		args []string
+		want []byte // Bytes dumped
+	}{
+		{"default length", []string{"--id", "5"}, compressed[:256]},
+		{"length past the entry", []string{"--id", "6", "--len", "100"}, []byte("0123456789")},
+		{"whole entry", []string{"--id", "5", "--len", "0"}, compressed},
+		{"file ID", []string{"--id", "101", "--file-id", "--len", "16"}, compressed[:16]},
+	}
+	for _, test := range tests {
+		var stdout bytes.Buffer
+		if err := runHexDump(append([]string{"--dat", fixtureDat}, test.args...), &stdout); err != nil {
+			t.Errorf("%s: runHexDump: %v", test.name, err)
+			continue
+		}
+		if want := hex.Dump(test.want); stdout.String() != want {
+			t.Errorf("%s: printed\n%s\nwant a dump of %d bytes:\n%s", test.name, stdout.String(), len(test.want), want)
+		}
+	}
+
+	if err := runHexDump([]string{"--dat", fixtureDat, "--id", "999"}, io.Discard); err == nil {
+		t.Error("runHexDump of a missing entry succeeded")
+	}
+}
+
+func TestDump(t *testing.T) {
+	t.Setenv(datPathEnv, fixtureDat)
+
+	// Base ID 6 is shorter than the 128 bytes the legacy form prints
+	var stdout bytes.Buffer
+	if err := runDump([]string{"6"}, &stdout); err != nil {
+		t.Fatalf("runDump: %v", err)
+	}
+	if want := fmt.Sprintf("Extracted data (first 10 bytes):\n%s\n", hex.Dump([]byte("0123456789"))); !strings.HasSuffix(stdout.String(), want) {
+		t.Errorf("runDump printed\n%s\nwant it to end with\n%s", stdout.String(), want)
+	}
+
+	for _, arg := range []string{"six", "999"} {
+		if err := runDump([]string{arg}, io.Discard); err == nil {
+			t.Errorf("runDump %q succeeded", arg)
+		}
+	}
+}